@@ -0,0 +1,706 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+	convJob "github.com/sofmon/convention/lib/job"
+)
+
+type OperationID string
+
+type OperationState string
+
+const (
+	OperationStateRunning   OperationState = "running"
+	OperationStateSucceeded OperationState = "succeeded"
+	OperationStateFailed    OperationState = "failed"
+	OperationStateCancelled OperationState = "cancelled"
+
+	asyncResumeJobID convJob.JobID = "convention-api-async-resume"
+)
+
+// Heartbeat and resume tuning; package vars so tests can shorten them.
+// An operation whose heartbeat is older than asyncLease is considered
+// orphaned (its pod is gone) and is resumed by the job runner.
+var (
+	asyncHeartbeatInterval = 10 * time.Second
+	asyncLease             = time.Minute
+	asyncResumeEvery       = time.Minute
+	asyncPollInterval      = time.Second
+)
+
+// Operation is the public status of an asynchronous operation.
+type Operation struct {
+	ID        OperationID    `json:"id"`
+	State     OperationState `json:"state"`
+	Progress  float64        `json:"progress"` // 0..1, reported by the handler via AsyncProgress
+	Error     *Error         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (op Operation) Done() bool {
+	return op.State == OperationStateSucceeded ||
+		op.State == OperationStateFailed ||
+		op.State == OperationStateCancelled
+}
+
+// asyncOperation is the persisted operation, including what is needed to resume it
+type asyncOperation struct {
+	Operation
+
+	Endpoint        string           `json:"endpoint"`
+	Values          []string         `json:"values,omitempty"`
+	Input           json.RawMessage  `json:"input,omitempty"`
+	Result          json.RawMessage  `json:"result,omitempty"`
+	Claims          convAuth.Claims  `json:"claims"`
+	Workflow        convCtx.Workflow `json:"workflow"`
+	Owner           string           `json:"owner"`
+	HeartbeatAt     time.Time        `json:"heartbeat_at"`
+	CancelRequested bool             `json:"cancel_requested"`
+}
+
+func (x asyncOperation) DBKey() convDB.Key[OperationID, OperationID] {
+	return convDB.Key[OperationID, OperationID]{
+		ID:       x.ID,
+		ShardKey: x.ID,
+	}
+}
+
+type asyncProgressKey struct{}
+
+// AsyncProgress reports the progress (0..1) of the asynchronous operation
+// running with ctx. It is persisted with the next heartbeat.
+func AsyncProgress(ctx convCtx.Context, progress float64) {
+	p, ok := ctx.Value(asyncProgressKey{}).(*atomic.Uint64)
+	if !ok {
+		return
+	}
+	p.Store(math.Float64bits(math.Max(0, math.Min(1, progress))))
+}
+
+// EnableAsync persists the operations of all Async endpoints in the vault
+// and registers a job resuming operations orphaned by a restart; the job
+// runner must be initialised beforehand.
+func (srv *server) EnableAsync(vault convDB.Vault, tenant convAuth.Tenant) (err error) {
+
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if !ok {
+		return
+	}
+
+	runner := &asyncRunner{
+		ctx:      h.ctx,
+		ops:      convDB.NewObjectSet[asyncOperation](vault).Ready().Tenant(tenant),
+		owner:    uuid.NewString(),
+		handlers: map[string]*asyncCore{},
+		running:  map[OperationID]context.CancelFunc{},
+	}
+
+	for _, ep := range h.eps {
+		if core, ok := ep.(interface{ setAsyncRunner(r *asyncRunner) }); ok {
+			core.setAsyncRunner(runner)
+		}
+	}
+
+	return convJob.Register(h.ctx, tenant, asyncResumeJobID, time.Now().UTC(), asyncResumeEvery, runner.resume)
+}
+
+type asyncRunner struct {
+	ctx   convCtx.Context
+	ops   convDB.TenantObjectSet[asyncOperation, OperationID, OperationID]
+	owner string // identifies this instance as the one running an operation
+
+	mu       sync.Mutex
+	handlers map[string]*asyncCore
+	running  map[OperationID]context.CancelFunc
+}
+
+func (r *asyncRunner) start(ctx convCtx.Context, w http.ResponseWriter, core *asyncCore, vals values, input json.RawMessage) {
+
+	now := time.Now().UTC()
+
+	op := asyncOperation{
+		Operation: Operation{
+			ID:        OperationID(uuid.NewString()),
+			State:     OperationStateRunning,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		Endpoint:    core.key(),
+		Input:       input,
+		Claims:      ctx.Claims(),
+		Workflow:    ctx.Workflow(),
+		Owner:       r.owner,
+		HeartbeatAt: now,
+	}
+	for _, v := range vals {
+		op.Values = append(op.Values, v.Value)
+	}
+
+	err := r.ops.Insert(ctx, op)
+	if err != nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unable to store operation", err)
+		return
+	}
+
+	go r.run(op)
+
+	w.Header().Set("Location", ctx.Request().URL.Path+"/operations/"+string(op.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(op.Operation)
+}
+
+func (r *asyncRunner) run(op asyncOperation) {
+
+	r.mu.Lock()
+	core := r.handlers[op.Endpoint]
+	r.mu.Unlock()
+
+	logger := r.ctx.Logger().With("operation", string(op.ID), "endpoint", op.Endpoint)
+
+	c, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	r.mu.Lock()
+	r.running[op.ID] = cancel
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, op.ID)
+		r.mu.Unlock()
+	}()
+
+	progress := &atomic.Uint64{}
+	progress.Store(math.Float64bits(op.Progress))
+
+	ctx := convCtx.Context{Context: context.WithValue(c, asyncProgressKey{}, progress)}.
+		WithClaims(op.Claims).
+		WithWorkflow(op.Workflow)
+
+	var lost atomic.Bool
+
+	stop := make(chan struct{})
+	hbDone := make(chan struct{})
+
+	go func() {
+		defer close(hbDone)
+		ticker := time.NewTicker(asyncHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := r.update(op.ID, func(o *asyncOperation) bool {
+					if o.Owner != r.owner {
+						lost.Store(true) // resumed by another instance
+						cancel()
+						return false
+					}
+					if o.CancelRequested {
+						cancel()
+					}
+					o.HeartbeatAt = time.Now().UTC()
+					o.UpdatedAt = o.HeartbeatAt
+					o.Progress = math.Float64frombits(progress.Load())
+					return true
+				})
+				if err != nil {
+					logger.Warn("operation heartbeat failed, will retry", "error", err)
+				}
+			}
+		}
+	}()
+
+	var (
+		result json.RawMessage
+		runErr error
+	)
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				runErr = fmt.Errorf("operation panicked: %v", p)
+			}
+		}()
+		if core == nil {
+			runErr = fmt.Errorf("no async endpoint '%s' on this server", op.Endpoint)
+			return
+		}
+		result, runErr = core.exec(ctx, op)
+	}()
+
+	close(stop)
+	<-hbDone
+
+	if lost.Load() {
+		logger.Warn("operation taken over by another instance")
+		return
+	}
+
+	err := r.update(op.ID, func(o *asyncOperation) bool {
+		if o.Owner != r.owner {
+			return false
+		}
+		switch {
+		case o.CancelRequested:
+			o.State = OperationStateCancelled
+		case runErr != nil:
+			o.State = OperationStateFailed
+			var apiErr *Error
+			if !errors.As(runErr, &apiErr) {
+				apiErr = newError(ctx, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", runErr)
+			}
+			o.Error = apiErr
+		default:
+			o.State = OperationStateSucceeded
+			o.Result = result
+			o.Progress = 1
+		}
+		o.UpdatedAt = time.Now().UTC()
+		return true
+	})
+	if err != nil {
+		logger.Error("unable to store operation outcome", "error", err)
+	}
+}
+
+// update applies fn to the stored operation with compare-and-swap,
+// retrying on concurrent modification; fn returns false to skip the update.
+func (r *asyncRunner) update(id OperationID, fn func(o *asyncOperation) bool) (err error) {
+
+	for range 5 {
+
+		var cur *asyncOperation
+		cur, err = r.ops.SelectByID(r.ctx, id)
+		if err != nil {
+			return
+		}
+		if cur == nil {
+			return convDB.ErrObjectNotFound
+		}
+
+		next := *cur
+		if !fn(&next) {
+			return nil
+		}
+
+		err = r.ops.SafeUpdate(r.ctx, *cur, next)
+		if errors.Is(err, convDB.ErrCASConflict) || errors.Is(err, convDB.ErrLockNotAvailable) {
+			continue
+		}
+		return
+	}
+
+	return
+}
+
+// resume claims running operations with an expired heartbeat and runs them again
+func (r *asyncRunner) resume(ctx convCtx.Context) (err error) {
+
+	ops, err := r.ops.Select(ctx, convDB.Where().Key("state").Equals().Value(OperationStateRunning))
+	if err != nil {
+		return
+	}
+
+	for _, op := range ops {
+
+		if time.Since(op.HeartbeatAt) < asyncLease {
+			continue
+		}
+
+		claimed := op
+		claimed.Owner = r.owner
+		claimed.HeartbeatAt = time.Now().UTC()
+
+		if r.ops.SafeUpdate(ctx, op, claimed) != nil {
+			continue // claimed by another instance
+		}
+
+		ctx.Logger().Info("resuming orphaned operation", "operation", string(op.ID), "previous_owner", op.Owner)
+
+		go r.run(claimed)
+	}
+
+	return
+}
+
+func (r *asyncRunner) cancelLocal(id OperationID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.running[id]; ok {
+		cancel()
+	}
+}
+
+// asyncCore holds what all Async endpoint variants share; the typed
+// variants only differ in how the stored input and values are decoded.
+type asyncCore struct {
+	descriptor descriptor
+	runner     *asyncRunner
+	checks     []Check
+	inType     reflect.Type
+	outType    reflect.Type
+	paramTypes []reflect.Type
+	exec       func(ctx convCtx.Context, op asyncOperation) (json.RawMessage, error)
+}
+
+func (x *asyncCore) key() string {
+	return x.descriptor.method + " " + x.descriptor.path()
+}
+
+func (x *asyncCore) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	vals, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	if x.runner == nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "async operations are not enabled; call EnableAsync on the server", nil)
+		return true
+	}
+
+	// validate the path values now rather than failing in the background
+	name, err := checkParams(vals, x.paramTypes)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("invalid path parameter '%s'", name), err)
+		return true
+	}
+
+	for _, check := range x.checks {
+		err := check(ctx)
+		if err != nil {
+			var apiErr *Error
+			if errors.As(err, &apiErr) {
+				serveError(w, apiErr)
+			} else {
+				ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+			}
+			return true
+		}
+	}
+
+	var input json.RawMessage
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to decode http payload", err)
+		return true
+	}
+
+	// validate the input now rather than failing in the background
+	err = json.Unmarshal(input, reflect.New(x.inType).Interface())
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to decode http payload", err)
+		return true
+	}
+
+	x.runner.start(ctx, w, x, vals, input)
+
+	return true
+}
+
+func (x *asyncCore) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *asyncCore) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *asyncCore) getParamTypes() []reflect.Type {
+	return x.paramTypes
+}
+
+func (x *asyncCore) getInOutTypes() (in, out reflect.Type) {
+	return x.inType, reflect.TypeFor[Operation]()
+}
+
+func (x *asyncCore) setEndpoints(eps endpoints) {}
+
+func (x *asyncCore) setAsyncRunner(r *asyncRunner) {
+	x.runner = r
+	r.mu.Lock()
+	r.handlers[x.key()] = x
+	r.mu.Unlock()
+}
+
+// subEndpoints adds the status, result and cancel endpoints of the operations
+func (x *asyncCore) subEndpoints() endpoints {
+
+	desc := x.descriptor
+	base := desc.path() + "/operations/{operation}"
+
+	newSub := func(pattern string, out reflect.Type, fn func(ctx convCtx.Context, w http.ResponseWriter, op *asyncOperation)) *asyncSubEndpoint {
+		return &asyncSubEndpoint{
+			descriptor: newDescriptor(desc.host, desc.port, pattern, nil, out),
+			core:       x,
+			fn:         fn,
+		}
+	}
+
+	return endpoints{
+		newSub("GET "+base, reflect.TypeFor[Operation](), func(ctx convCtx.Context, w http.ResponseWriter, op *asyncOperation) {
+			ServeJSON(w, op.Operation)
+		}),
+		newSub("GET "+base+"/result", x.outType, func(ctx convCtx.Context, w http.ResponseWriter, op *asyncOperation) {
+			switch op.State {
+			case OperationStateSucceeded:
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write(op.Result)
+			case OperationStateFailed:
+				serveError(w, op.Error)
+			case OperationStateCancelled:
+				ServeError(ctx, w, http.StatusConflict, ErrorCodeConflict, "operation was cancelled", nil)
+			default:
+				ServeError(ctx, w, http.StatusConflict, ErrorCodeConflict, "operation is not completed", nil)
+			}
+		}),
+		newSub("DELETE "+base, reflect.TypeFor[Operation](), func(ctx convCtx.Context, w http.ResponseWriter, op *asyncOperation) {
+			if op.Done() {
+				ServeError(ctx, w, http.StatusConflict, ErrorCodeConflict, "operation is already completed", nil)
+				return
+			}
+			err := x.runner.update(op.ID, func(o *asyncOperation) bool {
+				o.CancelRequested = true
+				*op = *o
+				return true
+			})
+			if err != nil {
+				ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unable to cancel operation", err)
+				return
+			}
+			x.runner.cancelLocal(op.ID) // other instances notice with the next heartbeat
+			ServeJSON(w, op.Operation)
+		}),
+	}
+}
+
+type asyncSubEndpoint struct {
+	descriptor descriptor
+	core       *asyncCore
+	fn         func(ctx convCtx.Context, w http.ResponseWriter, op *asyncOperation)
+}
+
+func (x *asyncSubEndpoint) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	vals, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	if x.core.runner == nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "async operations are not enabled; call EnableAsync on the server", nil)
+		return true
+	}
+
+	id := OperationID(vals.GetByIndex(len(vals) - 1))
+
+	op, err := x.core.runner.ops.SelectByID(ctx, id)
+	if err != nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unable to load operation", err)
+		return true
+	}
+
+	// operations are visible to the user who started them only, on the same path
+	found := op != nil &&
+		op.Endpoint == x.core.key() &&
+		op.Claims.User == ctx.User() &&
+		len(op.Values) == len(vals)-1
+	for i := 0; found && i < len(op.Values); i++ {
+		found = op.Values[i] == vals.GetByIndex(i)
+	}
+	if !found {
+		ServeError(ctx, w, http.StatusNotFound, ErrorCodeNotFound, "operation not found", nil)
+		return true
+	}
+
+	x.fn(ctx, w, op)
+
+	return true
+}
+
+func (x *asyncSubEndpoint) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *asyncSubEndpoint) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *asyncSubEndpoint) getInOutTypes() (in, out reflect.Type) {
+	return nil, nil
+}
+
+func (x *asyncSubEndpoint) setEndpoints(eps endpoints) {}
+
+// AsyncOperation is the client side handle of a started operation.
+type AsyncOperation[outT any] struct {
+	Operation
+
+	desc *descriptor
+	vals values
+	err  error // of formatting the path values
+}
+
+func (x *AsyncOperation[outT]) do(ctx convCtx.Context, method, suffix string) (req *http.Request, res *http.Response, err error) {
+
+	if x.err != nil {
+		return nil, nil, x.err
+	}
+
+	req, err = x.desc.newRequest(x.vals, nil)
+	if err != nil {
+		return
+	}
+
+	req.Method = method
+	req.URL.Path += "/operations/" + string(x.ID) + suffix
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Accept", "application/json")
+
+	res, err = x.desc.send(ctx, req)
+	return
+}
+
+// Status refreshes and returns the status of the operation
+func (x *AsyncOperation[outT]) Status(ctx convCtx.Context) (op Operation, err error) {
+
+	req, res, err := x.do(ctx, http.MethodGet, "")
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(&x.Operation)
+		op = x.Operation
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
+
+// Result returns the result of a succeeded operation or the error of a failed one
+func (x *AsyncOperation[outT]) Result(ctx convCtx.Context) (out outT, err error) {
+
+	req, res, err := x.do(ctx, http.MethodGet, "/result")
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(&out)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
+
+// Cancel requests the cancellation of the operation; the handler's ctx is cancelled
+func (x *AsyncOperation[outT]) Cancel(ctx convCtx.Context) (err error) {
+
+	req, res, err := x.do(ctx, http.MethodDelete, "")
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(&x.Operation)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
+
+// Wait polls the operation until it completes or ctx is done
+func (x *AsyncOperation[outT]) Wait(ctx convCtx.Context) (out outT, err error) {
+
+	for {
+		var op Operation
+		op, err = x.Status(ctx)
+		if err != nil {
+			return
+		}
+
+		if op.Done() {
+			return x.Result(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(asyncPollInterval):
+		}
+	}
+}
+
+func startAsync[outT any](ctx convCtx.Context, desc *descriptor, vals values, in any) (op *AsyncOperation[outT], err error) {
+
+	if !desc.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+
+	req, err := desc.newRequest(vals, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+
+	res, err := desc.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusAccepted {
+		op = &AsyncOperation[outT]{desc: desc, vals: vals}
+		err = json.NewDecoder(res.Body).Decode(&op.Operation)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewAsync[inT, outT any](fn func(ctx convCtx.Context, in inT) (outT, error)) Async[inT, outT] {
+	return Async[inT, outT]{
+		asyncCore: asyncCore{
+			inType:  reflect.TypeFor[inT](),
+			outType: reflect.TypeFor[outT](),
+			exec: func(ctx convCtx.Context, op asyncOperation) (res json.RawMessage, err error) {
+				var in inT
+				err = json.Unmarshal(op.Input, &in)
+				if err != nil {
+					return
+				}
+
+				out, err := fn(
+					ctx,
+					in,
+				)
+				if err != nil {
+					return
+				}
+
+				return json.Marshal(out)
+			},
+		},
+	}
+}
+
+// WithPreCheck runs the check before the operation is started
+func (x Async[inT, outT]) WithPreCheck(check Check) Async[inT, outT] {
+	x.checks = append(append([]Check{}, x.checks...), check)
+	return x
+}
+
+type Async[inT, outT any] struct {
+	asyncCore
+}
+
+// Start starts the operation and returns its handle once accepted
+func (x *Async[inT, outT]) Start(ctx convCtx.Context, in inT) (op *AsyncOperation[outT], err error) {
+	return startAsync[outT](ctx, &x.descriptor, nil, in)
+}
+
+// Operation returns the handle of an operation started earlier
+func (x *Async[inT, outT]) Operation(id OperationID) *AsyncOperation[outT] {
+	return &AsyncOperation[outT]{
+		Operation: Operation{ID: id},
+		desc:      &x.descriptor,
+		vals:      nil,
+	}
+}
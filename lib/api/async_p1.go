@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewAsyncP1[inT, outT, p1T any](fn func(ctx convCtx.Context, p1 p1T, in inT) (outT, error)) AsyncP1[inT, outT, p1T] {
+	return AsyncP1[inT, outT, p1T]{
+		asyncCore: asyncCore{
+			inType:     reflect.TypeFor[inT](),
+			outType:    reflect.TypeFor[outT](),
+			paramTypes: []reflect.Type{reflect.TypeFor[p1T]()},
+			exec: func(ctx convCtx.Context, op asyncOperation) (res json.RawMessage, err error) {
+				var p1 p1T
+				err = parseStoredParams(op.Values, &p1)
+				if err != nil {
+					return
+				}
+
+				var in inT
+				err = json.Unmarshal(op.Input, &in)
+				if err != nil {
+					return
+				}
+
+				out, err := fn(
+					ctx,
+					p1,
+					in,
+				)
+				if err != nil {
+					return
+				}
+
+				return json.Marshal(out)
+			},
+		},
+	}
+}
+
+// WithPreCheck runs the check before the operation is started
+func (x AsyncP1[inT, outT, p1T]) WithPreCheck(check Check) AsyncP1[inT, outT, p1T] {
+	x.checks = append(append([]Check{}, x.checks...), check)
+	return x
+}
+
+type AsyncP1[inT, outT, p1T any] struct {
+	asyncCore
+}
+
+// Start starts the operation and returns its handle once accepted
+func (x *AsyncP1[inT, outT, p1T]) Start(ctx convCtx.Context, p1 p1T, in inT) (op *AsyncOperation[outT], err error) {
+	vals, err := formatParams(p1)
+	if err != nil {
+		return
+	}
+	return startAsync[outT](ctx, &x.descriptor, vals, in)
+}
+
+// Operation returns the handle of an operation started earlier
+func (x *AsyncP1[inT, outT, p1T]) Operation(p1 p1T, id OperationID) *AsyncOperation[outT] {
+	vals, err := formatParams(p1)
+	return &AsyncOperation[outT]{
+		Operation: Operation{ID: id},
+		desc:      &x.descriptor,
+		vals:      vals,
+		err:       err,
+	}
+}
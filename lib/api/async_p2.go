@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewAsyncP2[inT, outT, p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (outT, error)) AsyncP2[inT, outT, p1T, p2T] {
+	return AsyncP2[inT, outT, p1T, p2T]{
+		asyncCore: asyncCore{
+			inType:     reflect.TypeFor[inT](),
+			outType:    reflect.TypeFor[outT](),
+			paramTypes: []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()},
+			exec: func(ctx convCtx.Context, op asyncOperation) (res json.RawMessage, err error) {
+				var (
+					p1 p1T
+					p2 p2T
+				)
+				err = parseStoredParams(op.Values, &p1, &p2)
+				if err != nil {
+					return
+				}
+
+				var in inT
+				err = json.Unmarshal(op.Input, &in)
+				if err != nil {
+					return
+				}
+
+				out, err := fn(
+					ctx,
+					p1,
+					p2,
+					in,
+				)
+				if err != nil {
+					return
+				}
+
+				return json.Marshal(out)
+			},
+		},
+	}
+}
+
+// WithPreCheck runs the check before the operation is started
+func (x AsyncP2[inT, outT, p1T, p2T]) WithPreCheck(check Check) AsyncP2[inT, outT, p1T, p2T] {
+	x.checks = append(append([]Check{}, x.checks...), check)
+	return x
+}
+
+type AsyncP2[inT, outT, p1T, p2T any] struct {
+	asyncCore
+}
+
+// Start starts the operation and returns its handle once accepted
+func (x *AsyncP2[inT, outT, p1T, p2T]) Start(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (op *AsyncOperation[outT], err error) {
+	vals, err := formatParams(p1, p2)
+	if err != nil {
+		return
+	}
+	return startAsync[outT](ctx, &x.descriptor, vals, in)
+}
+
+// Operation returns the handle of an operation started earlier
+func (x *AsyncP2[inT, outT, p1T, p2T]) Operation(p1 p1T, p2 p2T, id OperationID) *AsyncOperation[outT] {
+	vals, err := formatParams(p1, p2)
+	return &AsyncOperation[outT]{
+		Operation: Operation{ID: id},
+		desc:      &x.descriptor,
+		vals:      vals,
+		err:       err,
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewAsyncP4[inT, outT, p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (outT, error)) AsyncP4[inT, outT, p1T, p2T, p3T, p4T] {
+	return AsyncP4[inT, outT, p1T, p2T, p3T, p4T]{
+		asyncCore: asyncCore{
+			inType:     reflect.TypeFor[inT](),
+			outType:    reflect.TypeFor[outT](),
+			paramTypes: []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()},
+			exec: func(ctx convCtx.Context, op asyncOperation) (res json.RawMessage, err error) {
+				var (
+					p1 p1T
+					p2 p2T
+					p3 p3T
+					p4 p4T
+				)
+				err = parseStoredParams(op.Values, &p1, &p2, &p3, &p4)
+				if err != nil {
+					return
+				}
+
+				var in inT
+				err = json.Unmarshal(op.Input, &in)
+				if err != nil {
+					return
+				}
+
+				out, err := fn(
+					ctx,
+					p1,
+					p2,
+					p3,
+					p4,
+					in,
+				)
+				if err != nil {
+					return
+				}
+
+				return json.Marshal(out)
+			},
+		},
+	}
+}
+
+// WithPreCheck runs the check before the operation is started
+func (x AsyncP4[inT, outT, p1T, p2T, p3T, p4T]) WithPreCheck(check Check) AsyncP4[inT, outT, p1T, p2T, p3T, p4T] {
+	x.checks = append(append([]Check{}, x.checks...), check)
+	return x
+}
+
+type AsyncP4[inT, outT, p1T, p2T, p3T, p4T any] struct {
+	asyncCore
+}
+
+// Start starts the operation and returns its handle once accepted
+func (x *AsyncP4[inT, outT, p1T, p2T, p3T, p4T]) Start(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (op *AsyncOperation[outT], err error) {
+	vals, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
+	}
+	return startAsync[outT](ctx, &x.descriptor, vals, in)
+}
+
+// Operation returns the handle of an operation started earlier
+func (x *AsyncP4[inT, outT, p1T, p2T, p3T, p4T]) Operation(p1 p1T, p2 p2T, p3 p3T, p4 p4T, id OperationID) *AsyncOperation[outT] {
+	vals, err := formatParams(p1, p2, p3, p4)
+	return &AsyncOperation[outT]{
+		Operation: Operation{ID: id},
+		desc:      &x.descriptor,
+		vals:      vals,
+		err:       err,
+	}
+}
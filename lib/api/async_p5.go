@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewAsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (outT, error)) AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
+	return AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T]{
+		asyncCore: asyncCore{
+			inType:     reflect.TypeFor[inT](),
+			outType:    reflect.TypeFor[outT](),
+			paramTypes: []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()},
+			exec: func(ctx convCtx.Context, op asyncOperation) (res json.RawMessage, err error) {
+				var (
+					p1 p1T
+					p2 p2T
+					p3 p3T
+					p4 p4T
+					p5 p5T
+				)
+				err = parseStoredParams(op.Values, &p1, &p2, &p3, &p4, &p5)
+				if err != nil {
+					return
+				}
+
+				var in inT
+				err = json.Unmarshal(op.Input, &in)
+				if err != nil {
+					return
+				}
+
+				out, err := fn(
+					ctx,
+					p1,
+					p2,
+					p3,
+					p4,
+					p5,
+					in,
+				)
+				if err != nil {
+					return
+				}
+
+				return json.Marshal(out)
+			},
+		},
+	}
+}
+
+// WithPreCheck runs the check before the operation is started
+func (x AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) WithPreCheck(check Check) AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
+	x.checks = append(append([]Check{}, x.checks...), check)
+	return x
+}
+
+type AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T any] struct {
+	asyncCore
+}
+
+// Start starts the operation and returns its handle once accepted
+func (x *AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Start(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (op *AsyncOperation[outT], err error) {
+	vals, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
+	}
+	return startAsync[outT](ctx, &x.descriptor, vals, in)
+}
+
+// Operation returns the handle of an operation started earlier
+func (x *AsyncP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Operation(p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, id OperationID) *AsyncOperation[outT] {
+	vals, err := formatParams(p1, p2, p3, p4, p5)
+	return &AsyncOperation[outT]{
+		Operation: Operation{ID: id},
+		desc:      &x.descriptor,
+		vals:      vals,
+		err:       err,
+	}
+}
@@ -0,0 +1,198 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convJob "github.com/sofmon/convention/lib/job"
+)
+
+type asyncInput struct {
+	Value int `json:"value"`
+}
+
+type asyncOutput struct {
+	Result int    `json:"result"`
+	User   string `json:"user"`
+	Report string `json:"report"`
+}
+
+type asyncAPI struct {
+	Double convAPI.AsyncP1[asyncInput, asyncOutput, string] `api:"POST /test/v1/reports/{report}/double"`
+	Fail   convAPI.Async[asyncInput, asyncOutput]           `api:"POST /test/v1/fail"`
+	Block  convAPI.Async[asyncInput, asyncOutput]           `api:"POST /test/v1/block"`
+}
+
+func Test_async(t *testing.T) {
+
+	const (
+		vault  = "jobs"
+		tenant = "test"
+
+		roleReporter       convAuth.Role       = "reporter"
+		permissionReporter convAuth.Permission = "report"
+	)
+
+	defer convAPI.SetAsyncIntervalsForTest(50*time.Millisecond, 10*time.Millisecond)()
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleReporter: convAuth.Permissions{permissionReporter},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionReporter: convAuth.Actions{
+				"POST /test/v1/reports/{any}/double",
+				"GET /test/v1/reports/{any}/double/operations/{any...}",
+				"DELETE /test/v1/reports/{any}/double/operations/{any}",
+				"POST /test/v1/fail",
+				"GET /test/v1/fail/operations/{any...}",
+				"POST /test/v1/block",
+				"GET /test/v1/block/operations/{any...}",
+				"DELETE /test/v1/block/operations/{any}",
+			},
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_async"})
+
+	err := convJob.Initialise(agentCtx, vault)
+	if err != nil {
+		t.Fatalf("convJob.Initialise() = %v; want nil", err)
+	}
+	defer convJob.Cancel()
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "alice", Roles: convAuth.Roles{roleReporter}})
+
+	// left behind by a previous instance of the service
+	orphanID, err := convAPI.InsertOrphanedOperationForTest(callerCtx, vault, tenant, "POST /test/v1/reports/{report}/double", []string{"monthly"}, asyncInput{Value: 21})
+	if err != nil {
+		t.Fatalf("InsertOrphanedOperationForTest() = %v; want nil", err)
+	}
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &asyncAPI{
+		Double: convAPI.NewAsyncP1(func(ctx convCtx.Context, report string, in asyncInput) (asyncOutput, error) {
+			convAPI.AsyncProgress(ctx, 0.5)
+			return asyncOutput{Result: in.Value * 2, User: string(ctx.User()), Report: report}, nil
+		}),
+		Fail: convAPI.NewAsync(func(ctx convCtx.Context, in asyncInput) (asyncOutput, error) {
+			return asyncOutput{}, convAPI.NewError(ctx, http.StatusUnprocessableEntity, convAPI.ErrorCodeBadRequest, "value not supported", nil)
+		}),
+		Block: convAPI.NewAsync(func(ctx convCtx.Context, in asyncInput) (asyncOutput, error) {
+			<-ctx.Done()
+			return asyncOutput{}, ctx.Err()
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	err = srv.EnableAsync(vault, tenant)
+	if err != nil {
+		t.Fatalf("EnableAsync() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	client := convAPI.NewClient[asyncAPI]("localhost", portForAPITest(t))
+
+	t.Run("start_and_wait", func(t *testing.T) {
+		op, err := client.Double.Start(callerCtx, "weekly", asyncInput{Value: 4})
+		if err != nil {
+			t.Fatalf("Start() = %v; want nil", err)
+		}
+		if op.ID == "" || op.State != convAPI.OperationStateRunning {
+			t.Fatalf("Start() = %+v; want running operation with id", op.Operation)
+		}
+
+		out, err := op.Wait(callerCtx)
+		if err != nil {
+			t.Fatalf("Wait() = %v; want nil", err)
+		}
+		if out != (asyncOutput{Result: 8, User: "alice", Report: "weekly"}) {
+			t.Fatalf("Wait() = %+v; want result 8 for alice on weekly", out)
+		}
+		if op.State != convAPI.OperationStateSucceeded || op.Progress != 1 {
+			t.Fatalf("operation = %+v; want succeeded with progress 1", op.Operation)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		op, err := client.Fail.Start(callerCtx, asyncInput{Value: 1})
+		if err != nil {
+			t.Fatalf("Start() = %v; want nil", err)
+		}
+
+		_, err = op.Wait(callerCtx)
+
+		var apiErr *convAPI.Error
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnprocessableEntity {
+			t.Fatalf("Wait() = %v; want 422 error", err)
+		}
+		if op.State != convAPI.OperationStateFailed {
+			t.Fatalf("State = %s; want failed", op.State)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		op, err := client.Block.Start(callerCtx, asyncInput{})
+		if err != nil {
+			t.Fatalf("Start() = %v; want nil", err)
+		}
+
+		err = op.Cancel(callerCtx)
+		if err != nil {
+			t.Fatalf("Cancel() = %v; want nil", err)
+		}
+
+		_, err = op.Wait(callerCtx)
+
+		var apiErr *convAPI.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != convAPI.ErrorCodeConflict {
+			t.Fatalf("Wait() = %v; want conflict error", err)
+		}
+		if op.State != convAPI.OperationStateCancelled {
+			t.Fatalf("State = %s; want cancelled", op.State)
+		}
+	})
+
+	t.Run("other_user", func(t *testing.T) {
+		op, err := client.Double.Start(callerCtx, "daily", asyncInput{Value: 1})
+		if err != nil {
+			t.Fatalf("Start() = %v; want nil", err)
+		}
+
+		bobCtx := convCtx.New(convAuth.Claims{User: "bob", Roles: convAuth.Roles{roleReporter}})
+
+		_, err = client.Double.Operation("daily", op.ID).Status(bobCtx)
+
+		var apiErr *convAPI.Error
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+			t.Fatalf("Status() by other user = %v; want 404", err)
+		}
+
+		_, err = client.Double.Operation("weekly", op.ID).Status(callerCtx)
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+			t.Fatalf("Status() on other path = %v; want 404", err)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		out, err := client.Double.Operation("monthly", orphanID).Wait(callerCtx)
+		if err != nil {
+			t.Fatalf("Wait() = %v; want nil", err)
+		}
+		if out != (asyncOutput{Result: 42, User: "alice", Report: "monthly"}) {
+			t.Fatalf("Wait() = %+v; want resumed result 42 for alice on monthly", out)
+		}
+	})
+}
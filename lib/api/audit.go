@@ -0,0 +1,437 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+)
+
+type AuditRecordID string
+
+// AuditRecord is the durable trace of an authorized call.
+type AuditRecord struct {
+	ID       AuditRecordID    `json:"id"`
+	Time     time.Time        `json:"time"`
+	User     convAuth.User    `json:"user"`
+	Agent    convCtx.Agent    `json:"agent,omitempty"`
+	Tenant   convAuth.Tenant  `json:"tenant,omitempty"` // tenant of the authorization target
+	Entity   convAuth.Entity  `json:"entity,omitempty"` // entity of the authorization target
+	Action   string           `json:"action"`           // method and path template of the endpoint
+	Path     string           `json:"path"`             // requested path
+	Workflow convCtx.Workflow `json:"workflow,omitempty"`
+	Status   int              `json:"status"`
+	ObjectID string           `json:"object_id,omitempty"` // reported by the handler via AuditObject
+	Payload  json.RawMessage  `json:"payload,omitempty"`   // request body, for endpoints opting in
+}
+
+func (x AuditRecord) DBKey() convDB.Key[AuditRecordID, AuditRecordID] {
+	return convDB.Key[AuditRecordID, AuditRecordID]{
+		ID:       x.ID,
+		ShardKey: x.ID,
+	}
+}
+
+// AuditFilter selects audit records; zero fields match all records.
+type AuditFilter struct {
+	User   convAuth.User
+	Entity convAuth.Entity
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int // per shard; defaults to auditDefaultLimit
+}
+
+const (
+	auditDefaultLimit = 1000
+	auditRedacted     = "[redacted]"
+	auditMaxPayload   = 64 << 10
+)
+
+// auditPolicy is read from the `audit` tag of an endpoint:
+//
+//	audit:"off"                                 never audit the endpoint
+//	audit:"on"                                  audit the endpoint even if it does not mutate
+//	audit:"payload,redact=password,redact=a.b"  also record the request body, without the fields
+type auditPolicy struct {
+	on, off bool
+	payload bool
+	redact  []string
+}
+
+func parseAuditTag(tag string) (p auditPolicy) {
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case opt == "on":
+			p.on = true
+		case opt == "off" || opt == "-":
+			p.off = true
+		case opt == "payload":
+			p.payload = true
+		case strings.HasPrefix(opt, "redact="):
+			p.redact = append(p.redact, strings.TrimPrefix(opt, "redact="))
+		}
+	}
+	return
+}
+
+func (p auditPolicy) applies(method string) bool {
+	if p.off {
+		return false
+	}
+	if p.on {
+		return true
+	}
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+type auditObjectKey struct{}
+
+// AuditObject reports the id of the object created or changed by the call
+// running with ctx; it is stored with the audit record of the call.
+func AuditObject(ctx convCtx.Context, id string) {
+	if p, ok := ctx.Value(auditObjectKey{}).(*string); ok {
+		*p = id
+	}
+}
+
+// EnableAudit records every authorized mutating call in the vault, under the
+// tenant of its authorization target or the given tenant when there is none.
+// The redacted fields are removed from all recorded payloads.
+func (srv *server) EnableAudit(vault convDB.Vault, tenant convAuth.Tenant, redact ...string) {
+
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if !ok {
+		return
+	}
+
+	h.auditor = &auditor{
+		records: convDB.NewObjectSet[AuditRecord](vault).Ready(),
+		tenant:  tenant,
+		redact:  redact,
+	}
+
+	for _, ep := range h.eps {
+		if al, ok := ep.(interface{ setAuditor(a *auditor) }); ok {
+			al.setAuditor(h.auditor)
+		}
+	}
+}
+
+type auditor struct {
+	records convDB.ObjectSetReady[AuditRecord, AuditRecordID, AuditRecordID]
+	tenant  convAuth.Tenant
+	redact  []string
+}
+
+func (a *auditor) serve(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, target convAuth.Target, desc descriptor, handle func(ctx convCtx.Context, w http.ResponseWriter, r *http.Request)) {
+
+	rec := AuditRecord{
+		ID:       AuditRecordID(uuid.NewString()),
+		Time:     ctx.Now(),
+		User:     ctx.User(),
+		Agent:    ctx.Agent(),
+		Tenant:   target.Tenant,
+		Entity:   target.Entity,
+		Action:   desc.method + " " + desc.path(),
+		Path:     r.URL.Path,
+		Workflow: ctx.Workflow(),
+	}
+
+	if desc.audit.payload && r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body, err := io.ReadAll(io.LimitReader(r.Body, auditMaxPayload+1))
+		if err == nil {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			if len(body) <= auditMaxPayload {
+				rec.Payload = redactPayload(body, slices.Concat(a.redact, desc.audit.redact))
+			}
+		}
+	}
+
+	var objectID string
+	ctx = convCtx.Context{Context: context.WithValue(ctx, auditObjectKey{}, &objectID)}
+
+	cw := &callWriter{ResponseWriter: w}
+	handle(ctx, cw, r)
+
+	rec.Status = cw.status
+	if rec.Status == 0 {
+		rec.Status = http.StatusOK
+	}
+	rec.ObjectID = objectID
+
+	tenant := target.Tenant
+	if tenant == "" {
+		tenant = a.tenant
+	}
+
+	err := a.records.Tenant(tenant).Insert(ctx, rec)
+	if err != nil {
+		ctx.Logger().Error("unable to store audit record", "action", rec.Action, "path", rec.Path, "error", err)
+	}
+}
+
+func (a *auditor) query(ctx convCtx.Context, tenant convAuth.Tenant, filter AuditFilter) (res []AuditRecord, err error) {
+
+	where := convDB.Where().Noop()
+	if filter.User != "" {
+		where = where.And().Key("user").Equals().Value(filter.User)
+	}
+	if filter.Entity != "" {
+		where = where.And().Key("entity").Equals().Value(filter.Entity)
+	}
+	if filter.Action != "" {
+		where = where.And().Key("action").Equals().Value(filter.Action)
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		to := filter.To
+		if to.IsZero() {
+			to = time.Now().UTC().Add(time.Hour)
+		}
+		where = where.And().CreatedBetween(filter.From.UTC(), to.UTC())
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = auditDefaultLimit
+	}
+
+	res, err = a.records.Tenant(tenant).Select(ctx, where.OrderByCreatedAtDesc().LimitPerShard(limit))
+	if err != nil {
+		return
+	}
+
+	// newest first across shards
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time.After(res[j].Time) })
+
+	if res == nil {
+		res = []AuditRecord{}
+	}
+
+	return
+}
+
+func redactPayload(body []byte, fields []string) json.RawMessage {
+
+	if !json.Valid(body) {
+		return nil
+	}
+
+	if len(fields) == 0 {
+		return json.RawMessage(body)
+	}
+
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+
+	for _, f := range fields {
+		v = redactField(v, strings.Split(f, "."))
+	}
+
+	res, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return res
+}
+
+func redactField(v any, path []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		cur, ok := t[path[0]]
+		if !ok {
+			return v
+		}
+		if len(path) == 1 {
+			t[path[0]] = auditRedacted
+		} else {
+			t[path[0]] = redactField(cur, path[1:])
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactField(t[i], path)
+		}
+	}
+	return v
+}
+
+// NewAuditLog returns the endpoint querying the audit records of a tenant;
+// the endpoint path must have the tenant as its only parameter.
+func NewAuditLog() AuditLog {
+	return AuditLog{}
+}
+
+type AuditLog struct {
+	descriptor descriptor
+	auditor    *auditor
+}
+
+func (x *AuditLog) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	vals, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	if x.auditor == nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "audit is not enabled; call EnableAudit on the server", nil)
+		return true
+	}
+
+	tenant := convAuth.Tenant(vals.GetByIndex(0))
+	if tenant == "" {
+		tenant = x.auditor.tenant
+	}
+
+	filter, err := auditFilterFromQuery(r.URL.Query())
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "invalid audit filter", err)
+		return true
+	}
+
+	res, err := x.auditor.query(ctx, tenant, filter)
+	if err != nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unable to query audit records", err)
+		return true
+	}
+
+	ServeJSON(w, res)
+
+	return true
+}
+
+func (x *AuditLog) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *AuditLog) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *AuditLog) getInOutTypes() (in, out reflect.Type) {
+	return nil, reflect.TypeFor[[]AuditRecord]()
+}
+
+func (x *AuditLog) setEndpoints(eps endpoints) {}
+
+func (x *AuditLog) setAuditor(a *auditor) {
+	x.auditor = a
+}
+
+func auditFilterFromQuery(q url.Values) (filter AuditFilter, err error) {
+
+	filter.User = convAuth.User(q.Get("user"))
+	filter.Entity = convAuth.Entity(q.Get("entity"))
+	filter.Action = q.Get("action")
+
+	if s := q.Get("from"); s != "" {
+		filter.From, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return
+		}
+	}
+
+	if s := q.Get("to"); s != "" {
+		filter.To, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return
+		}
+	}
+
+	if s := q.Get("limit"); s != "" {
+		filter.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (filter AuditFilter) query() url.Values {
+	q := url.Values{}
+	if filter.User != "" {
+		q.Set("user", string(filter.User))
+	}
+	if filter.Entity != "" {
+		q.Set("entity", string(filter.Entity))
+	}
+	if filter.Action != "" {
+		q.Set("action", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		q.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		q.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	return q
+}
+
+// Query returns the audit records of the tenant matching the filter, newest first
+func (x *AuditLog) Query(ctx convCtx.Context, tenant convAuth.Tenant, filter AuditFilter) (res []AuditRecord, err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	var vals values
+	if len(x.descriptor.parameters()) > 0 {
+		vals = values{{Name: "", Value: string(tenant)}}
+	}
+
+	req, err := x.descriptor.newRequest(vals, nil)
+	if err != nil {
+		return
+	}
+	req.URL.RawQuery = filter.query().Encode()
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, resp)
+
+	return
+}
@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type auditAccount struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+}
+
+type auditAPI struct {
+	Create convAPI.InOutP1[auditAccount, auditAccount, convAuth.Tenant] `api:"POST /test/v1/tenants/{tenant}/accounts" audit:"payload,redact=password"`
+	Get    convAPI.OutP1[auditAccount, convAuth.Tenant]                 `api:"GET /test/v1/tenants/{tenant}/account"`
+	Peek   convAPI.OutP1[auditAccount, convAuth.Tenant]                 `api:"GET /test/v1/tenants/{tenant}/peek" audit:"on"`
+	Touch  convAPI.TriggerP1[convAuth.Tenant]                           `api:"PUT /test/v1/tenants/{tenant}/touch" audit:"off"`
+	Audit  convAPI.AuditLog                                             `api:"GET /test/v1/tenants/{tenant}/audit"`
+}
+
+func Test_audit(t *testing.T) {
+
+	const (
+		roleAuditor       convAuth.Role       = "auditor"
+		permissionAuditor convAuth.Permission = "audit"
+	)
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleAuditor: convAuth.Permissions{permissionAuditor},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionAuditor: convAuth.Actions{
+				"POST /test/v1/tenants/{tenant}/accounts",
+				"GET /test/v1/tenants/{tenant}/account",
+				"GET /test/v1/tenants/{tenant}/peek",
+				"PUT /test/v1/tenants/{tenant}/touch",
+				"GET /test/v1/tenants/{tenant}/audit",
+			},
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_audit"})
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &auditAPI{
+		Create: convAPI.NewInOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant, in auditAccount) (auditAccount, error) {
+			in.ID = "acc-" + in.Name
+			convAPI.AuditObject(ctx, in.ID)
+			return in, nil
+		}),
+		Get: convAPI.NewOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant) (auditAccount, error) {
+			return auditAccount{}, nil
+		}),
+		Peek: convAPI.NewOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant) (auditAccount, error) {
+			return auditAccount{}, convAPI.NewError(ctx, http.StatusNotFound, convAPI.ErrorCodeNotFound, "nothing to peek", nil)
+		}),
+		Touch: convAPI.NewTriggerP1(func(ctx convCtx.Context, tenant convAuth.Tenant) error {
+			return nil
+		}),
+		Audit: convAPI.NewAuditLog(),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	srv.EnableAudit("jobs", "test")
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	client := convAPI.NewClient[auditAPI]("localhost", portForAPITest(t))
+
+	aliceCtx := convCtx.New(convAuth.Claims{User: "alice", Tenants: convAuth.Tenants{"test", "other"}, Roles: convAuth.Roles{roleAuditor}})
+	bobCtx := convCtx.New(convAuth.Claims{User: "bob", Tenants: convAuth.Tenants{"test"}, Roles: convAuth.Roles{roleAuditor}})
+
+	_, err = client.Create.Call(aliceCtx, "test", auditAccount{Name: "one", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Create() = %v; want nil", err)
+	}
+	_, err = client.Create.Call(bobCtx, "test", auditAccount{Name: "two", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Create() = %v; want nil", err)
+	}
+	_, err = client.Create.Call(aliceCtx, "other", auditAccount{Name: "three"})
+	if err != nil {
+		t.Fatalf("Create() = %v; want nil", err)
+	}
+	_, _ = client.Get.Call(aliceCtx, "test")
+	_, _ = client.Peek.Call(aliceCtx, "test")
+	_ = client.Touch.Call(aliceCtx, "test")
+
+	t.Run("all", func(t *testing.T) {
+		recs, err := client.Audit.Query(aliceCtx, "test", convAPI.AuditFilter{})
+		if err != nil {
+			t.Fatalf("Query() = %v; want nil", err)
+		}
+
+		// creates of alice and bob and the opted-in peek; not get, touch or the other tenant
+		if len(recs) != 3 {
+			t.Fatalf("Query() = %d records; want 3: %+v", len(recs), recs)
+		}
+	})
+
+	t.Run("by_user_and_action", func(t *testing.T) {
+		recs, err := client.Audit.Query(aliceCtx, "test", convAPI.AuditFilter{
+			User:   "alice",
+			Action: "POST /test/v1/tenants/{tenant}/accounts",
+		})
+		if err != nil {
+			t.Fatalf("Query() = %v; want nil", err)
+		}
+		if len(recs) != 1 {
+			t.Fatalf("Query() = %d records; want 1: %+v", len(recs), recs)
+		}
+
+		rec := recs[0]
+		if rec.Tenant != "test" || rec.Status != http.StatusOK || rec.ObjectID != "acc-one" || rec.Path != "/test/v1/tenants/test/accounts" {
+			t.Fatalf("record = %+v; want alice's create of acc-one", rec)
+		}
+
+		var payload map[string]string
+		err = json.Unmarshal(rec.Payload, &payload)
+		if err != nil || payload["name"] != "one" || payload["password"] != "[redacted]" {
+			t.Fatalf("payload = %s; want name with redacted password", rec.Payload)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		recs, err := client.Audit.Query(aliceCtx, "test", convAPI.AuditFilter{Action: "GET /test/v1/tenants/{tenant}/peek"})
+		if err != nil {
+			t.Fatalf("Query() = %v; want nil", err)
+		}
+		if len(recs) != 1 || recs[0].Status != http.StatusNotFound || recs[0].Payload != nil {
+			t.Fatalf("Query() = %+v; want one 404 peek without payload", recs)
+		}
+	})
+
+	t.Run("time_range", func(t *testing.T) {
+		recs, err := client.Audit.Query(aliceCtx, "test", convAPI.AuditFilter{From: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Query() = %v; want nil", err)
+		}
+		if len(recs) != 0 {
+			t.Fatalf("Query() in the future = %d records; want 0", len(recs))
+		}
+
+		recs, err = client.Audit.Query(aliceCtx, "other", convAPI.AuditFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Query() = %v; want nil", err)
+		}
+		if len(recs) != 1 || recs[0].Tenant != "other" {
+			t.Fatalf("Query() of other tenant = %+v; want the one create", recs)
+		}
+	})
+
+	t.Run("other_tenant_forbidden", func(t *testing.T) {
+		_, err := client.Audit.Query(bobCtx, "other", convAPI.AuditFilter{})
+		if err == nil {
+			t.Fatal("Query() of a foreign tenant = nil; want error")
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type LoadBalancing string
+
+const (
+	LoadBalancingRoundRobin       LoadBalancing = "round_robin"
+	LoadBalancingLeastOutstanding LoadBalancing = "least_outstanding"
+)
+
+// balancerEjectFor is how long an instance failing to connect is skipped
+var balancerEjectFor = 30 * time.Second
+
+// WithResolver spreads the calls over the instances returned by the resolver
+// instead of the host and port of the client. TLS certificates are still
+// verified against the host of the client.
+func WithResolver(resolver Resolver) ClientOption {
+	return func(c *clientConfig) { c.resolver = resolver }
+}
+
+// WithLoadBalancing selects how calls are spread over the resolved instances;
+// round robin by default.
+func WithLoadBalancing(balancing LoadBalancing) ClientOption {
+	return func(c *clientConfig) { c.balancing = balancing }
+}
+
+// WithRetries retries idempotent calls (GET, HEAD, PUT, DELETE) failing to
+// connect or answered with 502 or 503 up to retries times, each time on
+// another resolved instance.
+func WithRetries(retries int) ClientOption {
+	return func(c *clientConfig) { c.retries = retries }
+}
+
+// balancer spreads the calls of a client over the resolved instances, and
+// skips the instances failing to connect for a while
+type balancer struct {
+	resolver  Resolver
+	balancing LoadBalancing
+	retries   int
+	client    *http.Client
+
+	mu          sync.Mutex
+	next        int
+	outstanding map[Instance]int
+	ejected     map[Instance]time.Time
+}
+
+func newBalancer(cfg clientConfig, host string) *balancer {
+
+	if cfg.resolver == nil {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{ServerName: host}
+
+	return &balancer{
+		resolver:    cfg.resolver,
+		balancing:   cfg.balancing,
+		retries:     cfg.retries,
+		client:      &http.Client{Transport: transport},
+		outstanding: map[Instance]int{},
+		ejected:     map[Instance]time.Time{},
+	}
+}
+
+func (b *balancer) send(ctx convCtx.Context, req *http.Request, g *clientGuard, client doer) (res *http.Response, err error) {
+
+	if client == nil {
+		client = b.client
+	}
+
+	instances, err := b.resolver.Resolve(ctx)
+	if err == nil && len(instances) == 0 {
+		err = ErrNoInstances
+	}
+	if err != nil {
+		apiErr := newError(ctx, http.StatusServiceUnavailable, ErrorCodeUnavailable, "unable to resolve "+req.URL.Host, err)
+		apiErr.Method = req.Method
+		apiErr.URL = req.URL.Path
+		return nil, apiErr
+	}
+
+	attempts := 1
+	if isIdempotent(req.Method) && (req.Body == nil || req.GetBody != nil) {
+		attempts += b.retries
+	}
+
+	tried := map[Instance]bool{}
+
+	for attempt := 0; attempt < attempts; attempt++ {
+
+		inst, ok := b.pick(instances, tried)
+		if !ok {
+			break
+		}
+		tried[inst] = true
+
+		if res != nil {
+			res.Body.Close() // answered with 502 or 503; retried on another instance
+			res = nil
+		}
+
+		r := req.Clone(req.Context())
+		r.URL.Host = inst.String()
+		r.Host = req.URL.Host
+		if attempt > 0 && req.GetBody != nil {
+			r.Body, err = req.GetBody()
+			if err != nil {
+				return
+			}
+		}
+
+		b.begin(inst)
+		res, err = g.do(ctx, client, r)
+		b.end(inst)
+
+		var apiErr *Error
+		switch {
+		case err == nil && res.StatusCode != http.StatusBadGateway && res.StatusCode != http.StatusServiceUnavailable:
+			return
+		case err == nil:
+			continue
+		case errors.As(err, &apiErr), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return // not sent, or given up by the caller
+		default:
+			b.eject(ctx, inst, err)
+		}
+	}
+
+	return
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// pick returns the next instance not tried yet, skipping the ejected ones
+// unless all are ejected
+func (b *balancer) pick(instances []Instance, tried map[Instance]bool) (inst Instance, ok bool) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var candidates []Instance
+	for _, i := range instances {
+		if tried[i] {
+			continue
+		}
+		if until, ejected := b.ejected[i]; ejected {
+			if now.Before(until) {
+				continue
+			}
+			delete(b.ejected, i)
+		}
+		candidates = append(candidates, i)
+	}
+
+	if len(candidates) == 0 {
+		for _, i := range instances {
+			if !tried[i] {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	start := b.next % len(candidates)
+	b.next++
+
+	inst, ok = candidates[start], true
+
+	if b.balancing == LoadBalancingLeastOutstanding {
+		for j := 1; j < len(candidates); j++ {
+			c := candidates[(start+j)%len(candidates)]
+			if b.outstanding[c] < b.outstanding[inst] {
+				inst = c
+			}
+		}
+	}
+
+	return
+}
+
+func (b *balancer) begin(inst Instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outstanding[inst]++
+}
+
+func (b *balancer) end(inst Instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outstanding[inst]--
+	if b.outstanding[inst] <= 0 {
+		delete(b.outstanding, inst)
+	}
+}
+
+func (b *balancer) eject(ctx convCtx.Context, inst Instance, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ejected := b.ejected[inst]; !ejected {
+		ctx.Logger().Warn("instance ejected", "instance", inst.String(), "for", balancerEjectFor.String(), "error", err.Error())
+	}
+	b.ejected[inst] = time.Now().Add(balancerEjectFor)
+}
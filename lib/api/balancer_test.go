@@ -0,0 +1,210 @@
+package api_test
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type balancerAPI struct {
+	Name   convAPI.Out[string]                     `api:"GET /test/v1/name"`
+	Hold   convAPI.Out[string]                     `api:"GET /test/v1/hold"`
+	Create convAPI.InOut[string, string]           `api:"POST /test/v1/names"`
+	Echo   convAPI.InOutP1[string, string, string] `api:"PUT /test/v1/echo/{id}"`
+}
+
+// holdResult is the outcome of a held call
+type holdResult struct {
+	res string
+	err error
+}
+
+func Test_balancer(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/name",
+			"GET /test/v1/hold",
+			"POST /test/v1/names",
+			"PUT /test/v1/echo/{any}",
+		},
+	}
+
+	hold := make(chan struct{})
+	held := make(chan struct{})
+
+	instances := map[string]convAPI.Instance{}
+
+	for _, name := range []string{"a", "b"} {
+
+		port := portForAPITestAgent(t, name)
+		instances[name] = convAPI.Instance{Host: "127.0.0.1", Port: port}
+
+		agentCtx := convCtx.New(convAuth.Claims{User: convAuth.User("Test_balancer_" + name)})
+
+		srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &balancerAPI{
+			Name: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+				return name, nil
+			}),
+			Hold: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+				select {
+				case held <- struct{}{}:
+				case <-hold: // released without the test waiting, e.g. after it failed
+				}
+				<-hold
+				return name, nil
+			}),
+			Create: convAPI.NewInOut(func(ctx convCtx.Context, in string) (string, error) {
+				return name, nil
+			}),
+			Echo: convAPI.NewInOutP1(func(ctx convCtx.Context, id string, in string) (string, error) {
+				return name + ":" + id + ":" + in, nil
+			}),
+		})
+		if err != nil {
+			t.Fatalf("NewServer() = %v; want nil", err)
+		}
+
+		go srv.ListenAndServe()
+		defer srv.Shutdown(agentCtx)
+	}
+
+	down := convAPI.Instance{Host: "127.0.0.1", Port: portForAPITestAgent(t, "down")} // nothing listening
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent apis to start
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1"})
+
+	t.Run("round_robin", func(t *testing.T) {
+		client := convAPI.NewClient[balancerAPI]("localhost", 0,
+			convAPI.WithResolver(convAPI.NewStaticResolver(instances["a"], instances["b"])),
+		)
+
+		counts := map[string]int{}
+		for range 4 {
+			res, err := client.Name.Call(callerCtx)
+			if err != nil {
+				t.Fatalf("Name() = %v; want nil", err)
+			}
+			counts[res]++
+		}
+
+		if counts["a"] != 2 || counts["b"] != 2 {
+			t.Fatalf("calls per instance = %v; want 2 each", counts)
+		}
+	})
+
+	t.Run("least_outstanding", func(t *testing.T) {
+		client := convAPI.NewClient[balancerAPI]("localhost", 0,
+			convAPI.WithResolver(convAPI.NewStaticResolver(instances["a"], instances["b"])),
+			convAPI.WithLoadBalancing(convAPI.LoadBalancingLeastOutstanding),
+		)
+
+		release := sync.OnceFunc(func() { close(hold) })
+		defer release() // never leave the instance holding, or Shutdown waits for it
+
+		done := make(chan holdResult, 1)
+		go func() {
+			res, err := client.Hold.Call(callerCtx)
+			done <- holdResult{res, err}
+		}()
+
+		select {
+		case <-held: // one of the instances is holding a call
+		case r := <-done:
+			t.Fatalf("Hold() = %q, %v; want it held", r.res, r.err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Hold() was not held by any instance")
+		}
+
+		seen := map[string]int{}
+		for range 3 {
+			res, err := client.Name.Call(callerCtx)
+			if err != nil {
+				t.Fatalf("Name() = %v; want nil", err)
+			}
+			seen[res]++
+		}
+
+		release()
+
+		var busy string
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatalf("Hold() = %v; want nil", r.err)
+			}
+			busy = r.res
+		case <-time.After(5 * time.Second):
+			t.Fatal("Hold() did not return once released")
+		}
+
+		if len(seen) != 1 || seen[busy] != 0 {
+			t.Fatalf("calls per instance = %v while %s was holding a call; want all on the other", seen, busy)
+		}
+	})
+
+	t.Run("ejection_and_retry", func(t *testing.T) {
+		client := convAPI.NewClient[balancerAPI]("localhost", 0,
+			convAPI.WithResolver(convAPI.NewStaticResolver(down, instances["a"])),
+			convAPI.WithRetries(1),
+		)
+
+		for range 4 {
+			res, err := client.Echo.Call(callerCtx, "x", "y")
+			if err != nil || res != "a:x:y" {
+				t.Fatalf("Echo() = %q, %v; want a:x:y retried on the running instance", res, err)
+			}
+		}
+	})
+
+	t.Run("no_retry_of_post", func(t *testing.T) {
+		client := convAPI.NewClient[balancerAPI]("localhost", 0,
+			convAPI.WithResolver(convAPI.NewStaticResolver(down)),
+			convAPI.WithRetries(3),
+		)
+
+		_, err := client.Create.Call(callerCtx, "x")
+		if err == nil {
+			t.Fatal("Create() on a down instance = nil; want error")
+		}
+	})
+
+	t.Run("config_reload", func(t *testing.T) {
+		const key convCfg.ConfigKey = "test_balancer_instances"
+
+		write := func(inst convAPI.Instance, mod time.Time) {
+			data, _ := json.Marshal([]string{inst.String()})
+			err := os.WriteFile(convCfg.FilePath(key), data, 0o600)
+			if err != nil {
+				t.Fatalf("WriteFile() = %v; want nil", err)
+			}
+			os.Chtimes(convCfg.FilePath(key), mod, mod)
+		}
+		defer os.Remove(convCfg.FilePath(key))
+
+		write(instances["a"], time.Now().Add(-time.Minute))
+
+		client := convAPI.NewClient[balancerAPI]("localhost", 0, convAPI.WithResolver(convAPI.NewConfigResolver(key)))
+
+		res, err := client.Name.Call(callerCtx)
+		if err != nil || res != "a" {
+			t.Fatalf("Name() = %q, %v; want a", res, err)
+		}
+
+		write(instances["b"], time.Now())
+		time.Sleep(1100 * time.Millisecond) // the file is checked at most once a second
+
+		res, err = client.Name.Call(callerCtx)
+		if err != nil || res != "b" {
+			t.Fatalf("Name() after reload = %q, %v; want b", res, err)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+const batchMaxItems = 100
+
+type batchContextKey struct{}
+
+// BatchRequest is a single sub-request of a batch; Path may include a query.
+type BatchRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the outcome of the sub-request at the same index.
+// Body holds the JSON response, or a JSON string for non JSON responses.
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// NewBatch creates an endpoint executing a list of sub-requests in one round trip.
+// Each sub-request is dispatched through the server handler, so it passes the
+// same authorization, claims decoding and endpoint matching as a direct call.
+func NewBatch() Batch {
+	return Batch{}
+}
+
+// WithParallel executes up to concurrency sub-requests at the same time
+// instead of one after another.
+func (x Batch) WithParallel(concurrency int) Batch {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	x.concurrency = concurrency
+	return x
+}
+
+// WithFailFast stops at the first sub-request with status 400 or above;
+// sub-requests that were not started get status 424 (Failed Dependency).
+func (x Batch) WithFailFast() Batch {
+	x.failFast = true
+	return x
+}
+
+type Batch struct {
+	descriptor  descriptor
+	handler     http.Handler
+	concurrency int
+	failFast    bool
+}
+
+func (x *Batch) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	_, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	if r.Context().Value(batchContextKey{}) != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "nested batch requests are not supported", nil)
+		return true
+	}
+
+	if x.handler == nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "batch endpoint is not attached to a server handler", nil)
+		return true
+	}
+
+	var reqs []BatchRequest
+	err := json.NewDecoder(r.Body).Decode(&reqs)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to decode http payload", err)
+		return true
+	}
+
+	if len(reqs) > batchMaxItems {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "too many batch items", nil)
+		return true
+	}
+
+	res := x.execute(ctx, r, reqs)
+
+	err = ServeJSON(w, res)
+	if err != nil {
+		ctx.Logger().Error("unable to encode batch response", "error", err)
+	}
+
+	return true
+}
+
+func (x *Batch) execute(ctx convCtx.Context, r *http.Request, reqs []BatchRequest) (res []BatchResult) {
+
+	res = make([]BatchResult, len(reqs))
+
+	c, cancel := context.WithCancel(context.WithValue(r.Context(), batchContextKey{}, true))
+	defer cancel()
+
+	concurrency := x.concurrency
+	if concurrency < 1 {
+		concurrency = 1 // sequential
+	}
+
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for i, req := range reqs {
+
+		slots <- struct{}{}
+
+		if c.Err() != nil { // fail-fast triggered
+			<-slots
+			res[i] = BatchResult{Status: http.StatusFailedDependency}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			res[i] = x.executeOne(ctx, c, r, req)
+
+			if x.failFast && res[i].Status >= http.StatusBadRequest {
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return
+}
+
+func (x *Batch) executeOne(ctx convCtx.Context, c context.Context, r *http.Request, br BatchRequest) (res BatchResult) {
+
+	if br.Method == "" || !strings.HasPrefix(br.Path, "/") {
+		return batchErrorResult(ctx, http.StatusBadRequest, "batch item requires method and absolute path")
+	}
+
+	req, err := http.NewRequestWithContext(c, br.Method, br.Path, bytes.NewReader(br.Body))
+	if err != nil {
+		return batchErrorResult(ctx, http.StatusBadRequest, "invalid batch item: "+err.Error())
+	}
+
+	// share the identity and workflow of the batch with every sub-request
+	for _, h := range []string{httpHeaderAuthorization, httpHeaderAgent, convCtx.HTTPHeaderTimeNow} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	req.Header.Set(convCtx.HttpHeaderWorkflow, string(ctx.Workflow()))
+	if budget, ok := timeBudget(ctx); ok {
+		req.Header.Set(convCtx.HttpHeaderTimeBudget, budget)
+	}
+	if len(br.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RemoteAddr = r.RemoteAddr
+
+	rec := httptest.NewRecorder()
+	x.handler.ServeHTTP(rec, req)
+
+	res.Status = rec.Code
+
+	body := bytes.TrimSpace(rec.Body.Bytes())
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		res.Body = body
+	default:
+		res.Body, _ = json.Marshal(string(body))
+	}
+
+	return
+}
+
+func batchErrorResult(ctx convCtx.Context, status int, message string) (res BatchResult) {
+	res.Status = status
+	res.Body, _ = json.Marshal(newError(ctx, status, ErrorCodeBadRequest, message, nil))
+	return
+}
+
+func (x *Batch) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *Batch) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *Batch) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[[]BatchRequest](), reflect.TypeFor[[]BatchResult]()
+}
+
+func (x *Batch) setEndpoints(eps endpoints) {}
+
+func (x *Batch) setHandler(h http.Handler) {
+	x.handler = h
+}
+
+func (x *Batch) Call(ctx convCtx.Context, reqs []BatchRequest) (res []BatchResult, err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return
+	}
+
+	req, err := x.descriptor.newRequest(nil, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, resp)
+
+	return
+}
@@ -0,0 +1,164 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type batchAPI struct {
+	GetWorkflow   convAPI.OutP1[string, string] `api:"GET /test/v1/workflows/{item}"`
+	PutItem       convAPI.InP1[string, string]  `api:"PUT /test/v1/items/{item}"`
+	Batch         convAPI.Batch                 `api:"POST /test/v1/batch"`
+	BatchFailFast convAPI.Batch                 `api:"POST /test/v1/batch/fail-fast"`
+	BatchParallel convAPI.Batch                 `api:"POST /test/v1/batch/parallel"`
+}
+
+func Test_batch(t *testing.T) {
+
+	const (
+		roleReader       convAuth.Role       = "reader"
+		permissionReader convAuth.Permission = "reader"
+	)
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleReader: convAuth.Permissions{permissionReader},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionReader: convAuth.Actions{
+				"GET /test/v1/workflows/{any}",
+			},
+		},
+		Public: convAuth.Actions{
+			"POST /test/v1/batch",
+			"POST /test/v1/batch/{any}",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_batch"})
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &batchAPI{
+		GetWorkflow: convAPI.NewOutP1(func(ctx convCtx.Context, item string) (string, error) {
+			if item == "missing" {
+				return "", convAPI.NewError(ctx, http.StatusNotFound, convAPI.ErrorCodeNotFound, "item not found", nil)
+			}
+			return string(ctx.Workflow()), nil
+		}),
+		PutItem: convAPI.NewInP1(func(ctx convCtx.Context, item string, in string) error {
+			return errors.New("not reachable; forbidden by policy")
+		}),
+		Batch:         convAPI.NewBatch(),
+		BatchFailFast: convAPI.NewBatch().WithFailFast(),
+		BatchParallel: convAPI.NewBatch().WithParallel(4),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	client := convAPI.NewClient[batchAPI]("localhost", portForAPITest(t))
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1", Roles: convAuth.Roles{roleReader}})
+
+	reqs := []convAPI.BatchRequest{
+		{Method: http.MethodGet, Path: "/test/v1/workflows/a"},
+		{Method: http.MethodGet, Path: "/test/v1/workflows/missing"},
+		{Method: http.MethodPut, Path: "/test/v1/items/a", Body: json.RawMessage(`"x"`)},
+		{Method: http.MethodGet, Path: "/test/v1/workflows/b"},
+	}
+
+	statuses := func(res []convAPI.BatchResult) (s []int) {
+		for _, r := range res {
+			s = append(s, r.Status)
+		}
+		return
+	}
+
+	equal := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		res, err := client.Batch.Call(callerCtx, reqs)
+		if err != nil {
+			t.Fatalf("Call() = %v; want nil", err)
+		}
+
+		want := []int{http.StatusOK, http.StatusNotFound, http.StatusForbidden, http.StatusOK}
+		if got := statuses(res); !equal(got, want) {
+			t.Fatalf("statuses = %v; want %v", got, want)
+		}
+
+		// workflow of the caller is shared with every sub-request
+		for _, i := range []int{0, 3} {
+			var wf string
+			if err := json.Unmarshal(res[i].Body, &wf); err != nil {
+				t.Fatalf("Unmarshal(res[%d].Body) = %v; want nil", i, err)
+			}
+			if wf != string(callerCtx.Workflow()) {
+				t.Errorf("res[%d] workflow = %q; want %q", i, wf, callerCtx.Workflow())
+			}
+		}
+
+		var apiErr convAPI.Error
+		if err := json.Unmarshal(res[1].Body, &apiErr); err != nil || apiErr.Code != convAPI.ErrorCodeNotFound {
+			t.Errorf("res[1].Body = %s; want not_found error", res[1].Body)
+		}
+	})
+
+	t.Run("fail_fast", func(t *testing.T) {
+		res, err := client.BatchFailFast.Call(callerCtx, reqs)
+		if err != nil {
+			t.Fatalf("Call() = %v; want nil", err)
+		}
+
+		want := []int{http.StatusOK, http.StatusNotFound, http.StatusFailedDependency, http.StatusFailedDependency}
+		if got := statuses(res); !equal(got, want) {
+			t.Fatalf("statuses = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		res, err := client.BatchParallel.Call(callerCtx, reqs)
+		if err != nil {
+			t.Fatalf("Call() = %v; want nil", err)
+		}
+
+		want := []int{http.StatusOK, http.StatusNotFound, http.StatusForbidden, http.StatusOK}
+		if got := statuses(res); !equal(got, want) {
+			t.Fatalf("statuses = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		res, err := client.Batch.Call(callerCtx, []convAPI.BatchRequest{
+			{Method: http.MethodPost, Path: "/test/v1/batch/parallel", Body: json.RawMessage(`[]`)},
+		})
+		if err != nil {
+			t.Fatalf("Call() = %v; want nil", err)
+		}
+
+		if got := statuses(res); !equal(got, []int{http.StatusBadRequest}) {
+			t.Fatalf("statuses = %v; want [400]", got)
+		}
+	})
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// The Params endpoints (TriggerParams, InParams, OutParams and InOutParams)
+// bind the path, query and header values of a call by name to the fields of a
+// params struct:
+//
+//	type ListMessagesParams struct {
+//		Tenant convAuth.Tenant `path:"tenant"`
+//		Limit  int             `query:"limit"`
+//		Before *time.Time      `query:"before"`  // nil when absent
+//		Trace  string          `header:"X-Trace"`
+//	}
+//
+// Fields can be of the types supported for path parameters, or pointers to
+// them for optional query and header values.
+
+const (
+	bindSourcePath   = "path"
+	bindSourceQuery  = "query"
+	bindSourceHeader = "header"
+)
+
+var bindSources = []string{bindSourcePath, bindSourceQuery, bindSourceHeader}
+
+type bindField struct {
+	index  []int
+	source string
+	name   string
+	typ    reflect.Type
+}
+
+// valueType is the type of the parsed value; of the element for pointers
+func (f bindField) valueType() reflect.Type {
+	if f.typ.Kind() == reflect.Pointer {
+		return f.typ.Elem()
+	}
+	return f.typ
+}
+
+type binding struct {
+	typ    reflect.Type
+	fields []bindField
+}
+
+func newBinding(t reflect.Type) *binding {
+
+	b := &binding{typ: t}
+
+	if t.Kind() != reflect.Struct {
+		return b // reported by check
+	}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		for _, source := range bindSources {
+			name := f.Tag.Get(source)
+			if name != "" {
+				b.fields = append(b.fields, bindField{index: f.Index, source: source, name: name, typ: f.Type})
+				break
+			}
+		}
+	}
+
+	return b
+}
+
+// bindEndpoint sets the binding of the params struct of Params endpoints
+func (desc *descriptor) bindEndpoint(ep endpoint) {
+	pt, ok := ep.(interface{ getParamsType() reflect.Type })
+	if !ok {
+		return
+	}
+	desc.binding = newBinding(pt.getParamsType())
+	desc.params = desc.binding.pathTypes(desc)
+	desc.query = desc.binding.queryParams(desc.query)
+}
+
+func (b *binding) field(source, name string) (bindField, bool) {
+	for _, f := range b.fields {
+		if f.source == source && f.name == name {
+			return f, true
+		}
+	}
+	return bindField{}, false
+}
+
+// check fails when the params struct does not fit the path of the endpoint
+func (b *binding) check(desc *descriptor) error {
+
+	if b.typ.Kind() != reflect.Struct {
+		return fmt.Errorf("params of type %s are not a struct", b.typ)
+	}
+
+	params := desc.parameters()
+
+	for _, f := range b.fields {
+		if !paramSupported(f.valueType()) {
+			return fmt.Errorf("%s parameter '%s' of type %s: %w", f.source, f.name, f.typ, errUnsupportedParam)
+		}
+		if f.source == bindSourcePath && !slices.Contains(params, f.name) {
+			return fmt.Errorf("path parameter '%s' is not in the path", f.name)
+		}
+	}
+
+	for _, p := range params {
+		if _, ok := b.field(bindSourcePath, p); !ok {
+			return fmt.Errorf("path parameter '%s' is bound to no field of %s", p, b.typ)
+		}
+	}
+
+	return nil
+}
+
+// pathTypes returns the types of the path parameters in the order of the path
+func (b *binding) pathTypes(desc *descriptor) (types []reflect.Type) {
+	for _, p := range desc.parameters() {
+		f, ok := b.field(bindSourcePath, p)
+		if !ok {
+			types = append(types, reflect.TypeFor[string]()) // reported by check
+			continue
+		}
+		types = append(types, f.valueType())
+	}
+	return
+}
+
+// queryParams adds the query fields not documented in the api tag
+func (b *binding) queryParams(query []queryParam) []queryParam {
+	for _, f := range b.fields {
+		if f.source != bindSourceQuery {
+			continue
+		}
+		documented := slices.ContainsFunc(query, func(q queryParam) bool { return q.Name == f.name })
+		if documented {
+			continue
+		}
+		typ, _ := paramSchema(f.valueType())
+		query = append(query, queryParam{Name: f.name, Type: typ})
+	}
+	return query
+}
+
+// bindParams sets the fields of ptr (a pointer to the params struct) from the
+// request, serving bad request when a value does not parse. Absent query and
+// header values leave their fields zero (nil for pointers).
+func (desc *descriptor) bindParams(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, vals values, ptr any) bool {
+
+	v := reflect.ValueOf(ptr).Elem()
+	query := r.URL.Query()
+
+	for _, f := range desc.binding.fields {
+
+		var (
+			s       string
+			present bool
+		)
+		switch f.source {
+		case bindSourcePath:
+			s, present = vals.GetByKey(f.name), vals.Has(f.name)
+		case bindSourceQuery:
+			s, present = query.Get(f.name), query.Has(f.name)
+		case bindSourceHeader:
+			s, present = r.Header.Get(f.name), len(r.Header.Values(f.name)) > 0
+		}
+		if !present {
+			continue
+		}
+
+		fv := v.FieldByIndex(f.index)
+		if f.typ.Kind() == reflect.Pointer {
+			fv.Set(reflect.New(f.typ.Elem()))
+			fv = fv.Elem()
+		}
+
+		err := parseParam(s, fv.Addr().Interface())
+		if err != nil {
+			ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("invalid %s parameter '%s'", f.source, f.name), err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// newBoundRequest creates the request of a call with the values of the fields
+// of params; zero query and header fields are not sent.
+func (desc *descriptor) newBoundRequest(params any, body io.Reader) (req *http.Request, err error) {
+
+	v := reflect.ValueOf(params)
+
+	format := func(f bindField) (s string, ok bool, err error) {
+		fv := v.FieldByIndex(f.index)
+		if fv.IsZero() && f.source != bindSourcePath {
+			return
+		}
+		if f.typ.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return // a missing path value is reported by newRequest
+			}
+			fv = fv.Elem()
+		}
+		s, err = formatParam(fv.Interface())
+		return s, err == nil, err
+	}
+
+	var vals values
+	for _, p := range desc.parameters() {
+		f, ok := desc.binding.field(bindSourcePath, p)
+		if !ok {
+			return nil, fmt.Errorf("path parameter '%s' is bound to no field of %s", p, desc.binding.typ)
+		}
+		s, _, err := format(f)
+		if err != nil {
+			return nil, err
+		}
+		vals.Add(p, s)
+	}
+
+	req, err = desc.newRequest(vals, body)
+	if err != nil {
+		return
+	}
+
+	query := url.Values{}
+	for _, f := range desc.binding.fields {
+		if f.source == bindSourcePath {
+			continue
+		}
+		s, ok, err := format(f)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if f.source == bindSourceQuery {
+			query.Set(f.name, s)
+		} else {
+			req.Header.Set(f.name, s)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	return
+}
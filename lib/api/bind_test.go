@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type bindParams struct {
+	Item   uuid.UUID       `path:"item"` // declared before tenant on purpose; bound by name
+	Tenant convAuth.Tenant `path:"tenant"`
+	Limit  int             `query:"limit"`
+	Before *int64          `query:"before"`
+	Trace  string          `header:"X-Trace"`
+}
+
+type bindLooseParams struct {
+	Tenant string `path:"tenant"`
+	Item   string `path:"item"`
+	Limit  string `query:"limit"`
+}
+
+type bindAPI struct {
+	Get convAPI.OutParams[string, bindParams]           `api:"GET /test/v1/tenants/{tenant}/items/{item}"`
+	Put convAPI.InOutParams[string, string, bindParams] `api:"PUT /test/v1/tenants/{tenant}/items/{item}"`
+	Del convAPI.TriggerParams[bindParams]               `api:"DELETE /test/v1/tenants/{tenant}/items/{item}"`
+	Set convAPI.InParams[string, bindParams]            `api:"POST /test/v1/tenants/{tenant}/items/{item}"`
+}
+
+type bindLooseAPI struct {
+	Get convAPI.OutParams[string, bindLooseParams] `api:"GET /test/v1/tenants/{tenant}/items/{item}"`
+}
+
+func Test_bind(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/tenants/{any}/items/{any}",
+			"PUT /test/v1/tenants/{any}/items/{any}",
+			"DELETE /test/v1/tenants/{any}/items/{any}",
+			"POST /test/v1/tenants/{any}/items/{any}",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_bind"})
+
+	port := portForAPITest(t)
+
+	describe := func(p bindParams) string {
+		before := "-"
+		if p.Before != nil {
+			before = fmt.Sprint(*p.Before)
+		}
+		return fmt.Sprintf("%s/%s/%d/%s/%s", p.Tenant, p.Item, p.Limit, before, p.Trace)
+	}
+
+	deleted := make(chan bindParams, 1)
+	set := make(chan string, 1)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &bindAPI{
+		Get: convAPI.NewOutParams(func(ctx convCtx.Context, p bindParams) (string, error) {
+			return describe(p), nil
+		}),
+		Put: convAPI.NewInOutParams(func(ctx convCtx.Context, p bindParams, in string) (string, error) {
+			return describe(p) + ":" + in, nil
+		}),
+		Del: convAPI.NewTriggerParams(func(ctx convCtx.Context, p bindParams) error {
+			deleted <- p
+			return nil
+		}),
+		Set: convAPI.NewInParams(func(ctx convCtx.Context, p bindParams, in string) error {
+			set <- describe(p) + ":" + in
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1"})
+
+	client := convAPI.NewClient[bindAPI]("localhost", port)
+
+	item := uuid.MustParse("6f1c2c5e-8d0b-4e4f-9a43-2b6c1f0a9d11")
+	before := int64(1700000000)
+
+	t.Run("by_name", func(t *testing.T) {
+		res, err := client.Get.Call(callerCtx, bindParams{Tenant: "a", Item: item, Limit: 10, Before: &before, Trace: "t1"})
+		want := "a/" + item.String() + "/10/1700000000/t1"
+		if err != nil || res != want {
+			t.Fatalf("Get() = %q, %v; want %q", res, err, want)
+		}
+	})
+
+	t.Run("absent_optional", func(t *testing.T) {
+		res, err := client.Put.Call(callerCtx, bindParams{Tenant: "a", Item: item}, "x")
+		want := "a/" + item.String() + "/0/-/:x"
+		if err != nil || res != want {
+			t.Fatalf("Put() = %q, %v; want %q", res, err, want)
+		}
+	})
+
+	t.Run("trigger_and_in", func(t *testing.T) {
+		err := client.Del.Call(callerCtx, bindParams{Tenant: "b", Item: item})
+		if err != nil {
+			t.Fatalf("Del() = %v; want nil", err)
+		}
+		if p := <-deleted; p.Tenant != "b" || p.Item != item {
+			t.Fatalf("Del() bound %+v; want tenant b and item %s", p, item)
+		}
+
+		err = client.Set.Call(callerCtx, bindParams{Tenant: "b", Item: item, Limit: 3}, "y")
+		if err != nil {
+			t.Fatalf("Set() = %v; want nil", err)
+		}
+		if got, want := <-set, "b/"+item.String()+"/3/-/:y"; got != want {
+			t.Fatalf("Set() bound %q; want %q", got, want)
+		}
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		loose := convAPI.NewClient[bindLooseAPI]("localhost", port)
+
+		for _, p := range []bindLooseParams{
+			{Tenant: "a", Item: "not-a-uuid"},
+			{Tenant: "a", Item: item.String(), Limit: "ten"},
+		} {
+			_, err := loose.Get.Call(callerCtx, p)
+			if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeBadRequest) {
+				t.Fatalf("Get(%+v) = %v; want bad request", p, err)
+			}
+		}
+	})
+
+	t.Run("unbound_path_parameter", func(t *testing.T) {
+		_, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &struct {
+			Get convAPI.OutParams[string, bindLooseParams] `api:"GET /test/v1/tenants/{tenant}/users/{user}"`
+		}{})
+		if err == nil {
+			t.Fatal("NewServer() with an unbound path parameter = nil; want error")
+		}
+	})
+}
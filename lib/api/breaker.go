@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// ClientOption configures a client created with NewClient.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	breaker     *CircuitBreaker
+	perEndpoint bool
+	bulkhead    int
+	resolver    Resolver
+	balancing   LoadBalancing
+	retries     int
+}
+
+// CircuitBreaker stops the calls to a failing target for a while; zero fields
+// take the defaults.
+type CircuitBreaker struct {
+	Failures       int           // consecutive failures opening the circuit; default 5
+	OpenFor        time.Duration // time before probing an open circuit; default 30s
+	HalfOpenProbes int           // concurrent probe calls of a half-open circuit; default 1
+}
+
+const (
+	circuitDefaultFailures       = 5
+	circuitDefaultOpenFor        = 30 * time.Second
+	circuitDefaultHalfOpenProbes = 1
+)
+
+// WithCircuitBreaker opens the circuit of the target after consecutive
+// failures (transport errors and 5xx responses). Calls to an open circuit fail
+// with ErrorCodeUnavailable without being sent, until probe calls succeed.
+func WithCircuitBreaker(cb CircuitBreaker) ClientOption {
+	if cb.Failures <= 0 {
+		cb.Failures = circuitDefaultFailures
+	}
+	if cb.OpenFor <= 0 {
+		cb.OpenFor = circuitDefaultOpenFor
+	}
+	if cb.HalfOpenProbes <= 0 {
+		cb.HalfOpenProbes = circuitDefaultHalfOpenProbes
+	}
+	return func(c *clientConfig) { c.breaker = &cb }
+}
+
+// WithBulkhead limits the concurrent calls to the target; calls over the limit
+// fail with ErrorCodeUnavailable without being sent.
+func WithBulkhead(maxConcurrent int) ClientOption {
+	return func(c *clientConfig) { c.bulkhead = maxConcurrent }
+}
+
+// WithPerEndpointGuard keeps a circuit and bulkhead for each endpoint instead
+// of one for the target host and port.
+func WithPerEndpointGuard() ClientOption {
+	return func(c *clientConfig) { c.perEndpoint = true }
+}
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half-open"
+)
+
+// clientGuard is the circuit breaker and bulkhead of a client target
+type clientGuard struct {
+	target  string
+	breaker *CircuitBreaker
+	slots   chan struct{} // nil without bulkhead
+
+	mu         sync.Mutex
+	state      circuitState
+	failures   int
+	openedAt   time.Time
+	probes     int    // in flight, of the current half-open state
+	generation uint64 // of the state, so probes of a previous one are not counted
+}
+
+func newClientGuard(cfg clientConfig, host string, port int, endpoint string) *clientGuard {
+
+	if cfg.breaker == nil && cfg.bulkhead <= 0 {
+		return nil
+	}
+
+	g := &clientGuard{
+		target:  fmt.Sprintf("%s:%d", host, port),
+		breaker: cfg.breaker,
+		state:   circuitClosed,
+	}
+	if endpoint != "" {
+		g.target += " " + endpoint
+	}
+	if cfg.bulkhead > 0 {
+		g.slots = make(chan struct{}, cfg.bulkhead)
+	}
+
+	return g
+}
+
+// do sends the request with the client unless the circuit is open or the
+// bulkhead is full; a nil guard just sends it.
+func (g *clientGuard) do(ctx convCtx.Context, client doer, req *http.Request) (res *http.Response, err error) {
+
+	if g == nil {
+		return client.Do(req)
+	}
+
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+			defer func() { <-g.slots }()
+		default:
+			err = g.unavailable(ctx, req, "too many concurrent calls")
+			return
+		}
+	}
+
+	probe, err := g.allow(ctx, req)
+	if err != nil {
+		return
+	}
+
+	res, err = client.Do(req)
+
+	switch {
+	case err == nil && res.StatusCode < http.StatusInternalServerError:
+		g.record(ctx, probe, true)
+	case errors.Is(err, context.Canceled):
+		g.release(probe) // given up by the caller, not a failure of the target
+	default:
+		g.record(ctx, probe, false)
+	}
+
+	return
+}
+
+// allow returns the generation of the half-open state of a probe call; zero
+// when the call is no probe
+func (g *clientGuard) allow(ctx convCtx.Context, req *http.Request) (probe uint64, err error) {
+
+	if g.breaker == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.state {
+	case circuitOpen:
+		if time.Since(g.openedAt) < g.breaker.OpenFor {
+			err = g.unavailable(ctx, req, "circuit is open")
+			return
+		}
+		g.setState(ctx, circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		if g.probes >= g.breaker.HalfOpenProbes {
+			err = g.unavailable(ctx, req, "circuit is half-open and probing")
+			return
+		}
+		g.probes++
+		probe = g.generation
+	}
+
+	return
+}
+
+func (g *clientGuard) release(probe uint64) {
+	if probe == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.endProbe(probe)
+}
+
+func (g *clientGuard) record(ctx convCtx.Context, generation uint64, success bool) {
+
+	if g.breaker == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	probe := g.endProbe(generation)
+
+	if success {
+		g.failures = 0
+		if probe && g.state == circuitHalfOpen {
+			g.setState(ctx, circuitClosed)
+		}
+		return
+	}
+
+	g.failures++
+	if (probe && g.state == circuitHalfOpen) || (g.state == circuitClosed && g.failures >= g.breaker.Failures) {
+		g.openedAt = time.Now()
+		g.setState(ctx, circuitOpen)
+	}
+}
+
+// endProbe ends a probe call, reporting whether it is a probe of the current
+// half-open state; probes of a previous state were reset with it.
+func (g *clientGuard) endProbe(generation uint64) bool {
+	if generation == 0 || generation != g.generation {
+		return false
+	}
+	g.probes--
+	return true
+}
+
+func (g *clientGuard) setState(ctx convCtx.Context, state circuitState) {
+
+	if g.state == state {
+		return
+	}
+
+	logger := ctx.Logger().With("target", g.target, "from", string(g.state), "to", string(state), "failures", g.failures)
+	if state == circuitOpen {
+		logger.Warn("circuit breaker state changed")
+	} else {
+		logger.Info("circuit breaker state changed")
+	}
+
+	g.state = state
+	g.generation++
+	g.probes = 0
+}
+
+func (g *clientGuard) unavailable(ctx convCtx.Context, req *http.Request, reason string) error {
+	err := newError(ctx, http.StatusServiceUnavailable, ErrorCodeUnavailable, reason+" for "+g.target, nil)
+	err.Method = req.Method
+	err.URL = req.URL.Path
+	return err
+}
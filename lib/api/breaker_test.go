@@ -0,0 +1,238 @@
+package api_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type breakerAPI struct {
+	Flaky convAPI.Out[string] `api:"GET /test/v1/flaky"`
+	Slow  convAPI.Out[string] `api:"GET /test/v1/slow"`
+	Fast  convAPI.Out[string] `api:"GET /test/v1/fast"`
+	Held  convAPI.Out[string] `api:"GET /test/v1/held"`
+}
+
+func Test_breaker(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/flaky",
+			"GET /test/v1/slow",
+			"GET /test/v1/fast",
+			"GET /test/v1/held",
+		},
+	}
+
+	var (
+		failing atomic.Bool
+		served  atomic.Int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+
+		heldStarted = make(chan struct{}, 4)
+		heldRelease = make(chan struct{}, 4) // one per call to return
+	)
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_breaker"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &breakerAPI{
+		Flaky: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			served.Add(1)
+			if failing.Load() {
+				return "", convAPI.NewError(ctx, http.StatusInternalServerError, convAPI.ErrorCodeInternalError, "failing", nil)
+			}
+			return "ok", nil
+		}),
+		Slow: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			started <- struct{}{}
+			<-release
+			return "ok", nil
+		}),
+		Fast: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "ok", nil
+		}),
+		Held: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			heldStarted <- struct{}{}
+			select {
+			case <-heldRelease:
+			case <-time.After(5 * time.Second):
+			}
+			return "ok", nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1"})
+
+	breaker := convAPI.WithCircuitBreaker(convAPI.CircuitBreaker{Failures: 2, OpenFor: 100 * time.Millisecond})
+
+	t.Run("open_and_recover", func(t *testing.T) {
+		client := convAPI.NewClient[breakerAPI]("localhost", port, breaker)
+
+		failing.Store(true)
+		served.Store(0)
+
+		for range 2 {
+			_, err := client.Flaky.Call(callerCtx)
+			if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeInternalError) {
+				t.Fatalf("Flaky() = %v; want internal error", err)
+			}
+		}
+
+		_, err := client.Fast.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Fast() with open circuit = %v; want unavailable", err)
+		}
+		if served.Load() != 2 {
+			t.Fatalf("served = %d; want 2, no calls while open", served.Load())
+		}
+
+		time.Sleep(120 * time.Millisecond)
+
+		// a failing probe opens the circuit again
+		_, err = client.Flaky.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeInternalError) {
+			t.Fatalf("Flaky() probe = %v; want internal error", err)
+		}
+		_, err = client.Flaky.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Flaky() after failed probe = %v; want unavailable", err)
+		}
+
+		time.Sleep(120 * time.Millisecond)
+		failing.Store(false)
+
+		// a successful probe closes the circuit
+		for range 3 {
+			res, err := client.Flaky.Call(callerCtx)
+			if err != nil || res != "ok" {
+				t.Fatalf("Flaky() after recovery = %q, %v; want ok", res, err)
+			}
+		}
+	})
+
+	t.Run("per_endpoint", func(t *testing.T) {
+		client := convAPI.NewClient[breakerAPI]("localhost", port, breaker, convAPI.WithPerEndpointGuard())
+
+		failing.Store(true)
+		defer failing.Store(false)
+
+		for range 3 {
+			client.Flaky.Call(callerCtx)
+		}
+
+		_, err := client.Flaky.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Flaky() = %v; want unavailable", err)
+		}
+
+		res, err := client.Fast.Call(callerCtx)
+		if err != nil || res != "ok" {
+			t.Fatalf("Fast() = %q, %v; want ok from its own circuit", res, err)
+		}
+	})
+
+	t.Run("stale_probes", func(t *testing.T) {
+		client := convAPI.NewClient[breakerAPI]("localhost", port,
+			convAPI.WithCircuitBreaker(convAPI.CircuitBreaker{Failures: 1, OpenFor: 100 * time.Millisecond, HalfOpenProbes: 2}),
+		)
+
+		failing.Store(true)
+		defer failing.Store(false)
+
+		probes := make(chan error, 4)
+		probe := func(t *testing.T) {
+			t.Helper()
+			go func() {
+				_, err := client.Held.Call(callerCtx)
+				probes <- err
+			}()
+			select {
+			case <-heldStarted:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Held() probe was not sent")
+			}
+		}
+		finish := func(t *testing.T) {
+			t.Helper()
+			heldRelease <- struct{}{}
+			select {
+			case err := <-probes:
+				if err != nil {
+					t.Fatalf("Held() probe = %v; want nil", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Held() probe did not return")
+			}
+		}
+		open := func(t *testing.T) {
+			t.Helper()
+			client.Flaky.Call(callerCtx)
+			time.Sleep(120 * time.Millisecond) // half-open once probed
+		}
+
+		open(t)
+		probe(t)
+		probe(t)
+
+		_, err := client.Fast.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Fast() with all probes in flight = %v; want unavailable", err)
+		}
+
+		finish(t) // closes the circuit
+
+		open(t)
+		finish(t) // a probe of the half-open state before, not counted again
+		probe(t)
+		probe(t)
+		defer finish(t)
+		defer finish(t)
+
+		_, err = client.Fast.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Fast() with all probes in flight after a stale probe = %v; want unavailable", err)
+		}
+	})
+
+	t.Run("bulkhead", func(t *testing.T) {
+		client := convAPI.NewClient[breakerAPI]("localhost", port, convAPI.WithBulkhead(1))
+
+		done := make(chan error)
+		go func() {
+			_, err := client.Slow.Call(callerCtx)
+			done <- err
+		}()
+		<-started
+
+		_, err := client.Fast.Call(callerCtx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeUnavailable) {
+			t.Fatalf("Fast() with full bulkhead = %v; want unavailable", err)
+		}
+
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("Slow() = %v; want nil", err)
+		}
+
+		res, err := client.Fast.Call(callerCtx)
+		if err != nil || res != "ok" {
+			t.Fatalf("Fast() after slot release = %q, %v; want ok", res, err)
+		}
+	})
+}
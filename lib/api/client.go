@@ -1,13 +1,27 @@
 package api
 
 import (
+	"net/http"
 	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewClient[svcT any](host string, port int) (svc *svcT) {
+// NewClient panics on endpoints with a path parameter type it cannot format,
+// or with a params struct not fitting its path.
+func NewClient[svcT any](host string, port int, opts ...ClientOption) (svc *svcT) {
+
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	target := newClientGuard(cfg, host, port, "")
+	balancer := newBalancer(cfg, host)
 
 	svc = new(svcT)
 
+	var eps endpoints
 	for _, f := range reflect.VisibleFields(reflect.TypeOf(svc).Elem()) {
 
 		ep, ok := reflect.ValueOf(svc).Elem().FieldByName(f.Name).Addr().Interface().(endpoint)
@@ -18,8 +32,47 @@ func NewClient[svcT any](host string, port int) (svc *svcT) {
 		apiTag := f.Tag.Get("api")
 		in, out := ep.getInOutTypes()
 		desc := newDescriptor(host, port, apiTag, in, out)
+		if pt, ok := ep.(interface{ getParamTypes() []reflect.Type }); ok {
+			desc.params = pt.getParamTypes()
+		}
+		desc.bindEndpoint(ep)
+		if cfg.perEndpoint {
+			desc.guard = newClientGuard(cfg, host, port, desc.method+" "+desc.path())
+		} else {
+			desc.guard = target
+		}
+		desc.balancer = balancer
 		ep.setDescriptor(desc)
+		eps = append(eps, ep)
+	}
+
+	err := checkEndpointParams(eps)
+	if err != nil {
+		panic(err)
 	}
 
 	return
 }
+
+// doer sends a client request; *http.Client or the websocket dialer
+type doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// send does the client request, through the balancer and guard of the
+// target when set
+func (desc *descriptor) send(ctx convCtx.Context, req *http.Request) (*http.Response, error) {
+	return desc.sendVia(ctx, req, nil)
+}
+
+// sendVia is send with the given doer instead of the http client of the
+// target; nil for that client
+func (desc *descriptor) sendVia(ctx convCtx.Context, req *http.Request, client doer) (*http.Response, error) {
+	if desc.balancer != nil {
+		return desc.balancer.send(ctx, req, desc.guard, client)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return desc.guard.do(ctx, client, req)
+}
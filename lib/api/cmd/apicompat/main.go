@@ -0,0 +1,59 @@
+// Command apicompat compares two OpenAPI documents generated by the
+// convention api package and exits with status 1 on breaking changes.
+//
+//	apicompat [-breaking] old.yaml new.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+)
+
+func main() {
+
+	breakingOnly := flag.Bool("breaking", false, "report breaking changes only")
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: apicompat [-breaking] old.yaml new.yaml")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldDoc, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	newDoc, err := os.ReadFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	changes, err := convAPI.CompareOpenAPI(oldDoc, newDoc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *breakingOnly {
+		changes = changes.Breaking()
+	}
+
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	if changes.HasBreaking() {
+		os.Exit(1)
+	}
+}
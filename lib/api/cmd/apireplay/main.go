@@ -0,0 +1,121 @@
+// Command apireplay replays the API calls logged by EnableCallsLogging of the
+// convention api package against a local agent, with freshly minted tokens
+// for the recorded claims at the recorded time, and reports the responses
+// differing from the recorded ones; it exits with status 1 when any does.
+//
+//	apireplay [-target url] [-workflow id] [-user user] [-since time] [-until time] [-ignore fields] [log files]
+//
+// Log lines are read from standard input when no files are given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func main() {
+
+	target := flag.String("target", "https://localhost:443", "base URL of the local agent")
+	workflow := flag.String("workflow", "", "replay the calls of the workflow only")
+	user := flag.String("user", "", "replay the calls of the user only")
+	since := flag.String("since", "", "replay the calls served at or after the time (RFC 3339) only")
+	until := flag.String("until", "", "replay the calls served at or before the time (RFC 3339) only")
+	ignore := flag.String("ignore", "", "comma separated response fields (dot notation) not to compare, e.g. id,items.updated_at")
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: apireplay [-target url] [-workflow id] [-user user] [-since time] [-until time] [-ignore fields] [log files]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	filter := convAPI.ReplayFilter{
+		Workflow: *workflow,
+		User:     convAuth.User(*user),
+	}
+
+	var err error
+	if *since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			fail(err)
+		}
+	}
+	if *until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, *until)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	var ignored []string
+	if *ignore != "" {
+		ignored = strings.Split(*ignore, ",")
+	}
+
+	var calls []convAPI.RecordedCall
+	if flag.NArg() == 0 {
+		calls, err = convAPI.ReadRecordedCalls(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fail(err)
+		}
+		cs, err := convAPI.ReadRecordedCalls(f)
+		f.Close()
+		if err != nil {
+			fail(err)
+		}
+		calls = append(calls, cs...)
+	}
+
+	ctx := convCtx.New(convAuth.Claims{User: "apireplay"})
+
+	replayed, differing := 0, 0
+	for _, c := range calls {
+
+		if !filter.Match(c) {
+			continue
+		}
+		replayed++
+
+		res, err := c.Replay(ctx, *target, ignored...)
+		if err != nil {
+			differing++
+			fmt.Printf("✘ %s %s (%s): %v\n", c.Method, c.URL, c.Time.Format(time.RFC3339), err)
+			continue
+		}
+
+		if res.Matches() {
+			fmt.Printf("✔ %s %s (%s): %d\n", c.Method, c.URL, c.Time.Format(time.RFC3339), res.Status)
+			continue
+		}
+
+		differing++
+		fmt.Printf("✘ %s %s (%s):\n", c.Method, c.URL, c.Time.Format(time.RFC3339))
+		for _, d := range res.Diffs {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+
+	fmt.Printf("%d calls replayed, %d differing\n", replayed, differing)
+
+	if differing > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is a single difference between two versions of an API.
+// Breaking changes can fail consumers built against the old version.
+type Change struct {
+	Breaking bool   `json:"breaking"`
+	Endpoint string `json:"endpoint"`
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (c Change) String() string {
+	sb := strings.Builder{}
+	if c.Breaking {
+		sb.WriteString("[breaking] ")
+	} else {
+		sb.WriteString("[safe] ")
+	}
+	sb.WriteString(c.Endpoint)
+	if c.Location != "" {
+		sb.WriteRune(' ')
+		sb.WriteString(c.Location)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(c.Message)
+	return sb.String()
+}
+
+type Changes []Change
+
+// Breaking returns only the breaking changes
+func (cs Changes) Breaking() (res Changes) {
+	for _, c := range cs {
+		if c.Breaking {
+			res = append(res, c)
+		}
+	}
+	return
+}
+
+func (cs Changes) HasBreaking() bool {
+	return len(cs.Breaking()) > 0
+}
+
+// CompareAPI reports the changes between two versions of an API definition.
+// Both are pointers to API structs, as passed to NewServer; enums and type
+// substitutions of an OpenAPI endpoint in the struct are taken into account.
+func CompareAPI(oldAPI, newAPI any) Changes {
+	return compareSpecs(specFromAPI(oldAPI), specFromAPI(newAPI))
+}
+
+// CompareOpenAPI reports the changes between two OpenAPI documents (YAML)
+// as generated by the OpenAPI endpoint.
+func CompareOpenAPI(oldYAML, newYAML []byte) (changes Changes, err error) {
+
+	oldSpec, err := specFromOpenAPI(oldYAML)
+	if err != nil {
+		err = fmt.Errorf("old document: %w", err)
+		return
+	}
+
+	newSpec, err := specFromOpenAPI(newYAML)
+	if err != nil {
+		err = fmt.Errorf("new document: %w", err)
+		return
+	}
+
+	changes = compareSpecs(oldSpec, newSpec)
+	return
+}
+
+// apiSpec is the comparable model of an API, built either from the
+// descriptors of an API struct or from an OpenAPI document.
+type apiSpec map[string]*specEndpoint // by method and path with unnamed params
+
+type specEndpoint struct {
+	method  string
+	path    string
+	params  []specParam
+	query   map[string]string // name → type
+	in, out *specSchema
+}
+
+type specParam struct {
+	name   string
+	typ    objectType
+	format string
+}
+
+func (p specParam) String() string {
+	if p.format == "" {
+		return string(p.typ)
+	}
+	return fmt.Sprintf("%s (%s)", p.typ, p.format)
+}
+
+type specSchema struct {
+	typ      objectType
+	fields   map[string]*specSchema // nil when the object is free form
+	required map[string]bool
+	elem     *specSchema
+	enum     []string
+}
+
+// endpointKey ignores the names of path parameters, as clients only
+// depend on their position
+func endpointKey(method string, segments []urlSegment) string {
+	sb := strings.Builder{}
+	sb.WriteString(method)
+	sb.WriteRune(' ')
+	for _, s := range segments {
+		sb.WriteRune('/')
+		if s.Param {
+			sb.WriteString("{}")
+		} else {
+			sb.WriteString(s.Value)
+		}
+	}
+	return sb.String()
+}
+
+func specFromAPI(api any) (spec apiSpec) {
+
+	spec = apiSpec{}
+
+	eps := computeEndpoints("", 0, api)
+
+	oa := &OpenAPI{}
+	for _, ep := range eps {
+		if o, ok := ep.(*OpenAPI); ok {
+			oa = o
+		}
+	}
+
+	seen := map[*object]*specSchema{}
+
+	for _, ep := range eps {
+		desc := ep.getDescriptor()
+
+		sep := &specEndpoint{
+			method: desc.method,
+			path:   desc.path(),
+			query:  map[string]string{},
+			in:     specFromObject(oa, oa.objOrSub(desc.in), seen),
+			out:    specFromObject(oa, oa.objOrSub(desc.out), seen),
+		}
+		for i, name := range desc.parameters() {
+			typ, format := desc.parameterSchema(i)
+			sep.params = append(sep.params, specParam{name, typ, format})
+		}
+		for _, q := range desc.query {
+			sep.query[q.Name] = string(q.Type)
+		}
+
+		spec[endpointKey(desc.method, desc.segments)] = sep
+	}
+
+	return
+}
+
+func specFromObject(oa *OpenAPI, o *object, seen map[*object]*specSchema) (s *specSchema) {
+
+	if o == nil {
+		return nil
+	}
+
+	if s, ok := seen[o]; ok {
+		return s
+	}
+
+	s = &specSchema{typ: o.Type}
+	seen[o] = s
+
+	if values, ok := oa.enums[o.ID]; ok {
+		s.typ = objectTypeEnum
+		s.enum = values
+		return
+	}
+
+	switch o.Type {
+	case objectTypeArray, objectTypeMap:
+		s.elem = specFromObject(oa, oa.objOrSub(o.Elem), seen)
+	case objectTypeObject:
+		if o.Fields != nil {
+			s.fields = map[string]*specSchema{}
+			s.required = map[string]bool{}
+			for name, f := range o.Fields {
+				f = oa.objOrSub(f)
+				s.fields[name] = specFromObject(oa, f, seen)
+				s.required[name] = f.Mandatory
+			}
+		}
+	}
+
+	return
+}
+
+type specComparer struct {
+	changes  Changes
+	endpoint string
+	visited  map[specVisit]bool
+}
+
+type specVisit struct {
+	o, n    *specSchema
+	request bool
+}
+
+func (c *specComparer) add(breaking bool, location, format string, args ...any) {
+	c.changes = append(c.changes, Change{
+		Breaking: breaking,
+		Endpoint: c.endpoint,
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func compareSpecs(oldSpec, newSpec apiSpec) Changes {
+
+	c := &specComparer{visited: map[specVisit]bool{}}
+
+	keys := make([]string, 0, len(oldSpec)+len(newSpec))
+	for k := range oldSpec {
+		keys = append(keys, k)
+	}
+	for k := range newSpec {
+		if _, ok := oldSpec[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		o, n := oldSpec[k], newSpec[k]
+		switch {
+		case n == nil:
+			c.endpoint = o.method + " " + o.path
+			c.add(true, "", "endpoint removed")
+		case o == nil:
+			c.endpoint = n.method + " " + n.path
+			c.add(false, "", "endpoint added")
+		default:
+			c.endpoint = n.method + " " + n.path
+			c.compareEndpoints(o, n)
+		}
+	}
+
+	return c.changes
+}
+
+func (c *specComparer) compareEndpoints(o, n *specEndpoint) {
+
+	for i, op := range o.params {
+		if i >= len(n.params) {
+			break
+		}
+		np := n.params[i]
+		if op.name != np.name {
+			c.add(false, "path."+np.name, "path parameter renamed from '%s'", op.name)
+		}
+		if op.typ != np.typ || op.format != np.format {
+			c.add(true, "path."+np.name, "path parameter type changed from %s to %s", op, np)
+		}
+	}
+
+	for _, name := range sortedKeys(o.query) {
+		nt, ok := n.query[name]
+		switch {
+		case !ok:
+			c.add(true, "query."+name, "query parameter removed")
+		case nt != o.query[name]:
+			c.add(true, "query."+name, "query parameter type changed from %s to %s", o.query[name], nt)
+		}
+	}
+	for _, name := range sortedKeys(n.query) {
+		if _, ok := o.query[name]; !ok {
+			c.add(false, "query."+name, "query parameter added")
+		}
+	}
+
+	switch {
+	case o.in == nil && n.in != nil:
+		c.add(true, "request", "request body added")
+	case o.in != nil && n.in == nil:
+		c.add(false, "request", "request body removed")
+	case o.in != nil:
+		c.compareSchemas("request", o.in, n.in, true)
+	}
+
+	switch {
+	case o.out == nil && n.out != nil:
+		c.add(false, "response", "response body added")
+	case o.out != nil && n.out == nil:
+		c.add(true, "response", "response body removed")
+	case o.out != nil:
+		c.compareSchemas("response", o.out, n.out, false)
+	}
+}
+
+// compareSchemas compares o and n at location; request tells whether the
+// schema is sent by the client (narrowing breaks) or received (widening breaks).
+func (c *specComparer) compareSchemas(location string, o, n *specSchema, request bool) {
+
+	visit := specVisit{o, n, request}
+	if c.visited[visit] {
+		return // recursive types
+	}
+	c.visited[visit] = true
+	defer delete(c.visited, visit) // shared types are reported at every location
+
+	if o.typ != n.typ {
+		switch {
+		case o.typ == objectTypeString && n.typ == objectTypeEnum:
+			c.add(request, location, "string narrowed to enum %s", strings.Join(n.enum, ", "))
+		case o.typ == objectTypeEnum && n.typ == objectTypeString:
+			c.add(!request, location, "enum widened to string")
+		default:
+			c.add(true, location, "type changed from %s to %s", o.typ, n.typ)
+		}
+		return
+	}
+
+	switch o.typ {
+
+	case objectTypeEnum:
+		removed, added := diffValues(o.enum, n.enum)
+		if len(removed) > 0 {
+			c.add(request, location, "enum values removed: %s", strings.Join(removed, ", "))
+		}
+		if len(added) > 0 {
+			c.add(!request, location, "enum values added: %s", strings.Join(added, ", "))
+		}
+
+	case objectTypeArray:
+		if o.elem != nil && n.elem != nil {
+			c.compareSchemas(location+"[]", o.elem, n.elem, request)
+		}
+
+	case objectTypeMap:
+		if o.elem != nil && n.elem != nil {
+			c.compareSchemas(location+"{}", o.elem, n.elem, request)
+		}
+
+	case objectTypeObject:
+		if o.fields == nil || n.fields == nil {
+			return
+		}
+
+		for _, name := range sortedKeys(o.fields) {
+			loc := location + "." + name
+			nf, ok := n.fields[name]
+			if !ok {
+				// servers ignore unknown fields, clients may depend on them
+				c.add(!request, loc, "field removed")
+				continue
+			}
+			switch {
+			case request && !o.required[name] && n.required[name]:
+				c.add(true, loc, "field became required")
+			case !request && o.required[name] && !n.required[name]:
+				c.add(true, loc, "field became optional")
+			}
+			c.compareSchemas(loc, o.fields[name], nf, request)
+		}
+
+		for _, name := range sortedKeys(n.fields) {
+			if _, ok := o.fields[name]; ok {
+				continue
+			}
+			loc := location + "." + name
+			if request && n.required[name] {
+				c.add(true, loc, "required field added")
+			} else {
+				c.add(false, loc, "field added")
+			}
+		}
+	}
+}
+
+func diffValues(oldValues, newValues []string) (removed, added []string) {
+
+	known := map[string]bool{}
+	for _, v := range newValues {
+		known[v] = true
+	}
+	for _, v := range oldValues {
+		if !known[v] {
+			removed = append(removed, v)
+		}
+	}
+
+	known = map[string]bool{}
+	for _, v := range oldValues {
+		known[v] = true
+	}
+	for _, v := range newValues {
+		if !known[v] {
+			added = append(added, v)
+		}
+	}
+
+	return
+}
+
+func sortedKeys[T any](m map[string]T) (keys []string) {
+	keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
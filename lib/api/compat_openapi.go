@@ -0,0 +1,402 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var errUnsupportedYAML = errors.New("unsupported yaml")
+
+var openAPIMethods = map[string]bool{
+	strings.ToLower(http.MethodGet):     true,
+	strings.ToLower(http.MethodHead):    true,
+	strings.ToLower(http.MethodPost):    true,
+	strings.ToLower(http.MethodPut):     true,
+	strings.ToLower(http.MethodPatch):   true,
+	strings.ToLower(http.MethodDelete):  true,
+	strings.ToLower(http.MethodOptions): true,
+}
+
+func specFromOpenAPI(doc []byte) (spec apiSpec, err error) {
+
+	root, err := parseYAML(string(doc))
+	if err != nil {
+		return
+	}
+
+	rootMap, ok := root.(map[string]any)
+	if !ok {
+		err = errors.New("document is not a yaml mapping")
+		return
+	}
+
+	conv := &openAPIConverter{
+		schemas: yamlMap(yamlMap(rootMap["components"])["schemas"]),
+		refs:    map[string]*specSchema{},
+	}
+
+	spec = apiSpec{}
+
+	paths := yamlMap(rootMap["paths"])
+	for _, path := range sortedKeys(paths) {
+		item := yamlMap(paths[path])
+
+		desc := newDescriptor("", 0, path, nil, nil)
+
+		pathParams := yamlList(item["parameters"])
+
+		for _, method := range sortedKeys(item) {
+			if !openAPIMethods[method] {
+				continue // parameters, summary, ...
+			}
+			op := yamlMap(item[method])
+
+			sep := &specEndpoint{
+				method: strings.ToUpper(method),
+				path:   desc.path(),
+				query:  map[string]string{},
+			}
+
+			inPath := map[string]specParam{}
+			for _, p := range append(pathParams, yamlList(op["parameters"])...) {
+				pm := yamlMap(p)
+				name, schema := yamlString(pm["name"]), yamlMap(pm["schema"])
+				switch yamlString(pm["in"]) {
+				case "query":
+					sep.query[name] = yamlString(schema["type"])
+				case "path":
+					inPath[name] = specParam{name, objectType(yamlString(schema["type"])), yamlString(schema["format"])}
+				}
+			}
+			for _, name := range desc.parameters() {
+				p, ok := inPath[name]
+				if !ok || p.typ == "" {
+					p = specParam{name: name, typ: objectTypeString} // undocumented
+				}
+				sep.params = append(sep.params, p)
+			}
+
+			if body, ok := op["requestBody"]; ok {
+				sep.in, err = conv.schema(jsonSchemaOf(body))
+				if err != nil {
+					return
+				}
+			}
+
+			if res, ok := yamlMap(op["responses"])["200"]; ok {
+				if s := jsonSchemaOf(res); s != nil {
+					sep.out, err = conv.schema(s)
+					if err != nil {
+						return
+					}
+				}
+			}
+
+			spec[endpointKey(sep.method, desc.segments)] = sep
+		}
+	}
+
+	return
+}
+
+// jsonSchemaOf returns the application/json schema of a request body or response
+func jsonSchemaOf(node any) any {
+	return yamlMap(yamlMap(yamlMap(node)["content"])["application/json"])["schema"]
+}
+
+type openAPIConverter struct {
+	schemas map[string]any
+	refs    map[string]*specSchema
+}
+
+func (conv *openAPIConverter) schema(node any) (s *specSchema, err error) {
+
+	m := yamlMap(node)
+	if m == nil {
+		return nil, nil
+	}
+
+	if ref := yamlString(m["$ref"]); ref != "" {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if s, ok := conv.refs[name]; ok {
+			return s, nil
+		}
+		target, ok := conv.schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema reference '%s'", ref)
+		}
+		s = &specSchema{}
+		conv.refs[name] = s // register before resolving for recursive schemas
+		var resolved *specSchema
+		resolved, err = conv.schema(target)
+		if err != nil {
+			return
+		}
+		*s = *resolved
+		return
+	}
+
+	s = &specSchema{typ: objectType(yamlString(m["type"]))}
+
+	switch {
+
+	case s.typ == objectTypeString && yamlString(m["format"]) == "date-time":
+		s.typ = objectTypeTime
+
+	case s.typ == objectTypeString && m["enum"] != nil:
+		s.typ = objectTypeEnum
+		for _, v := range yamlList(m["enum"]) {
+			s.enum = append(s.enum, yamlString(v))
+		}
+
+	case s.typ == objectTypeArray:
+		s.elem, err = conv.schema(m["items"])
+
+	case s.typ == objectTypeObject && m["additionalProperties"] != nil:
+		s.typ = objectTypeMap
+		s.elem, err = conv.schema(m["additionalProperties"])
+
+	case s.typ == objectTypeObject && m["properties"] != nil:
+		props := yamlMap(m["properties"])
+		s.fields = map[string]*specSchema{}
+		s.required = map[string]bool{}
+		for _, name := range sortedKeys(props) {
+			s.fields[name], err = conv.schema(props[name])
+			if err != nil {
+				return
+			}
+		}
+		for _, name := range yamlList(m["required"]) {
+			s.required[yamlString(name)] = true
+		}
+	}
+
+	return
+}
+
+func yamlMap(node any) map[string]any {
+	m, _ := node.(map[string]any)
+	return m
+}
+
+func yamlList(node any) []any {
+	l, _ := node.([]any)
+	return l
+}
+
+func yamlString(node any) string {
+	s, _ := node.(string)
+	return s
+}
+
+// parseYAML reads the block style subset of YAML used by OpenAPI documents:
+// mappings, sequences, plain and quoted scalars, simple flow sequences and
+// block scalars. Scalars are returned as strings.
+func parseYAML(doc string) (res any, err error) {
+
+	lines := yamlLines(doc)
+	if len(lines) == 0 {
+		return
+	}
+
+	res, i, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return
+	}
+
+	if i < len(lines) {
+		err = fmt.Errorf("%w: unexpected content at '%s'", errUnsupportedYAML, lines[i].text)
+	}
+
+	return
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+func yamlLines(doc string) (lines []yamlLine) {
+	for _, raw := range strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n") {
+		text := stripYAMLComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" || trimmed == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{
+			indent: len(text) - len(trimmed),
+			text:   strings.TrimRight(trimmed, " \t"),
+		})
+	}
+	return
+}
+
+func stripYAMLComment(s string) string {
+	quote := rune(0)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if isYAMLSeqItem(lines[i].text) {
+		return parseYAMLSeq(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+func parseYAMLSeq(lines []yamlLine, i, indent int) (res []any, _ int, err error) {
+
+	res = []any{}
+
+	for i < len(lines) && lines[i].indent == indent && isYAMLSeqItem(lines[i].text) {
+
+		rest := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+
+		var v any
+
+		switch {
+		case rest == "":
+			i++
+			if i < len(lines) && lines[i].indent > indent {
+				v, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+			}
+		case isYAMLSeqItem(rest) || isYAMLMapEntry(rest):
+			// the item content continues at the column after the dash
+			itemIndent := indent + len(lines[i].text) - len(rest)
+			lines[i] = yamlLine{indent: itemIndent, text: rest}
+			v, i, err = parseYAMLBlock(lines, i, itemIndent)
+		default:
+			v = parseYAMLScalar(rest)
+			i++
+		}
+		if err != nil {
+			return
+		}
+
+		res = append(res, v)
+	}
+
+	return res, i, nil
+}
+
+func parseYAMLMap(lines []yamlLine, i, indent int) (res map[string]any, _ int, err error) {
+
+	res = map[string]any{}
+
+	for i < len(lines) && lines[i].indent == indent && !isYAMLSeqItem(lines[i].text) {
+
+		key, value, ok := splitYAMLMapEntry(lines[i].text)
+		if !ok {
+			err = fmt.Errorf("%w: expecting 'key: value' at '%s'", errUnsupportedYAML, lines[i].text)
+			return
+		}
+		i++
+
+		var v any
+
+		switch {
+		case value == "":
+			next := i < len(lines) &&
+				(lines[i].indent > indent || (lines[i].indent == indent && isYAMLSeqItem(lines[i].text)))
+			if next {
+				v, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+				if err != nil {
+					return
+				}
+			}
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			sb := strings.Builder{}
+			for i < len(lines) && lines[i].indent > indent {
+				if sb.Len() > 0 {
+					sb.WriteRune('\n')
+				}
+				sb.WriteString(lines[i].text)
+				i++
+			}
+			v = sb.String()
+		default:
+			v = parseYAMLScalar(value)
+		}
+
+		res[key] = v
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		err = fmt.Errorf("%w: unexpected indentation at '%s'", errUnsupportedYAML, lines[i].text)
+		return
+	}
+
+	return res, i, nil
+}
+
+func isYAMLMapEntry(text string) bool {
+	_, _, ok := splitYAMLMapEntry(text)
+	return ok
+}
+
+func splitYAMLMapEntry(text string) (key, value string, ok bool) {
+	quote := rune(0)
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '\'' || r == '"') && i == 0:
+			quote = r
+		case r == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key = unquoteYAML(strings.TrimSpace(text[:i]))
+			value = strings.TrimSpace(text[i+1:])
+			return key, value, true
+		}
+	}
+	return
+}
+
+func parseYAMLScalar(s string) any {
+	switch {
+	case s == "{}":
+		return map[string]any{}
+	case strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]"):
+		res := []any{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return res
+		}
+		for _, v := range strings.Split(inner, ",") {
+			res = append(res, unquoteYAML(strings.TrimSpace(v)))
+		}
+		return res
+	default:
+		return unquoteYAML(s)
+	}
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		case s[0] == '"' && s[len(s)-1] == '"':
+			return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+		}
+	}
+	return s
+}
@@ -0,0 +1,261 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type compatStatus string
+
+const (
+	compatStatusActive  compatStatus = "active"
+	compatStatusBlocked compatStatus = "blocked"
+)
+
+type compatRole string
+
+const (
+	compatRoleMember compatRole = "member"
+	compatRoleAdmin  compatRole = "admin"
+)
+
+type compatUserV1 struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Email  string       `json:"email,omitempty"`
+	Status compatStatus `json:"status"`
+	Role   compatRole   `json:"role"`
+}
+
+type compatUserV2 struct {
+	ID       string       `json:"id"`
+	FullName string       `json:"full_name"`
+	Email    string       `json:"email,omitempty"`
+	Status   compatStatus `json:"status"`
+	Role     compatRole   `json:"role"`
+}
+
+type compatAPIV1 struct {
+	GetUser    convAPI.OutP1[compatUserV1, string] `api:"GET /users/v1/users/{user}"`
+	PutUser    convAPI.InP1[compatUserV1, string]  `api:"PUT /users/v1/users/{user}"`
+	DeleteUser convAPI.TriggerP1[string]           `api:"DELETE /users/v1/users/{user}"`
+	GetAvatar  convAPI.OutP1[string, string]       `api:"GET /users/v1/avatars/{avatar}"`
+	OpenAPI    convAPI.OpenAPI                     `api:"GET /users/v1/openapi.yaml"`
+}
+
+type compatAPIV2 struct {
+	GetUser   convAPI.OutP1[compatUserV2, string] `api:"GET /users/v1/users/{user_id}"`
+	PutUser   convAPI.InP1[compatUserV2, string]  `api:"PUT /users/v1/users/{user_id}"`
+	ListUsers convAPI.Out[[]compatUserV2]         `api:"GET /users/v1/users"`
+	GetAvatar convAPI.OutP1[string, uuid.UUID]    `api:"GET /users/v1/avatars/{avatar}"`
+	OpenAPI   convAPI.OpenAPI                     `api:"GET /users/v1/openapi.yaml"`
+}
+
+func compatAPIs() (v1 *compatAPIV1, v2 *compatAPIV2) {
+	v1 = &compatAPIV1{
+		OpenAPI: convAPI.NewOpenAPI().WithEnums(
+			convAPI.NewEnum(compatStatusActive, compatStatusBlocked),
+			convAPI.NewEnum(compatRoleMember),
+		),
+	}
+	v2 = &compatAPIV2{
+		OpenAPI: convAPI.NewOpenAPI().WithEnums(
+			convAPI.NewEnum(compatStatusActive),
+			convAPI.NewEnum(compatRoleMember, compatRoleAdmin),
+		),
+	}
+	return
+}
+
+var compatWant = []string{
+	"[breaking] DELETE /users/v1/users/{user}: endpoint removed",
+	"[breaking] GET /users/v1/avatars/{avatar} path.avatar: path parameter type changed from string to string (uuid)",
+	"[safe] GET /users/v1/users: endpoint added",
+	"[safe] GET /users/v1/users/{user_id} path.user_id: path parameter renamed from 'user'",
+	"[breaking] GET /users/v1/users/{user_id} response.name: field removed",
+	"[breaking] GET /users/v1/users/{user_id} response.role: enum values added: admin",
+	"[safe] GET /users/v1/users/{user_id} response.status: enum values removed: blocked",
+	"[safe] GET /users/v1/users/{user_id} response.full_name: field added",
+	"[safe] PUT /users/v1/users/{user_id} path.user_id: path parameter renamed from 'user'",
+	"[safe] PUT /users/v1/users/{user_id} request.name: field removed",
+	"[safe] PUT /users/v1/users/{user_id} request.role: enum values added: admin",
+	"[breaking] PUT /users/v1/users/{user_id} request.status: enum values removed: blocked",
+	"[breaking] PUT /users/v1/users/{user_id} request.full_name: required field added",
+}
+
+func assertChanges(t *testing.T, changes convAPI.Changes) {
+	t.Helper()
+
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+
+	if strings.Join(got, "\n") != strings.Join(compatWant, "\n") {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(compatWant, "\n"))
+	}
+
+	if len(changes.Breaking()) != 6 || !changes.HasBreaking() {
+		t.Fatalf("Breaking() = %d changes; want 6", len(changes.Breaking()))
+	}
+}
+
+func Test_compare_api(t *testing.T) {
+
+	v1, v2 := compatAPIs()
+
+	assertChanges(t, convAPI.CompareAPI(v1, v2))
+
+	if changes := convAPI.CompareAPI(v1, v1); len(changes) > 0 {
+		t.Fatalf("CompareAPI(v1, v1) = %v; want no changes", changes)
+	}
+}
+
+func Test_compare_openapi(t *testing.T) {
+
+	ctx := convCtx.New(convAuth.Claims{User: "Test_compare_openapi"})
+
+	allowAll := func(r *http.Request) (convAuth.Target, error) { return convAuth.Target{}, nil }
+
+	openAPI := func(api any) []byte {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/users/v1/openapi.yaml", nil)
+		convAPI.NewHandler(ctx, "localhost", 443, allowAll, api).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("openapi status = %d; want 200", rec.Code)
+		}
+		return rec.Body.Bytes()
+	}
+
+	v1, v2 := compatAPIs()
+
+	changes, err := convAPI.CompareOpenAPI(openAPI(v1), openAPI(v2))
+	if err != nil {
+		t.Fatalf("CompareOpenAPI() = %v; want nil", err)
+	}
+
+	assertChanges(t, changes)
+}
+
+func Test_compare_openapi_yaml(t *testing.T) {
+
+	oldDoc := `
+openapi: 3.0.0
+paths:
+  /orders/v1/orders/{order}:
+    parameters:
+      - name: order
+        in: path
+        schema: {type: string}
+    delete:
+      responses:
+        '200':
+          description: OK
+  /orders/v1/orders:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer}
+      responses:
+        '200':
+          description: OK # list of orders
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/order'
+components:
+  schemas:
+    order:
+      type: object
+      required: [id, total]
+      properties:
+        id:
+          type: string
+        total:
+          type: number
+        state:
+          type: string
+          enum: [open, paid]
+        parent:
+          $ref: '#/components/schemas/order'
+`
+
+	newDoc := `
+openapi: 3.0.0
+components:
+  schemas:
+    order:
+      type: object
+      required:
+        - id
+      properties:
+        id:
+          type: string
+        total:
+          type: string
+        state:
+          type: string
+        parent:
+          $ref: '#/components/schemas/order'
+paths:
+  /orders/v1/orders/{order}:
+    delete:
+      parameters:
+        - name: order
+          in: path
+          schema:
+            type: integer
+            format: int64
+      responses:
+        '200':
+          description: OK
+  /orders/v1/orders:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/order'
+`
+
+	changes, err := convAPI.CompareOpenAPI([]byte(oldDoc), []byte(newDoc))
+	if err != nil {
+		t.Fatalf("CompareOpenAPI() = %v; want nil", err)
+	}
+
+	want := []string{
+		"[breaking] DELETE /orders/v1/orders/{order} path.order: path parameter type changed from string to integer (int64)",
+		"[breaking] GET /orders/v1/orders query.limit: query parameter removed",
+		"[breaking] GET /orders/v1/orders response[].state: enum widened to string",
+		"[breaking] GET /orders/v1/orders response[].total: field became optional",
+		"[breaking] GET /orders/v1/orders response[].total: type changed from number to string",
+	}
+
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	_, err = convAPI.CompareOpenAPI([]byte("paths:\n  /a:\n    get\n"), []byte(newDoc))
+	if err == nil {
+		t.Fatal("CompareOpenAPI() with invalid yaml = nil; want error")
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+const (
+	configKeyCORS convCfg.ConfigKey = "cors"
+
+	httpHeaderOrigin                        = "Origin"
+	httpHeaderVary                          = "Vary"
+	httpHeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	httpHeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	httpHeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	httpHeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	httpHeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	httpHeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	httpHeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	httpHeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// CORS describes which browser origins may call the API and how.
+// When AllowedHeaders is empty the headers requested by the preflight are allowed.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"` // seconds
+}
+
+// LoadCORS reads the CORS configuration from the "cors" config key.
+func LoadCORS() (cors CORS, err error) {
+	return convCfg.Object[CORS](configKeyCORS)
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) anyOrigin() bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// setOriginHeaders adds the headers shared by preflight and actual requests.
+// It reports false when the origin is not allowed.
+func (c *CORS) setOriginHeaders(w http.ResponseWriter, origin string) bool {
+
+	if !c.allowOrigin(origin) {
+		return false
+	}
+
+	if c.anyOrigin() && !c.AllowCredentials {
+		w.Header().Set(httpHeaderAccessControlAllowOrigin, "*")
+	} else {
+		// credentials cannot be combined with a wildcard origin, so echo it back
+		w.Header().Set(httpHeaderAccessControlAllowOrigin, origin)
+		w.Header().Add(httpHeaderVary, httpHeaderOrigin)
+	}
+
+	if c.AllowCredentials {
+		w.Header().Set(httpHeaderAccessControlAllowCredentials, "true")
+	}
+
+	return true
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get(httpHeaderOrigin) != "" &&
+		r.Header.Get(httpHeaderAccessControlRequestMethod) != ""
+}
+
+// serve handles the CORS part of a request. It reports true when the
+// request was fully answered (preflight) and must not be processed further.
+func (c *CORS) serve(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, eps endpoints) bool {
+
+	origin := r.Header.Get(httpHeaderOrigin)
+	if origin == "" {
+		return false
+	}
+
+	if !isPreflight(r) {
+		if c.setOriginHeaders(w, origin) && len(c.ExposedHeaders) > 0 {
+			w.Header().Set(httpHeaderAccessControlExposeHeaders, strings.Join(c.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	// the origin first, so that other origins cannot probe the routes
+	if !c.setOriginHeaders(w, origin) {
+		ServeError(ctx, w, http.StatusForbidden, ErrorCodeForbidden, "origin '"+origin+"' is not allowed", nil)
+		return true
+	}
+
+	methods := allowedMethods(eps, r.URL.Path)
+	if len(methods) == 0 {
+		ServeError(ctx, w, http.StatusNotFound, ErrorCodeNotFound, "Endpoint not found", nil)
+		return true
+	}
+
+	w.Header().Set(httpHeaderAccessControlAllowMethods, strings.Join(methods, ", "))
+
+	if len(c.AllowedHeaders) > 0 {
+		w.Header().Set(httpHeaderAccessControlAllowHeaders, strings.Join(c.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get(httpHeaderAccessControlRequestHeaders); requested != "" {
+		w.Header().Set(httpHeaderAccessControlAllowHeaders, requested)
+		w.Header().Add(httpHeaderVary, httpHeaderAccessControlRequestHeaders)
+	}
+
+	if c.MaxAge > 0 {
+		w.Header().Set(httpHeaderAccessControlMaxAge, strconv.Itoa(c.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowedMethods lists the methods of all endpoints registered for the path
+func allowedMethods(eps endpoints, path string) (methods []string) {
+
+	known := map[string]bool{}
+
+	for _, ep := range eps {
+		desc := ep.getDescriptor()
+		if _, match := desc.matchPath(path); !match {
+			continue
+		}
+		switch desc.method {
+		case "{any}", "*":
+			for _, m := range []string{
+				http.MethodGet,
+				http.MethodHead,
+				http.MethodPost,
+				http.MethodPut,
+				http.MethodPatch,
+				http.MethodDelete,
+			} {
+				known[m] = true
+			}
+		default:
+			known[desc.method] = true
+		}
+	}
+
+	if len(known) == 0 {
+		return
+	}
+
+	known[http.MethodOptions] = true
+
+	for m := range known {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	return
+}
@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type corsAPI struct {
+	GetItem convAPI.OutP1[string, string] `api:"GET /test/v1/items/{item}"`
+	PutItem convAPI.InP1[string, string]  `api:"PUT /test/v1/items/{item}"`
+}
+
+func Test_cors(t *testing.T) {
+
+	ctx := convCtx.New(convAuth.Claims{User: "Test_cors"})
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{"GET /test/v1/items/{any}"},
+	}
+
+	srv, err := convAPI.NewServer(ctx, "localhost", portForAPITest(t), policy, &corsAPI{
+		GetItem: convAPI.NewOutP1(func(ctx convCtx.Context, item string) (string, error) {
+			return item, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	srv.EnableCORS(convAPI.CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(ctx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	url := fmt.Sprintf("https://localhost:%d", portForAPITest(t))
+
+	do := func(method, path string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url+path, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() = %v; want nil", err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do() = %v; want nil", err)
+		}
+		return res
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		res := do(http.MethodOptions, "/test/v1/items/abc", map[string]string{
+			"Origin":                         "https://app.example.com",
+			"Access-Control-Request-Method":  http.MethodPut,
+			"Access-Control-Request-Headers": "Authorization, Content-Type",
+		})
+
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusNoContent)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Methods"); got != "GET, OPTIONS, PUT" {
+			t.Errorf("Access-Control-Allow-Methods = %q", got)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Headers"); got != "Authorization, Content-Type" {
+			t.Errorf("Access-Control-Allow-Headers = %q", got)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Access-Control-Allow-Credentials = %q", got)
+		}
+		if got := res.Header.Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("Access-Control-Max-Age = %q", got)
+		}
+	})
+
+	t.Run("preflight_unknown_origin", func(t *testing.T) {
+		res := do(http.MethodOptions, "/test/v1/items/abc", map[string]string{
+			"Origin":                        "https://evil.example.com",
+			"Access-Control-Request-Method": http.MethodGet,
+		})
+
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusForbidden)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q; want empty", got)
+		}
+	})
+
+	t.Run("preflight_unknown_origin_and_path", func(t *testing.T) {
+		res := do(http.MethodOptions, "/test/v1/unknown", map[string]string{
+			"Origin":                        "https://evil.example.com",
+			"Access-Control-Request-Method": http.MethodGet,
+		})
+
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusForbidden)
+		}
+	})
+
+	t.Run("preflight_unknown_path", func(t *testing.T) {
+		res := do(http.MethodOptions, "/test/v1/unknown", map[string]string{
+			"Origin":                        "https://app.example.com",
+			"Access-Control-Request-Method": http.MethodGet,
+		})
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("actual_request", func(t *testing.T) {
+		res := do(http.MethodGet, "/test/v1/items/abc", map[string]string{
+			"Origin": "https://app.example.com",
+		})
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusOK)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"reflect"
 	"strings"
 	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
 )
 
 func newDescriptor(host string, port int, pattern string, in, out reflect.Type) (desc descriptor) {
@@ -86,6 +88,16 @@ type descriptor struct {
 	query    []queryParam
 	weight   int
 	open     bool
+	audit    auditPolicy
+	timeout  time.Duration
+	guard    *clientGuard // client side circuit breaker and bulkhead
+	balancer *balancer    // client side instances of the target
+	perms    []convAuth.Permission
+	public   bool
+	params   []reflect.Type // of the path parameters, in order
+	binding  *binding       // of the params struct of Params endpoints
+	fields   bool           // output shaped by the fields query parameter
+	tagErr   error          // of an invalid struct tag; fails NewServer
 
 	in, out *object
 }
@@ -149,6 +161,7 @@ type object struct {
 	Elem      *object            `json:"elem"`
 	Key       *object            `json:"key"`
 	Fields    map[string]*object `json:"fields"`
+	Encoding  objectEncoding     `json:"encoding,omitempty"`
 }
 
 func snakeName(name string) string {
@@ -248,12 +261,19 @@ func objectFromType(t reflect.Type, optional bool, knownObjects ...*object) (o *
 
 	for _, known := range knownObjects {
 		if known.ID == o.ID {
-			return known
+			if known.Type != objectTypeObject {
+				return known
+			}
+			ref := *known // optional when referenced through a pointer
+			ref.Mandatory = known.Mandatory && !isPointer
+			return &ref
 		}
 	}
 
 	knownObjects = append(knownObjects, o)
 
+	o.Encoding = encodingOfType(t)
+
 	switch t.Kind() {
 
 	case reflect.Bool:
@@ -351,7 +371,12 @@ func (desc *descriptor) match(r *http.Request) (values values, match bool) {
 		return
 	}
 
-	urlSplit := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	return desc.matchPath(r.URL.Path)
+}
+
+func (desc *descriptor) matchPath(path string) (values values, match bool) {
+
+	urlSplit := strings.Split(strings.Trim(path, "/"), "/")
 
 	if len(urlSplit) < len(desc.segments) {
 		match = false
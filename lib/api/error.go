@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	convAuth "github.com/sofmon/convention/lib/auth"
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
@@ -18,6 +19,9 @@ const (
 	ErrorCodeBadRequest           ErrorCode = "bad_request"
 	ErrorCodeForbidden            ErrorCode = "forbidden"
 	ErrorCodeUnauthorized         ErrorCode = "unauthorized"
+	ErrorCodeConflict             ErrorCode = "conflict"
+	ErrorCodeTimeout              ErrorCode = "timeout"
+	ErrorCodeUnavailable          ErrorCode = "unavailable"
 	ErrorCodeUnexpectedStatusCode ErrorCode = "unexpected_status_code"
 )
 
@@ -69,6 +73,10 @@ type Error struct {
 	Scope   string    `json:"scope,omitempty"`
 	Message string    `json:"message,omitempty"`
 	Inner   *Error    `json:"inner,omitempty"`
+
+	// Explanation is the decision of the policy on forbidden calls, outside
+	// production environments
+	Explanation *convAuth.Explanation `json:"explanation,omitempty"`
 }
 
 func (e Error) Error() string {
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// TokenExchange exchanges the tokens of an external OpenID Connect provider
+// for tokens of the agents (OAuth 2.0 Token Exchange, RFC 8693). Requests are
+// form encoded:
+//
+//	grant_type=urn:ietf:params:oauth:grant-type:token-exchange
+//	subject_token=<ID or access token of the provider>
+//	subject_token_type=urn:ietf:params:oauth:token-type:id_token (or access_token, jwt)
+//	audience=<agent> (optional, repeatable; one of WithAudiences)
+//
+// and answered with the minted token:
+//
+//	{"access_token": "…", "issued_token_type": "urn:ietf:params:oauth:token-type:jwt", "token_type": "Bearer", "expires_in": 3600}
+//
+// Failures are answered with the OAuth error responses of RFC 6749, so OAuth
+// clients understand them. The endpoint must be public.
+type TokenExchange struct {
+	descriptor descriptor
+	provider   *convAuth.OIDCProvider
+	ttl        time.Duration
+	audiences  []string
+}
+
+const (
+	tokenExchangeGrantType   = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeJWT             = "urn:ietf:params:oauth:token-type:jwt"
+	tokenTypeIDToken         = "urn:ietf:params:oauth:token-type:id_token"
+	tokenTypeAccessToken     = "urn:ietf:params:oauth:token-type:access_token"
+	tokenExchangeFormMaxSize = 64 << 10
+)
+
+var tokenExchangeSubjectTypes = []string{tokenTypeIDToken, tokenTypeAccessToken, tokenTypeJWT}
+
+// TokenExchangeResponse is the response of a successful exchange
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"` // seconds
+}
+
+type tokenExchangeError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewTokenExchange returns an endpoint minting tokens for the claims of the
+// tokens of the provider, that expire after convAuth.TokenTTL but never after
+// the token of the provider.
+func NewTokenExchange(provider *convAuth.OIDCProvider) TokenExchange {
+	return TokenExchange{provider: provider}
+}
+
+// WithTTL sets the lifetime of the minted tokens
+func (x TokenExchange) WithTTL(ttl time.Duration) TokenExchange {
+	x.ttl = ttl
+	return x
+}
+
+// WithAudiences sets the agents the minted tokens may be issued for; a
+// request without audience gets a token for all of them. Without audiences
+// requests asking for one are rejected.
+func (x TokenExchange) WithAudiences(audiences ...string) TokenExchange {
+	x.audiences = audiences
+	return x
+}
+
+func (x *TokenExchange) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	_, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, tokenExchangeFormMaxSize)
+	if err := r.ParseForm(); err != nil {
+		serveTokenExchangeError(w, http.StatusBadRequest, "invalid_request", "the request must be form encoded")
+		return true
+	}
+
+	if r.PostForm.Get("grant_type") != tokenExchangeGrantType {
+		serveTokenExchangeError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be "+tokenExchangeGrantType)
+		return true
+	}
+
+	subject := r.PostForm.Get("subject_token")
+	if subject == "" || !slices.Contains(tokenExchangeSubjectTypes, r.PostForm.Get("subject_token_type")) {
+		serveTokenExchangeError(w, http.StatusBadRequest, "invalid_request", "subject_token of type id_token, access_token or jwt is required")
+		return true
+	}
+
+	audience := r.PostForm["audience"]
+	if len(audience) == 0 {
+		audience = x.audiences
+	}
+	for _, a := range audience {
+		if !slices.Contains(x.audiences, a) {
+			serveTokenExchangeError(w, http.StatusBadRequest, "invalid_target", "audience '"+a+"' is not allowed")
+			return true
+		}
+	}
+
+	now := ctx.Now()
+
+	claims, err := x.provider.VerifyAt(subject, now)
+	if err != nil {
+		ctx.Logger().Warn("token exchange rejected the subject token", "error", err.Error())
+		serveTokenExchangeError(w, http.StatusBadRequest, "invalid_grant", "the subject token is invalid or expired")
+		return true
+	}
+
+	ttl := x.ttl
+	if ttl == 0 {
+		ttl = convAuth.TokenTTL
+	}
+	if left := claims.Registered.ExpiresAt.Sub(now); left < ttl {
+		ttl = left // never outlives the subject token
+	}
+
+	claims.Registered = convAuth.RegisteredClaims{} // minted anew
+
+	token, expiresAt, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{
+		Audience: audience,
+		TTL:      ttl,
+		IssuedAt: now, // valid at the time of the agent, also on time travel
+	})
+	if err != nil {
+		ctx.Logger().Error("token exchange failed to mint a token", "error", err.Error())
+		serveTokenExchangeError(w, http.StatusInternalServerError, "server_error", "")
+		return true
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	ServeJSON(w, TokenExchangeResponse{
+		AccessToken:     token,
+		IssuedTokenType: tokenTypeJWT,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(expiresAt.Sub(now).Seconds()),
+	})
+
+	return true
+}
+
+func serveTokenExchangeError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tokenExchangeError{Error: code, Description: description})
+}
+
+func (x *TokenExchange) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *TokenExchange) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *TokenExchange) getInOutTypes() (in, out reflect.Type) {
+	return nil, reflect.TypeFor[TokenExchangeResponse]()
+}
+
+func (x *TokenExchange) setEndpoints(eps endpoints) {}
@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type exchangeAPI struct {
+	Exchange convAPI.TokenExchange `api:"POST /auth/v1/token"`
+}
+
+func Test_token_exchange(t *testing.T) {
+
+	// a local stand-in of the identity provider
+	idpKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	point, _ := idpKey.PublicKey.Bytes()
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	jwks, _ := json.Marshal(convAuth.JSONWebKeySet{Keys: []convAuth.JSONWebKey{
+		{KID: "idp-1", Kty: "EC", Alg: "ES256", Use: "sig", Crv: "P-256", X: b64(point[1:33]), Y: b64(point[33:])},
+	}})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(jwksFile, jwks, 0600)
+
+	provider, err := convAuth.NewOIDCProvider(convAuth.OIDCConfig{
+		Issuer:    "https://id.example.com",
+		Audiences: []string{"mobile-app"},
+		JWKSFile:  jwksFile,
+		Mapping: convAuth.OIDCMapping{
+			UserClaim: "email",
+			Groups: map[string]convAuth.OIDCGroup{
+				"buyers": {Tenants: convAuth.Tenants{"acme"}, Roles: convAuth.Roles{"buyer"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() = %v; want nil", err)
+	}
+
+	idToken := func(exp time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":    "https://id.example.com",
+			"aud":    "mobile-app",
+			"sub":    "0f3c",
+			"email":  "jane@example.com",
+			"groups": []string{"buyers"},
+			"iat":    time.Now().Unix(),
+			"exp":    exp.Unix(),
+		})
+		token.Header["kid"] = "idp-1"
+		res, _ := token.SignedString(idpKey)
+		return res
+	}
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"POST /auth/v1/token",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_token_exchange"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &exchangeAPI{
+		Exchange: convAPI.NewTokenExchange(provider).WithTTL(10 * time.Minute).WithAudiences("orders", "billing"),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	exchange := func(t *testing.T, form url.Values) (status int, body map[string]any) {
+		t.Helper()
+		res, err := http.PostForm(fmt.Sprintf("https://localhost:%d/auth/v1/token", port), form)
+		if err != nil {
+			t.Fatalf("http.PostForm() = %v; want nil", err)
+		}
+		defer res.Body.Close()
+		json.NewDecoder(res.Body).Decode(&body)
+		return res.StatusCode, body
+	}
+
+	form := func(subject string) url.Values {
+		return url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {subject},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:id_token"},
+			"audience":           {"orders"},
+		}
+	}
+
+	t.Run("exchanged", func(t *testing.T) {
+		status, body := exchange(t, form(idToken(time.Now().Add(time.Hour))))
+		if status != http.StatusOK {
+			t.Fatalf("status = %d (%v); want %d", status, body, http.StatusOK)
+		}
+		if body["token_type"] != "Bearer" || body["expires_in"] != float64(600) {
+			t.Fatalf("response = %v; want a Bearer token expiring in 600 seconds", body)
+		}
+
+		token, _ := body["access_token"].(string)
+		claims, err := convAuth.DecodeToken(token, "orders")
+		if err != nil {
+			t.Fatalf("DecodeToken(access_token) = %v; want nil", err)
+		}
+		if claims.User != "jane@example.com" || !reflect.DeepEqual(claims.Tenants, convAuth.Tenants{"acme"}) || !reflect.DeepEqual(claims.Roles, convAuth.Roles{"buyer"}) {
+			t.Fatalf("claims = %+v; want the mapped claims", claims)
+		}
+	})
+
+	t.Run("invalid_grant", func(t *testing.T) {
+		status, body := exchange(t, form(idToken(time.Now().Add(-time.Hour))))
+		if status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+			t.Fatalf("expired subject token = %d %v; want 400 invalid_grant", status, body)
+		}
+	})
+
+	t.Run("unsupported_grant_type", func(t *testing.T) {
+		f := form(idToken(time.Now().Add(time.Hour)))
+		f.Set("grant_type", "password")
+		status, body := exchange(t, f)
+		if status != http.StatusBadRequest || body["error"] != "unsupported_grant_type" {
+			t.Fatalf("password grant = %d %v; want 400 unsupported_grant_type", status, body)
+		}
+	})
+	t.Run("capped_ttl", func(t *testing.T) {
+		status, body := exchange(t, form(idToken(time.Now().Add(30*time.Second))))
+		if status != http.StatusOK {
+			t.Fatalf("status = %d (%v); want %d", status, body, http.StatusOK)
+		}
+		if expiresIn, _ := body["expires_in"].(float64); expiresIn > 30 {
+			t.Fatalf("expires_in = %v; want at most the 30 seconds left of the subject token", body["expires_in"])
+		}
+	})
+
+	t.Run("default_audiences", func(t *testing.T) {
+		f := form(idToken(time.Now().Add(time.Hour)))
+		f.Del("audience")
+		status, body := exchange(t, f)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d (%v); want %d", status, body, http.StatusOK)
+		}
+
+		token, _ := body["access_token"].(string)
+		if _, err := convAuth.DecodeToken(token, "billing"); err != nil {
+			t.Fatalf("DecodeToken(access_token, billing) = %v; want nil", err)
+		}
+	})
+
+	t.Run("invalid_target", func(t *testing.T) {
+		f := form(idToken(time.Now().Add(time.Hour)))
+		f.Add("audience", "payroll")
+		status, body := exchange(t, f)
+		if status != http.StatusBadRequest || body["error"] != "invalid_target" {
+			t.Fatalf("audience not allowed = %d %v; want 400 invalid_target", status, body)
+		}
+	})
+}
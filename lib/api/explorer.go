@@ -0,0 +1,89 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// Explorer serves a self-contained HTML page to browse the OpenAPI document
+// of the agent and call its endpoints from the browser, with a bearer token
+// and the Workflow and Time-Now headers set by hand. It is not served in
+// production environments unless enabled with EnabledInProd.
+
+//go:embed explorer.html
+var explorerHTML string
+
+const explorerSpecPlaceholder = "{{spec}}"
+
+var isProdEnv = func(ctx convCtx.Context) bool {
+	return ctx.IsProdEnv()
+}
+
+type Explorer struct {
+	descriptor descriptor
+	endpoints  endpoints
+	inProd     bool
+}
+
+func NewExplorer() Explorer {
+	return Explorer{}
+}
+
+// EnabledInProd serves the explorer in production environments too
+func (x Explorer) EnabledInProd() Explorer {
+	x.inProd = true
+	return x
+}
+
+func (x *Explorer) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	_, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	if isProdEnv(ctx) && !x.inProd {
+		return false // as if not defined
+	}
+
+	var spec string
+	for _, ep := range x.endpoints {
+		if oa, ok := ep.(*OpenAPI); ok {
+			desc := oa.getDescriptor()
+			spec = desc.path()
+			break
+		}
+	}
+	if spec == "" {
+		ServeError(ctx, w, http.StatusNotFound, ErrorCodeNotFound, "no OpenAPI endpoint to explore", nil)
+		return true
+	}
+
+	specJSON, _ := json.Marshal(spec) // escapes <, > and & for the script
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(strings.Replace(explorerHTML, explorerSpecPlaceholder, string(specJSON), 1)))
+
+	return true
+}
+
+func (x *Explorer) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *Explorer) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *Explorer) getInOutTypes() (in, out reflect.Type) {
+	return nil, nil
+}
+
+func (x *Explorer) setEndpoints(eps endpoints) {
+	x.endpoints = eps
+}
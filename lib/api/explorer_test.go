@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type explorerAPI struct {
+	Ping         convAPI.Out[string] `api:"GET /test/v1/ping"`
+	OpenAPI      convAPI.OpenAPI     `api:"GET /test/v1/openapi.yaml"`
+	Explorer     convAPI.Explorer    `api:"GET /test/v1/explorer"`
+	ProdExplorer convAPI.Explorer    `api:"GET /test/v1/prod/explorer"`
+}
+
+type explorerNoSpecAPI struct {
+	Explorer convAPI.Explorer `api:"GET /test/v1/explorer"`
+}
+
+func Test_explorer(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/ping",
+			"GET /test/v1/openapi.yaml",
+			"GET /test/v1/explorer",
+			"GET /test/v1/prod/explorer",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_explorer"})
+
+	port := portForAPITest(t)
+	noSpecPort := portForAPITestAgent(t, "no_spec")
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &explorerAPI{
+		Ping: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "pong", nil
+		}),
+		OpenAPI:      convAPI.NewOpenAPI(),
+		Explorer:     convAPI.NewExplorer(),
+		ProdExplorer: convAPI.NewExplorer().EnabledInProd(),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	noSpecSrv, err := convAPI.NewServer(agentCtx, "localhost", noSpecPort, policy, &explorerNoSpecAPI{
+		Explorer: convAPI.NewExplorer(),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go noSpecSrv.ListenAndServe()
+	defer noSpecSrv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	get := func(t *testing.T, port int, path string) (res *http.Response, body string) {
+		t.Helper()
+		res, err := http.Get(fmt.Sprintf("https://localhost:%d%s", port, path))
+		if err != nil {
+			t.Fatalf("http.Get() = %v; want nil", err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res, string(data)
+	}
+
+	t.Run("serves_page", func(t *testing.T) {
+		res, body := get(t, port, "/test/v1/explorer")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusOK)
+		}
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("Content-Type = %s; want text/html", ct)
+		}
+		if !strings.Contains(body, `const specPath = "/test/v1/openapi.yaml";`) {
+			t.Fatalf("page does not load the spec of the agent")
+		}
+		for _, h := range []string{"Authorization", "Workflow", "Time-Now"} {
+			if !strings.Contains(body, `"`+h+`"`) {
+				t.Fatalf("page does not set the %s header", h)
+			}
+		}
+		if strings.Contains(body, `src="http`) || strings.Contains(body, `href="http`) {
+			t.Fatalf("page loads external resources")
+		}
+	})
+
+	t.Run("not_in_prod", func(t *testing.T) {
+		defer convAPI.SetProdEnvForTest(true)()
+
+		res, _ := get(t, port, "/test/v1/explorer")
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusNotFound)
+		}
+
+		res, _ = get(t, port, "/test/v1/prod/explorer")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d; want %d for an explorer enabled in prod", res.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("no_spec", func(t *testing.T) {
+		res, _ := get(t, noSpecPort, "/test/v1/explorer")
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+)
+
+// Test seams (compiled only into the package's test binary).
+
+// SetAsyncIntervalsForTest shortens the heartbeat and polling intervals for
+// tests and returns a restore func.
+func SetAsyncIntervalsForTest(heartbeat, poll time.Duration) (restore func()) {
+	oh, op := asyncHeartbeatInterval, asyncPollInterval
+	asyncHeartbeatInterval, asyncPollInterval = heartbeat, poll
+	return func() { asyncHeartbeatInterval, asyncPollInterval = oh, op }
+}
+
+// SetProdEnvForTest makes the explorer and the forbidden errors see a
+// production environment and returns a restore func.
+func SetProdEnvForTest(prod bool) (restore func()) {
+	o := isProdEnv
+	isProdEnv = func(convCtx.Context) bool { return prod }
+	return func() { isProdEnv = o }
+}
+
+// InsertOrphanedOperationForTest stores a running operation whose owner is
+// gone, as left behind by a pod restart.
+func InsertOrphanedOperationForTest(ctx convCtx.Context, vault convDB.Vault, tenant convAuth.Tenant, endpoint string, vals []string, in any) (id OperationID, err error) {
+
+	input, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+
+	stale := time.Now().UTC().Add(-time.Hour)
+
+	op := asyncOperation{
+		Operation: Operation{
+			ID:        OperationID(uuid.NewString()),
+			State:     OperationStateRunning,
+			CreatedAt: stale,
+			UpdatedAt: stale,
+		},
+		Endpoint:    endpoint,
+		Values:      vals,
+		Input:       input,
+		Claims:      ctx.Claims(),
+		Workflow:    ctx.Workflow(),
+		Owner:       "restarted-pod",
+		HeartbeatAt: stale,
+	}
+
+	err = convDB.NewObjectSet[asyncOperation](vault).Ready().Tenant(tenant).Insert(ctx, op)
+	if err != nil {
+		return
+	}
+
+	return op.ID, nil
+}
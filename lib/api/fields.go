@@ -0,0 +1,364 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// Read endpoints (Out, OutP1-5 and OutParams) tagged `fields:"true"` shape
+// their output by the fields query parameter of a call:
+//
+//	?fields=name,address.city   only the listed JSON paths
+//	?fields=-notes,-items.raw   all but the listed JSON paths
+//
+// Paths into lists apply to their elements, and into maps to their keys.
+
+const queryFields = "fields"
+
+const queryFieldsDescription = "Comma separated JSON paths of the fields to return (e.g. name,address.city); prefix each with - to return all but them"
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	errMixedFields    = errors.New("fields cannot be both selected and excluded")
+)
+
+func parseFieldsTag(tag string) (bool, error) {
+	if tag == "" {
+		return false, nil
+	}
+	fields, err := strconv.ParseBool(tag)
+	if err != nil {
+		return false, fmt.Errorf("invalid fields tag: %w", err)
+	}
+	return fields, nil
+}
+
+// fieldTree is the JSON fields of a shaped value; nil for the whole value
+type fieldTree map[string]fieldTree
+
+func (t fieldTree) add(path []string) {
+	sub, ok := t[path[0]]
+	if ok && sub == nil {
+		return // already the whole value
+	}
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+	if sub == nil {
+		sub = fieldTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+type shape struct {
+	tree    fieldTree
+	exclude bool
+}
+
+// parseFields parses the value of the fields query parameter
+func parseFields(s string) (paths []string, exclude bool, err error) {
+	for i, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		excluded := strings.HasPrefix(p, "-")
+		switch {
+		case i == 0:
+			exclude = excluded
+		case excluded != exclude:
+			return nil, false, errMixedFields
+		}
+		p = strings.TrimPrefix(p, "-")
+		if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.Contains(p, "..") {
+			return nil, false, fmt.Errorf("invalid field path '%s'", p)
+		}
+		paths = append(paths, p)
+	}
+	return
+}
+
+// Fields returns the JSON paths selected by the fields query parameter of
+// the call, e.g. to load only them with SelectProjected of convDB; nil when
+// the parameter is absent, invalid or excludes fields.
+func Fields(ctx convCtx.Context) []string {
+	r := ctx.Request()
+	if r == nil {
+		return nil
+	}
+	s := r.URL.Query().Get(queryFields)
+	if s == "" {
+		return nil
+	}
+	paths, exclude, err := parseFields(s)
+	if err != nil || exclude {
+		return nil
+	}
+	return paths
+}
+
+// parseShape parses the fields query parameter of endpoints allowing it,
+// serving bad request when it does not fit the output; nil shape for the
+// whole output.
+func (desc *descriptor) parseShape(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, out reflect.Type) (sh *shape, ok bool) {
+
+	if !desc.fields {
+		return nil, true
+	}
+
+	s := r.URL.Query().Get(queryFields)
+	if s == "" {
+		return nil, true
+	}
+
+	paths, exclude, err := parseFields(s)
+	if err == nil {
+		sh = &shape{tree: fieldTree{}, exclude: exclude}
+		for _, p := range paths {
+			sh.tree.add(strings.Split(p, "."))
+		}
+		err = sh.tree.check(out, "")
+	}
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "invalid fields query parameter", err)
+		return nil, false
+	}
+
+	return sh, true
+}
+
+// serveShaped serves the output shaped by sh; the whole output for nil sh
+func serveShaped(w http.ResponseWriter, out any, sh *shape) {
+	if sh == nil {
+		ServeJSON(w, out)
+		return
+	}
+	ServeJSON(w, shapeValue(reflect.ValueOf(out), sh.tree, sh.exclude))
+}
+
+// isWhole tells whether values of the type are encoded as a whole
+func isWhole(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// check fails on paths not matching the JSON fields of the type
+func (t fieldTree) check(typ reflect.Type, prefix string) error {
+
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		if isWhole(typ) {
+			break
+		}
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() == reflect.Interface {
+		return nil // known when served
+	}
+
+	if typ.Kind() == reflect.Map && !isWhole(typ) {
+		for name, sub := range t {
+			if sub != nil {
+				err := sub.check(typ.Elem(), prefix+name+".")
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if typ.Kind() != reflect.Struct || isWhole(typ) {
+		if prefix == "" {
+			return errors.New("the output has no fields")
+		}
+		return fmt.Errorf("field '%s' has no fields", strings.TrimSuffix(prefix, "."))
+	}
+
+	for name, sub := range t {
+		f, ok := jsonFieldByName(typ, name)
+		if !ok {
+			return fmt.Errorf("unknown field '%s'", prefix+name)
+		}
+		if sub != nil {
+			err := sub.check(f.typ, prefix+name+".")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type jsonField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields returns the fields of a struct as encoded by encoding/json,
+// with the fields of embedded structs promoted
+func jsonFields(t reflect.Type) (fields []jsonField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, sub := range jsonFields(ft) {
+					sub.index = append([]int{i}, sub.index...)
+					fields = append(fields, sub)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{
+			name:      name,
+			index:     []int{i},
+			typ:       f.Type,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return
+}
+
+func jsonFieldByName(t reflect.Type, name string) (jsonField, bool) {
+	for _, f := range jsonFields(t) {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return jsonField{}, false
+}
+
+// shapeValue returns the value with only the fields of the tree (all but
+// them when excluding) for JSON encoding
+func shapeValue(v reflect.Value, tree fieldTree, exclude bool) any {
+
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !isWhole(v.Type()) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	if tree == nil || isWhole(v.Type()) {
+		return v.Interface()
+	}
+
+	// field returns the shaped value of a listed field, and whether to keep it
+	field := func(name string, fv reflect.Value) (any, bool) {
+		sub, listed := tree[name]
+		switch {
+		case !exclude && !listed:
+			return nil, false
+		case !exclude:
+			return shapeValue(fv, sub, false), true
+		case listed && sub == nil:
+			return nil, false
+		case listed:
+			return shapeValue(fv, sub, true), true
+		default:
+			return fv.Interface(), true
+		}
+	}
+
+	switch v.Kind() {
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		res := make([]any, v.Len())
+		for i := range res {
+			res[i] = shapeValue(v.Index(i), tree, exclude)
+		}
+		return res
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		res := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			name := mapKeyName(iter.Key())
+			if fv, ok := field(name, iter.Value()); ok {
+				res[name] = fv
+			}
+		}
+		return res
+
+	case reflect.Struct:
+		res := map[string]any{}
+		for _, f := range jsonFields(v.Type()) {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				continue // of a nil embedded struct
+			}
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			if sv, ok := field(f.name, fv); ok {
+				res[f.name] = sv
+			}
+		}
+		return res
+
+	default:
+		return v.Interface()
+	}
+}
+
+// mapKeyName returns the map key as encoded by encoding/json
+func mapKeyName(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, _ := tm.MarshalText()
+		return string(text)
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// isEmptyValue reports the values omitted by the omitempty option of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type fieldsAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+type fieldsLine struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type fieldsOrder struct {
+	ID      string            `json:"id"`
+	Notes   string            `json:"notes,omitempty"`
+	Address *fieldsAddress    `json:"address"`
+	Lines   []fieldsLine      `json:"lines"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+}
+
+type fieldsAPI struct {
+	List  convAPI.Out[[]fieldsOrder]         `api:"GET /test/v1/orders" fields:"true"`
+	Get   convAPI.OutP1[fieldsOrder, string] `api:"GET /test/v1/orders/{id}" fields:"true"`
+	Plain convAPI.Out[fieldsOrder]           `api:"GET /test/v1/plain"`
+}
+
+func Test_fields(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/orders",
+			"GET /test/v1/orders/{any}",
+			"GET /test/v1/plain",
+		},
+	}
+
+	order := fieldsOrder{
+		ID:      "o1",
+		Address: &fieldsAddress{City: "Sofia", Street: "Vitosha"},
+		Lines:   []fieldsLine{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}},
+		Labels:  map[string]string{"color": "red", "size": "xl"},
+		Created: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC),
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_fields"})
+
+	port := portForAPITest(t)
+
+	requested := make(chan []string, 16)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &fieldsAPI{
+		List: convAPI.NewOut(func(ctx convCtx.Context) ([]fieldsOrder, error) {
+			return []fieldsOrder{order, {ID: "o2", Notes: "n"}}, nil
+		}),
+		Get: convAPI.NewOutP1(func(ctx convCtx.Context, id string) (fieldsOrder, error) {
+			requested <- convAPI.Fields(ctx)
+			return order, nil
+		}),
+		Plain: convAPI.NewOut(func(ctx convCtx.Context) (fieldsOrder, error) {
+			return order, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	get := func(t *testing.T, path, fields string) (status int, body any) {
+		t.Helper()
+		res, err := http.Get(fmt.Sprintf("https://localhost:%d%s?fields=%s", port, path, url.QueryEscape(fields)))
+		if err != nil {
+			t.Fatalf("http.Get() = %v; want nil", err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		if res.StatusCode == http.StatusOK {
+			err = json.Unmarshal(data, &body)
+			if err != nil {
+				t.Fatalf("json.Unmarshal() = %v; want nil", err)
+			}
+		}
+		return res.StatusCode, body
+	}
+
+	decode := func(s string) (v any) {
+		json.Unmarshal([]byte(s), &v)
+		return
+	}
+
+	for _, c := range []struct {
+		name, path, fields, want string
+	}{
+		{"select", "/test/v1/orders/o1", "id,address.city", `{"id":"o1","address":{"city":"Sofia"}}`},
+		{"select_in_lists", "/test/v1/orders", "id,lines.qty", `[{"id":"o1","lines":[{"qty":1},{"qty":2}]},{"id":"o2","lines":null}]`},
+		{"select_map_keys", "/test/v1/orders/o1", "labels.size", `{"labels":{"size":"xl"}}`},
+		{"select_whole_value", "/test/v1/orders/o1", "created,address", `{"created":"2026-03-14T00:00:00Z","address":{"city":"Sofia","street":"Vitosha"}}`},
+		{"exclude", "/test/v1/orders", "-lines,-labels,-address.street,-created", `[{"id":"o1","address":{"city":"Sofia"}},{"id":"o2","notes":"n","address":null}]`},
+		{"not_enabled", "/test/v1/plain", "id", `{"id":"o1","address":{"city":"Sofia","street":"Vitosha"},"lines":[{"sku":"a","qty":1},{"sku":"b","qty":2}],"labels":{"color":"red","size":"xl"},"created":"2026-03-14T00:00:00Z"}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			status, body := get(t, c.path, c.fields)
+			if status != http.StatusOK || !reflect.DeepEqual(body, decode(c.want)) {
+				t.Fatalf("GET %s?fields=%s = %d %v; want %s", c.path, c.fields, status, body, c.want)
+			}
+		})
+	}
+	for len(requested) > 0 {
+		<-requested
+	}
+
+	t.Run("handler_fields", func(t *testing.T) {
+		get(t, "/test/v1/orders/o1", "id, address.city")
+		if got := <-requested; strings.Join(got, ",") != "id,address.city" {
+			t.Fatalf("Fields() = %v; want [id address.city]", got)
+		}
+		get(t, "/test/v1/orders/o1", "-notes")
+		if got := <-requested; got != nil {
+			t.Fatalf("Fields() when excluding = %v; want nil", got)
+		}
+	})
+
+	for _, c := range []struct{ name, fields string }{
+		{"unknown_field", "id,address.zip"},
+		{"mixed", "id,-notes"},
+		{"into_whole_value", "created.year"},
+		{"empty_path", "id,,notes"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			status, _ := get(t, "/test/v1/orders", c.fields)
+			if status != http.StatusBadRequest {
+				t.Fatalf("GET /test/v1/orders?fields=%s = %d; want 400", c.fields, status)
+			}
+		})
+	}
+
+	t.Run("invalid_tag", func(t *testing.T) {
+		_, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &struct {
+			Get convAPI.Out[string] `api:"GET /test/v1/orders" fields:"yes"`
+		}{})
+		if err == nil {
+			t.Fatal("NewServer() with an invalid fields tag = nil; want error")
+		}
+	})
+}
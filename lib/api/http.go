@@ -29,7 +29,18 @@ func setContextHttpHeaders(ctx convCtx.Context, r *http.Request) (err error) {
 	r.Header.Add(convCtx.HttpHeaderWorkflow, string(ctx.Workflow()))
 	r.Header.Add(httpHeaderAgent, string(ctx.Agent()))
 
-	err = convAuth.EncodeHTTPRequestClaims(r, ctx.Claims())
+	// share the deadline of the caller with the called agent
+	if budget, ok := timeBudget(ctx); ok {
+		if ctx.Err() != nil {
+			err = newError(ctx, http.StatusGatewayTimeout, ErrorCodeTimeout, "no time left for the call", ctx.Err())
+			return
+		}
+		r.Header.Set(convCtx.HttpHeaderTimeBudget, budget)
+	}
+	*r = *r.WithContext(ctx)
+
+	// short-lived token bound to the called agent
+	err = convAuth.EncodeHTTPRequestServiceClaims(r, ctx.Claims(), r.URL.Hostname())
 	if err != nil {
 		return
 	}
@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type audienceAPI struct {
+	WhoAmI convAPI.Out[convAuth.User] `api:"GET /test/v1/whoami"`
+}
+
+func Test_service_token_audience(t *testing.T) {
+
+	const (
+		roleAgent       convAuth.Role       = "agent"
+		permissionAgent convAuth.Permission = "agent"
+	)
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleAgent: convAuth.Permissions{permissionAgent},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionAgent: convAuth.Actions{"GET /test/v1/whoami"},
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_service_token_audience"})
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &audienceAPI{
+		WhoAmI: convAPI.NewOut(func(ctx convCtx.Context) (convAuth.User, error) {
+			return ctx.User(), nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	claims := convAuth.Claims{User: "caller-v1", Roles: convAuth.Roles{roleAgent}}
+
+	client := convAPI.NewClient[audienceAPI]("localhost", portForAPITest(t))
+
+	user, err := client.WhoAmI.Call(convCtx.New(claims))
+	if err != nil || user != claims.User {
+		t.Fatalf("WhoAmI() = %q, %v; want %q", user, err, claims.User)
+	}
+
+	token, _, err := convAuth.GenerateServiceToken(claims, "other-agent", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken() = %v; want nil", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/test/v1/whoami", portForAPITest(t)), nil)
+	req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v; want nil", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("call with a token for another agent = %d; want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	// tokens bound to the agent name are accepted as well
+	named, _, err := convAuth.GenerateServiceToken(claims, string(agentCtx.Agent()), time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken() = %v; want nil", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/test/v1/whoami", portForAPITest(t)), nil)
+	req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+named)
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v; want nil", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("call with a token for the agent name = %d; want %d", res.StatusCode, http.StatusOK)
+	}
+
+	// tokens without audience are rejected
+	legacy, err := convAuth.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("GenerateToken() = %v; want nil", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/test/v1/whoami", portForAPITest(t)), nil)
+	req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+legacy)
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v; want nil", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("call with a token without audience = %d; want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	// servers checking no audience do not start
+	unnamedCtx := convCtx.New(convAuth.Claims{})
+	unchecked, err := convAPI.NewServer(unnamedCtx, "127.0.0.1", portForAPITestAgent(t, "unchecked"), policy, &audienceAPI{})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- unchecked.ListenAndServe() }()
+	select {
+	case err = <-errs:
+		if err == nil {
+			t.Fatal("ListenAndServe() of a server checking no audience = nil; want error")
+		}
+	case <-time.After(time.Second):
+		unchecked.Shutdown(unnamedCtx)
+		t.Fatal("ListenAndServe() of a server checking no audience is serving; want error")
+	}
+}
@@ -113,7 +113,7 @@ func (x *In[inT]) Call(ctx convCtx.Context, in inT) (err error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInP1[inT any, p1T ~string](fn func(ctx convCtx.Context, p1 p1T, in inT) error) InP1[inT, p1T] {
+func NewInP1[inT, p1T any](fn func(ctx convCtx.Context, p1 p1T, in inT) error) InP1[inT, p1T] {
 	return InP1[inT, p1T]{
 		fn: fn,
 	}
@@ -40,7 +40,7 @@ func (x InP1[inT, p1T]) WithPostCheck(check Check) InP1[inT, p1T] {
 	}
 }
 
-type InP1[inT any, p1T ~string] struct {
+type InP1[inT, p1T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, in inT) error
 }
@@ -52,6 +52,11 @@ func (x *InP1[inT, p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter,
 		return false
 	}
 
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -61,7 +66,7 @@ func (x *InP1[inT, p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter,
 
 	err = x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
+		p1,
 		in,
 	)
 	if err != nil {
@@ -92,6 +97,10 @@ func (x *InP1[inT, p1T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *InP1[inT, p1T]) setEndpoints(eps endpoints) {}
 
+func (x *InP1[inT, p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
 func (x *InP1[inT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -104,8 +113,9 @@ func (x *InP1[inT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (err error) {
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
+	values, err := formatParams(p1)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -121,7 +131,7 @@ func (x *InP1[inT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (err error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInP2[inT any, p1T, p2T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) error) InP2[inT, p1T, p2T] {
+func NewInP2[inT, p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) error) InP2[inT, p1T, p2T] {
 	return InP2[inT, p1T, p2T]{
 		fn: fn,
 	}
@@ -40,7 +40,7 @@ func (x InP2[inT, p1T, p2T]) WithPostCheck(check Check) InP2[inT, p1T, p2T] {
 	}
 }
 
-type InP2[inT any, p1T, p2T ~string] struct {
+type InP2[inT, p1T, p2T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) error
 }
@@ -52,6 +52,14 @@ func (x *InP2[inT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.ResponseWr
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -61,8 +69,8 @@ func (x *InP2[inT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.ResponseWr
 
 	err = x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
+		p1,
+		p2,
 		in,
 	)
 	if err != nil {
@@ -92,6 +100,10 @@ func (x *InP2[inT, p1T, p2T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *InP2[inT, p1T, p2T]) setEndpoints(eps endpoints) {}
 
+func (x *InP2[inT, p1T, p2T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()}
+}
+
 func (x *InP2[inT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -104,9 +116,9 @@ func (x *InP2[inT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, in inT)
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
+	values, err := formatParams(p1, p2)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -122,7 +134,7 @@ func (x *InP2[inT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, in inT)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInP3[inT any, p1T, p2T, p3T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) error) InP3[inT, p1T, p2T, p3T] {
+func NewInP3[inT, p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) error) InP3[inT, p1T, p2T, p3T] {
 	return InP3[inT, p1T, p2T, p3T]{
 		fn: fn,
 	}
@@ -40,7 +40,7 @@ func (x InP3[inT, p1T, p2T, p3T]) WithPostCheck(check Check) InP3[inT, p1T, p2T,
 	}
 }
 
-type InP3[inT any, p1T, p2T, p3T ~string] struct {
+type InP3[inT, p1T, p2T, p3T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) error
 }
@@ -52,6 +52,15 @@ func (x *InP3[inT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.Respo
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -61,9 +70,9 @@ func (x *InP3[inT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.Respo
 
 	err = x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
+		p1,
+		p2,
+		p3,
 		in,
 	)
 	if err != nil {
@@ -94,6 +103,10 @@ func (x *InP3[inT, p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *InP3[inT, p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
 
+func (x *InP3[inT, p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
 func (x *InP3[inT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -106,10 +119,9 @@ func (x *InP3[inT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -125,7 +137,7 @@ func (x *InP3[inT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
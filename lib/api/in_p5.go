@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInP5[inT any, p1T, p2T, p3T, p4T, p5T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) error) InP5[inT, p1T, p2T, p3T, p4T, p5T] {
+func NewInP5[inT, p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) error) InP5[inT, p1T, p2T, p3T, p4T, p5T] {
 	return InP5[inT, p1T, p2T, p3T, p4T, p5T]{
 		fn: fn,
 	}
@@ -41,7 +41,7 @@ func (x InP5[inT, p1T, p2T, p3T, p4T, p5T]) WithPostCheck(check Check) InP5[inT,
 	}
 }
 
-type InP5[inT any, p1T, p2T, p3T, p4T, p5T ~string] struct {
+type InP5[inT, p1T, p2T, p3T, p4T, p5T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) error
 }
@@ -53,6 +53,17 @@ func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context, w
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -62,11 +73,11 @@ func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context, w
 
 	err = x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
-		p5T(values.GetByIndex(4)),
+		p1,
+		p2,
+		p3,
+		p4,
+		p5,
 		in,
 	)
 	if err != nil {
@@ -97,6 +108,10 @@ func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out reflect.Ty
 
 func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
 
+func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
 func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -109,12 +124,9 @@ func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
-		{Name: "", Value: string(p5)},
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -130,7 +142,7 @@ func (x *InP5[inT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
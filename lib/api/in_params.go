@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewInParams[inT, pT any](fn func(ctx convCtx.Context, p pT, in inT) error) InParams[inT, pT] {
+	return InParams[inT, pT]{
+		fn: fn,
+	}
+}
+
+func (x InParams[inT, pT]) WithPreCheck(check Check) InParams[inT, pT] {
+	return InParams[inT, pT]{
+		fn: func(ctx convCtx.Context, p pT, in inT) error {
+			err := check(ctx)
+			if err != nil {
+				return err
+			}
+			return x.fn(ctx, p, in)
+		},
+	}
+}
+
+func (x InParams[inT, pT]) WithPostCheck(check Check) InParams[inT, pT] {
+	return InParams[inT, pT]{
+		fn: func(ctx convCtx.Context, p pT, in inT) error {
+			err := x.fn(ctx, p, in)
+			if err != nil {
+				return err
+			}
+			return check(ctx)
+		},
+	}
+}
+
+type InParams[inT, pT any] struct {
+	descriptor descriptor
+	fn         func(ctx convCtx.Context, p pT, in inT) error
+}
+
+func (x *InParams[inT, pT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var p pT
+	if !x.descriptor.bindParams(ctx, w, r, values, &p) {
+		return true
+	}
+
+	var in inT
+	err := json.NewDecoder(r.Body).Decode(&in)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to decode http payload", err)
+		return true
+	}
+
+	err = x.fn(
+		ctx,
+		p,
+		in,
+	)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			serveError(w, apiErr)
+		} else {
+			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+		}
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return true
+}
+
+func (x *InParams[inT, pT]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *InParams[inT, pT]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *InParams[inT, pT]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeOf(new(inT)), nil
+}
+
+func (x *InParams[inT, pT]) setEndpoints(eps endpoints) {}
+
+func (x *InParams[inT, pT]) getParamsType() reflect.Type {
+	return reflect.TypeFor[pT]()
+}
+
+func (x *InParams[inT, pT]) Call(ctx convCtx.Context, p pT, in inT) (err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+
+	req, err := x.descriptor.newBoundRequest(p, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+
+	res, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInOutP1[inT, outT any, p1T ~string](fn func(ctx convCtx.Context, p1 p1T, in inT) (outT, error)) InOutP1[inT, outT, p1T] {
+func NewInOutP1[inT, outT, p1T any](fn func(ctx convCtx.Context, p1 p1T, in inT) (outT, error)) InOutP1[inT, outT, p1T] {
 	return InOutP1[inT, outT, p1T]{
 		fn: fn,
 	}
@@ -44,7 +44,7 @@ func (x InOutP1[inT, outT, p1T]) WithPostCheck(check Check) InOutP1[inT, outT, p
 	}
 }
 
-type InOutP1[inT, outT any, p1T ~string] struct {
+type InOutP1[inT, outT, p1T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, in inT) (outT, error)
 }
@@ -56,6 +56,11 @@ func (x *InOutP1[inT, outT, p1T]) execIfMatch(ctx convCtx.Context, w http.Respon
 		return false
 	}
 
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -65,7 +70,7 @@ func (x *InOutP1[inT, outT, p1T]) execIfMatch(ctx convCtx.Context, w http.Respon
 
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
+		p1,
 		in,
 	)
 	if err != nil {
@@ -96,6 +101,10 @@ func (x *InOutP1[inT, outT, p1T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *InOutP1[inT, outT, p1T]) setEndpoints(eps endpoints) {}
 
+func (x *InOutP1[inT, outT, p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
 func (x *InOutP1[inT, outT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -108,8 +117,9 @@ func (x *InOutP1[inT, outT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (out
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
+	values, err := formatParams(p1)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -125,7 +135,7 @@ func (x *InOutP1[inT, outT, p1T]) Call(ctx convCtx.Context, p1 p1T, in inT) (out
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
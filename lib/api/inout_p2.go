@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInOutP2[inT, outT any, p1T, p2T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (outT, error)) InOutP2[inT, outT, p1T, p2T] {
+func NewInOutP2[inT, outT, p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (outT, error)) InOutP2[inT, outT, p1T, p2T] {
 	return InOutP2[inT, outT, p1T, p2T]{
 		fn: fn,
 	}
@@ -44,7 +44,7 @@ func (x InOutP2[inT, outT, p1T, p2T]) WithPostCheck(check Check) InOutP2[inT, ou
 	}
 }
 
-type InOutP2[inT, outT any, p1T, p2T ~string] struct {
+type InOutP2[inT, outT, p1T, p2T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (outT, error)
 }
@@ -56,6 +56,14 @@ func (x *InOutP2[inT, outT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.R
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -65,8 +73,8 @@ func (x *InOutP2[inT, outT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.R
 
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
+		p1,
+		p2,
 		in,
 	)
 	if err != nil {
@@ -98,6 +106,10 @@ func (x *InOutP2[inT, outT, p1T, p2T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *InOutP2[inT, outT, p1T, p2T]) setEndpoints(eps endpoints) {}
 
+func (x *InOutP2[inT, outT, p1T, p2T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()}
+}
+
 func (x *InOutP2[inT, outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, in inT) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -110,9 +122,9 @@ func (x *InOutP2[inT, outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T,
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
+	values, err := formatParams(p1, p2)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -128,7 +140,7 @@ func (x *InOutP2[inT, outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T,
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
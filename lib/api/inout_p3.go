@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInOutP3[inT, outT any, p1T, p2T, p3T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) (outT, error)) InOutP3[inT, outT, p1T, p2T, p3T] {
+func NewInOutP3[inT, outT, p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) (outT, error)) InOutP3[inT, outT, p1T, p2T, p3T] {
 	return InOutP3[inT, outT, p1T, p2T, p3T]{
 		fn: fn,
 	}
@@ -44,7 +44,7 @@ func (x InOutP3[inT, outT, p1T, p2T, p3T]) WithPostCheck(check Check) InOutP3[in
 	}
 }
 
-type InOutP3[inT, outT any, p1T, p2T, p3T ~string] struct {
+type InOutP3[inT, outT, p1T, p2T, p3T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) (outT, error)
 }
@@ -56,6 +56,15 @@ func (x *InOutP3[inT, outT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w h
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -65,9 +74,9 @@ func (x *InOutP3[inT, outT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w h
 
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
+		p1,
+		p2,
+		p3,
 		in,
 	)
 	if err != nil {
@@ -98,6 +107,10 @@ func (x *InOutP3[inT, outT, p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Typ
 
 func (x *InOutP3[inT, outT, p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
 
+func (x *InOutP3[inT, outT, p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
 func (x *InOutP3[inT, outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in inT) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -110,10 +123,9 @@ func (x *InOutP3[inT, outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -129,7 +141,7 @@ func (x *InOutP3[inT, outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
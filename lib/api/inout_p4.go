@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInOutP4[inT, outT any, p1T, p2T, p3T, p4T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (outT, error)) InOutP4[inT, outT, p1T, p2T, p3T, p4T] {
+func NewInOutP4[inT, outT, p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (outT, error)) InOutP4[inT, outT, p1T, p2T, p3T, p4T] {
 	return InOutP4[inT, outT, p1T, p2T, p3T, p4T]{
 		fn: fn,
 	}
@@ -44,7 +44,7 @@ func (x InOutP4[inT, outT, p1T, p2T, p3T, p4T]) WithPostCheck(check Check) InOut
 	}
 }
 
-type InOutP4[inT, outT any, p1T, p2T, p3T, p4T ~string] struct {
+type InOutP4[inT, outT, p1T, p2T, p3T, p4T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (outT, error)
 }
@@ -56,6 +56,16 @@ func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -65,10 +75,10 @@ func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context
 
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
+		p1,
+		p2,
+		p3,
+		p4,
 		in,
 	)
 	if err != nil {
@@ -99,6 +109,10 @@ func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) getInOutTypes() (in, out reflec
 
 func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) setEndpoints(eps endpoints) {}
 
+func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()}
+}
+
 func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in inT) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -111,11 +125,9 @@ func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
+	values, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -131,7 +143,7 @@ func (x *InOutP4[inT, outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
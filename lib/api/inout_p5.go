@@ -10,7 +10,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewInOutP5[inT, outT any, p1T, p2T, p3T, p4T, p5T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (outT, error)) InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
+func NewInOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (outT, error)) InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
 	return InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]{
 		fn: fn,
 	}
@@ -44,7 +44,7 @@ func (x InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) WithPostCheck(check Check)
 	}
 }
 
-type InOutP5[inT, outT any, p1T, p2T, p3T, p4T, p5T ~string] struct {
+type InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (outT, error)
 }
@@ -56,6 +56,17 @@ func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Co
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
 	var in inT
 	err := json.NewDecoder(r.Body).Decode(&in)
 	if err != nil {
@@ -65,11 +76,11 @@ func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Co
 
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
-		p5T(values.GetByIndex(4)),
+		p1,
+		p2,
+		p3,
+		p4,
+		p5,
 		in,
 	)
 	if err != nil {
@@ -100,6 +111,10 @@ func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out r
 
 func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
 
+func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
 func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in inT) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -112,12 +127,9 @@ func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context,
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
-		{Name: "", Value: string(p5)},
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, bytes.NewReader(body))
@@ -133,7 +145,7 @@ func (x *InOutP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context,
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
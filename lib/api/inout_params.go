@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewInOutParams[inT, outT, pT any](fn func(ctx convCtx.Context, p pT, in inT) (outT, error)) InOutParams[inT, outT, pT] {
+	return InOutParams[inT, outT, pT]{
+		fn: fn,
+	}
+}
+
+func (x InOutParams[inT, outT, pT]) WithPreCheck(check Check) InOutParams[inT, outT, pT] {
+	return InOutParams[inT, outT, pT]{
+		fn: func(ctx convCtx.Context, p pT, in inT) (res outT, err error) {
+			err = check(ctx)
+			if err != nil {
+				return
+			}
+			return x.fn(ctx, p, in)
+		},
+	}
+}
+
+func (x InOutParams[inT, outT, pT]) WithPostCheck(check Check) InOutParams[inT, outT, pT] {
+	return InOutParams[inT, outT, pT]{
+		fn: func(ctx convCtx.Context, p pT, in inT) (res outT, err error) {
+			res, err = x.fn(ctx, p, in)
+			if err != nil {
+				return
+			}
+			err = check(ctx)
+			if err != nil {
+				return
+			}
+			return
+		},
+	}
+}
+
+type InOutParams[inT, outT, pT any] struct {
+	descriptor descriptor
+	fn         func(ctx convCtx.Context, p pT, in inT) (outT, error)
+}
+
+func (x *InOutParams[inT, outT, pT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var p pT
+	if !x.descriptor.bindParams(ctx, w, r, values, &p) {
+		return true
+	}
+
+	var in inT
+	err := json.NewDecoder(r.Body).Decode(&in)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to decode http payload", err)
+		return true
+	}
+
+	out, err := x.fn(
+		ctx,
+		p,
+		in,
+	)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			serveError(w, apiErr)
+		} else {
+			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+		}
+	} else {
+		ServeJSON(w, out)
+	}
+
+	return true
+}
+
+func (x *InOutParams[inT, outT, pT]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *InOutParams[inT, outT, pT]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *InOutParams[inT, outT, pT]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeOf(new(inT)), reflect.TypeOf(new(outT))
+}
+
+func (x *InOutParams[inT, outT, pT]) setEndpoints(eps endpoints) {}
+
+func (x *InOutParams[inT, outT, pT]) getParamsType() reflect.Type {
+	return reflect.TypeFor[pT]()
+}
+
+func (x *InOutParams[inT, outT, pT]) Call(ctx convCtx.Context, p pT, in inT) (out outT, err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+
+	req, err := x.descriptor.newBoundRequest(p, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+
+	res, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(&out)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
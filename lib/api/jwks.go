@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// JWKS serves the public keys verifying the tokens of the agents as a JSON Web
+// Key Set, for other parties to verify them; typically made public at
+// "GET /.well-known/jwks.json".
+type JWKS struct {
+	descriptor descriptor
+}
+
+const jwksCacheControl = "public, max-age=300"
+
+func NewJWKS() JWKS {
+	return JWKS{}
+}
+
+func (x *JWKS) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	_, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	set, err := convAuth.JWKS()
+	if err != nil {
+		ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unable to read the verification keys", err)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", jwksCacheControl)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(set)
+
+	return true
+}
+
+func (x *JWKS) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *JWKS) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *JWKS) getInOutTypes() (in, out reflect.Type) {
+	return nil, reflect.TypeFor[convAuth.JSONWebKeySet]()
+}
+
+func (x *JWKS) setEndpoints(eps endpoints) {}
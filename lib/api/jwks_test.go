@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type jwksAPI struct {
+	JWKS convAPI.JWKS `api:"GET /.well-known/jwks.json"`
+}
+
+func Test_jwks(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /.well-known/jwks.json",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_jwks"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &jwksAPI{
+		JWKS: convAPI.NewJWKS(),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	res, err := http.Get(fmt.Sprintf("https://localhost:%d/.well-known/jwks.json", port))
+	if err != nil {
+		t.Fatalf("http.Get() = %v; want nil", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusOK)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/jwk-set+json" {
+		t.Fatalf("Content-Type = %s; want application/jwk-set+json", ct)
+	}
+
+	var set convAuth.JSONWebKeySet
+	err = json.NewDecoder(res.Body).Decode(&set)
+	if err != nil {
+		t.Fatalf("Decode() = %v; want nil", err)
+	}
+
+	want, _ := convAuth.JWKS()
+	if set.Keys == nil || len(set.Keys) != len(want.Keys) {
+		t.Fatalf("keys = %v; want %v", set.Keys, want.Keys) // the secret of HS256 tokens is never published
+	}
+}
@@ -14,12 +14,21 @@ var (
 )
 
 func portForAPITest(t *testing.T) int {
+	return portForAPITestAgent(t, "")
+}
+
+// portForAPITestAgent returns the port of one of the agents of a test
+func portForAPITestAgent(t *testing.T, agent string) int {
 	mutex.Lock()
 	defer mutex.Unlock()
-	port, ok := apiPorts[t.Name()]
+	key := t.Name()
+	if agent != "" {
+		key += "@" + agent
+	}
+	port, ok := apiPorts[key]
 	if !ok {
 		port = 12345 + len(apiPorts)
-		apiPorts[t.Name()] = port
+		apiPorts[key] = port
 	}
 	return port
 }
@@ -269,12 +269,16 @@ func (x *OpenAPI) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *htt
 		fmt.Println("desc.query", desc.query)
 		if len(urlParams)+len(desc.query) > 0 {
 			sb.WriteString("    parameters:\n")
-			for _, p := range urlParams {
+			for i, p := range urlParams {
+				typ, format := desc.parameterSchema(i)
 				sb.WriteString(fmt.Sprintf("      - name: %s\n", p))
 				sb.WriteString("        required: true\n")
 				sb.WriteString("        in: path\n")
 				sb.WriteString("        schema:\n")
-				sb.WriteString("          type: string\n")
+				sb.WriteString(fmt.Sprintf("          type: %s\n", typ))
+				if format != "" {
+					sb.WriteString(fmt.Sprintf("          format: %s\n", format))
+				}
 			}
 			for _, p := range desc.query {
 				sb.WriteString(fmt.Sprintf("      - name: %s\n", p.Name))
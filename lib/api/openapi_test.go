@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
 	convAPI "github.com/sofmon/convention/lib/api"
 	convAuth "github.com/sofmon/convention/lib/auth"
 	convCtx "github.com/sofmon/convention/lib/ctx"
@@ -162,6 +164,155 @@ paths:
 	)
 }
 
+func Test_openapi_typed_path_params(t *testing.T) {
+
+	checkOpenAPI(
+		t,
+		&struct {
+			GetOpenAPI convAPI.OpenAPI                                 `api:"GET /test/v1/openapi.yaml"`
+			GetItem    convAPI.OutP3[string, int64, uuid.UUID, string] `api:"GET /test/v1/orders/{number}/items/{item}/{name}"`
+		}{},
+		`openapi: 3.0.0
+info:
+	title: API
+	version: 1.0.0
+paths:
+	/test/v1/openapi.yaml:
+		get:
+			responses:
+				'200':
+					description: OK
+	/test/v1/orders/{number}/items/{item}/{name}:
+		parameters:
+			- name: number
+				required: true
+				in: path
+				schema:
+					type: integer
+					format: int64
+			- name: item
+				required: true
+				in: path
+				schema:
+					type: string
+					format: uuid
+			- name: name
+				required: true
+				in: path
+				schema:
+					type: string
+		get:
+			responses:
+				'200':
+					description: OK
+					content:
+						application/json:
+							schema:
+								type: string`,
+	)
+}
+
+type openapiParams struct {
+	Item   uuid.UUID `path:"item"`
+	Number int64     `path:"number"`
+	Name   string    `path:"name"`
+	Limit  int       `query:"limit"`
+	Trace  string    `header:"X-Trace"`
+}
+
+func Test_openapi_params_struct(t *testing.T) {
+
+	// same document as the positional OutP3 of Test_openapi_typed_path_params
+	// with its query parameter
+	checkOpenAPI(
+		t,
+		&struct {
+			GetOpenAPI convAPI.OpenAPI                          `api:"GET /test/v1/openapi.yaml"`
+			GetItem    convAPI.OutParams[string, openapiParams] `api:"GET /test/v1/orders/{number}/items/{item}/{name}?limit=integer|Max results"`
+		}{},
+		`openapi: 3.0.0
+info:
+	title: API
+	version: 1.0.0
+paths:
+	/test/v1/openapi.yaml:
+		get:
+			responses:
+				'200':
+					description: OK
+	/test/v1/orders/{number}/items/{item}/{name}:
+		parameters:
+			- name: number
+				required: true
+				in: path
+				schema:
+					type: integer
+					format: int64
+			- name: item
+				required: true
+				in: path
+				schema:
+					type: string
+					format: uuid
+			- name: name
+				required: true
+				in: path
+				schema:
+					type: string
+			- name: limit
+				required: false
+				in: query
+				schema:
+					type: integer
+				description: Max results
+		get:
+			responses:
+				'200':
+					description: OK
+					content:
+						application/json:
+							schema:
+								type: string`,
+	)
+}
+
+func Test_openapi_fields_query(t *testing.T) {
+
+	checkOpenAPI(
+		t,
+		&struct {
+			GetOpenAPI convAPI.OpenAPI     `api:"GET /test/v1/openapi.yaml"`
+			GetNames   convAPI.Out[string] `api:"GET /test/v1/names" fields:"true"`
+		}{},
+		`openapi: 3.0.0
+info:
+	title: API
+	version: 1.0.0
+paths:
+	/test/v1/names:
+		parameters:
+			- name: fields
+				required: false
+				in: query
+				schema:
+					type: string
+				description: Comma separated JSON paths of the fields to return (e.g. name,address.city); prefix each with - to return all but them
+		get:
+			responses:
+				'200':
+					description: OK
+					content:
+						application/json:
+							schema:
+								type: string
+	/test/v1/openapi.yaml:
+		get:
+			responses:
+				'200':
+					description: OK`,
+	)
+}
+
 func Test_openapi_enums_in_object(t *testing.T) {
 
 	type Enum string
@@ -55,6 +55,11 @@ func (x *Out[outT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *h
 		return false
 	}
 
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(ctx)
 	if err != nil {
 		var apiErr *Error
@@ -64,7 +69,7 @@ func (x *Out[outT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *h
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -103,7 +108,7 @@ func (x *Out[outT]) Call(ctx convCtx.Context) (out outT, err error) {
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
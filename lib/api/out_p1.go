@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewOutP1[outT any, p1T ~string](fn func(ctx convCtx.Context, p1 p1T) (outT, error)) OutP1[outT, p1T] {
+func NewOutP1[outT, p1T any](fn func(ctx convCtx.Context, p1 p1T) (outT, error)) OutP1[outT, p1T] {
 	return OutP1[outT, p1T]{
 		fn: fn,
 	}
@@ -43,7 +43,7 @@ func (x OutP1[outT, p1T]) WithPostCheck(check Check) OutP1[outT, p1T] {
 	}
 }
 
-type OutP1[outT any, p1T ~string] struct {
+type OutP1[outT, p1T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T) (outT, error)
 }
@@ -55,9 +55,19 @@ func (x *OutP1[outT, p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWrite
 		return false
 	}
 
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
+		p1,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -67,7 +77,7 @@ func (x *OutP1[outT, p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWrite
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -87,6 +97,10 @@ func (x *OutP1[outT, p1T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *OutP1[outT, p1T]) setEndpoints(eps endpoints) {}
 
+func (x *OutP1[outT, p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
 func (x *OutP1[outT, p1T]) Call(ctx convCtx.Context, p1 p1T) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -94,8 +108,9 @@ func (x *OutP1[outT, p1T]) Call(ctx convCtx.Context, p1 p1T) (out outT, err erro
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
+	values, err := formatParams(p1)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -110,7 +125,7 @@ func (x *OutP1[outT, p1T]) Call(ctx convCtx.Context, p1 p1T) (out outT, err erro
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewOutP2[outT any, p1T, p2T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T) (outT, error)) OutP2[outT, p1T, p2T] {
+func NewOutP2[outT, p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T) (outT, error)) OutP2[outT, p1T, p2T] {
 	return OutP2[outT, p1T, p2T]{
 		fn: fn,
 	}
@@ -43,7 +43,7 @@ func (x OutP2[outT, p1T, p2T]) WithPostCheck(check Check) OutP2[outT, p1T, p2T]
 	}
 }
 
-type OutP2[outT any, p1T, p2T ~string] struct {
+type OutP2[outT, p1T, p2T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T) (outT, error)
 }
@@ -55,10 +55,23 @@ func (x *OutP2[outT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.Response
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
+		p1,
+		p2,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -68,7 +81,7 @@ func (x *OutP2[outT, p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.Response
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -88,6 +101,10 @@ func (x *OutP2[outT, p1T, p2T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *OutP2[outT, p1T, p2T]) setEndpoints(eps endpoints) {}
 
+func (x *OutP2[outT, p1T, p2T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()}
+}
+
 func (x *OutP2[outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -95,9 +112,9 @@ func (x *OutP2[outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (out o
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
+	values, err := formatParams(p1, p2)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -112,7 +129,7 @@ func (x *OutP2[outT, p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (out o
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
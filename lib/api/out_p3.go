@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewOutP3[outT any, p1T, p2T, p3T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (outT, error)) OutP3[outT, p1T, p2T, p3T] {
+func NewOutP3[outT, p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (outT, error)) OutP3[outT, p1T, p2T, p3T] {
 	return OutP3[outT, p1T, p2T, p3T]{
 		fn: fn,
 	}
@@ -43,7 +43,7 @@ func (x OutP3[outT, p1T, p2T, p3T]) WithPostCheck(check Check) OutP3[outT, p1T,
 	}
 }
 
-type OutP3[outT any, p1T, p2T, p3T ~string] struct {
+type OutP3[outT, p1T, p2T, p3T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (outT, error)
 }
@@ -55,11 +55,25 @@ func (x *OutP3[outT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.Res
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
+		p1,
+		p2,
+		p3,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -69,7 +83,7 @@ func (x *OutP3[outT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.Res
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -89,6 +103,10 @@ func (x *OutP3[outT, p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *OutP3[outT, p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
 
+func (x *OutP3[outT, p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
 func (x *OutP3[outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -96,10 +114,9 @@ func (x *OutP3[outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -114,7 +131,7 @@ func (x *OutP3[outT, p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
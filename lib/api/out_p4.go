@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewOutP4[outT any, p1T, p2T, p3T, p4T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (outT, error)) OutP4[outT, p1T, p2T, p3T, p4T] {
+func NewOutP4[outT, p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (outT, error)) OutP4[outT, p1T, p2T, p3T, p4T] {
 	return OutP4[outT, p1T, p2T, p3T, p4T]{
 		fn: fn,
 	}
@@ -43,7 +43,7 @@ func (x OutP4[outT, p1T, p2T, p3T, p4T]) WithPostCheck(check Check) OutP4[outT,
 	}
 }
 
-type OutP4[outT any, p1T, p2T, p3T, p4T ~string] struct {
+type OutP4[outT, p1T, p2T, p3T, p4T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (outT, error)
 }
@@ -55,12 +55,27 @@ func (x *OutP4[outT, p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context, w htt
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
+		p1,
+		p2,
+		p3,
+		p4,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -70,7 +85,7 @@ func (x *OutP4[outT, p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context, w htt
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -90,6 +105,10 @@ func (x *OutP4[outT, p1T, p2T, p3T, p4T]) getInOutTypes() (in, out reflect.Type)
 
 func (x *OutP4[outT, p1T, p2T, p3T, p4T]) setEndpoints(eps endpoints) {}
 
+func (x *OutP4[outT, p1T, p2T, p3T, p4T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()}
+}
+
 func (x *OutP4[outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -97,11 +116,9 @@ func (x *OutP4[outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
+	values, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -116,7 +133,7 @@ func (x *OutP4[outT, p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
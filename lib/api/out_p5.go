@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewOutP5[outT any, p1T, p2T, p3T, p4T, p5T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (outT, error)) OutP5[outT, p1T, p2T, p3T, p4T, p5T] {
+func NewOutP5[outT, p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (outT, error)) OutP5[outT, p1T, p2T, p3T, p4T, p5T] {
 	return OutP5[outT, p1T, p2T, p3T, p4T, p5T]{
 		fn: fn,
 	}
@@ -43,7 +43,7 @@ func (x OutP5[outT, p1T, p2T, p3T, p4T, p5T]) WithPostCheck(check Check) OutP5[o
 	}
 }
 
-type OutP5[outT any, p1T, p2T, p3T, p4T, p5T ~string] struct {
+type OutP5[outT, p1T, p2T, p3T, p4T, p5T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (outT, error)
 }
@@ -55,13 +55,29 @@ func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context,
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
 	out, err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
-		p5T(values.GetByIndex(4)),
+		p1,
+		p2,
+		p3,
+		p4,
+		p5,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -71,7 +87,7 @@ func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context,
 			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
 		}
 	} else {
-		ServeJSON(w, out)
+		serveShaped(w, out, sh)
 	}
 
 	return true
@@ -91,6 +107,10 @@ func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out reflect.
 
 func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
 
+func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
 func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (out outT, err error) {
 
 	if !x.descriptor.isSet() {
@@ -98,12 +118,9 @@ func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T,
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
-		{Name: "", Value: string(p5)},
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -118,7 +135,7 @@ func (x *OutP5[outT, p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T,
 
 	req.Header.Add("Accept", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
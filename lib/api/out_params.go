@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewOutParams[outT, pT any](fn func(ctx convCtx.Context, p pT) (outT, error)) OutParams[outT, pT] {
+	return OutParams[outT, pT]{
+		fn: fn,
+	}
+}
+
+func (x OutParams[outT, pT]) WithPreCheck(check Check) OutParams[outT, pT] {
+	return OutParams[outT, pT]{
+		fn: func(ctx convCtx.Context, p pT) (res outT, err error) {
+			err = check(ctx)
+			if err != nil {
+				return
+			}
+			return x.fn(ctx, p)
+		},
+	}
+}
+
+func (x OutParams[outT, pT]) WithPostCheck(check Check) OutParams[outT, pT] {
+	return OutParams[outT, pT]{
+		fn: func(ctx convCtx.Context, p pT) (res outT, err error) {
+			res, err = x.fn(ctx, p)
+			if err != nil {
+				return
+			}
+			err = check(ctx)
+			if err != nil {
+				return
+			}
+			return
+		},
+	}
+}
+
+type OutParams[outT, pT any] struct {
+	descriptor descriptor
+	fn         func(ctx convCtx.Context, p pT) (outT, error)
+}
+
+func (x *OutParams[outT, pT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var p pT
+	if !x.descriptor.bindParams(ctx, w, r, values, &p) {
+		return true
+	}
+
+	sh, ok := x.descriptor.parseShape(ctx, w, r, reflect.TypeFor[outT]())
+	if !ok {
+		return true
+	}
+
+	out, err := x.fn(
+		ctx,
+		p,
+	)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			serveError(w, apiErr)
+		} else {
+			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+		}
+	} else {
+		serveShaped(w, out, sh)
+	}
+
+	return true
+}
+
+func (x *OutParams[outT, pT]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *OutParams[outT, pT]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *OutParams[outT, pT]) getInOutTypes() (in, out reflect.Type) {
+	return nil, reflect.TypeOf(new(outT))
+}
+
+func (x *OutParams[outT, pT]) setEndpoints(eps endpoints) {}
+
+func (x *OutParams[outT, pT]) getParamsType() reflect.Type {
+	return reflect.TypeFor[pT]()
+}
+
+func (x *OutParams[outT, pT]) Call(ctx convCtx.Context, p pT) (out outT, err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	req, err := x.descriptor.newBoundRequest(p, nil)
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("Accept", "application/json")
+
+	res, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(&out)
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
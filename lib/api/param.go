@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+
+	"github.com/google/uuid"
+)
+
+// Path parameters can be of any type implementing encoding.TextUnmarshaler
+// (parsed) and encoding.TextMarshaler (formatted by clients), or of a string
+// or integer kind.
+
+var errUnsupportedParam = errors.New("unsupported path parameter type; implement encoding.TextUnmarshaler and encoding.TextMarshaler")
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// paramSupported reports whether a parameter of type t can be both parsed
+// and formatted
+func paramSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType) &&
+		reflect.PointerTo(t).Implements(textMarshalerType) // also true when t implements it
+}
+
+// checkEndpointParams fails on the first endpoint with a path parameter of an
+// unsupported type, or with a params struct not fitting its path
+func checkEndpointParams(eps endpoints) error {
+	for _, ep := range eps {
+		desc := ep.getDescriptor()
+		if desc.binding != nil {
+			err := desc.binding.check(&desc)
+			if err != nil {
+				return fmt.Errorf("endpoint '%s %s': %w", desc.method, desc.path(), err)
+			}
+		}
+		for _, t := range desc.params {
+			if !paramSupported(t) {
+				return fmt.Errorf("endpoint '%s %s' path parameter of type %s: %w", desc.method, desc.path(), t, errUnsupportedParam)
+			}
+		}
+	}
+	return nil
+}
+
+// parseParam parses the path value into ptr, a pointer to a parameter
+func parseParam(s string, ptr any) error {
+
+	if u, ok := ptr.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	v := reflect.ValueOf(ptr).Elem()
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	default:
+		return errUnsupportedParam
+	}
+
+	return nil
+}
+
+// formatParam formats the parameter as a path value
+func formatParam(p any) (string, error) {
+
+	if m, ok := p.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+
+	v := reflect.ValueOf(p)
+
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		text, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	default:
+		return "", errUnsupportedParam
+	}
+}
+
+// formatParams formats the parameters as the path values of a client call
+func formatParams(ps ...any) (vals values, err error) {
+	for _, p := range ps {
+		var s string
+		s, err = formatParam(p)
+		if err != nil {
+			return
+		}
+		vals.Add("", s)
+	}
+	return
+}
+
+// parseParams parses the path values into the parameter pointers, serving
+// bad request when a value does not parse
+func (desc *descriptor) parseParams(ctx convCtx.Context, w http.ResponseWriter, vals values, ptrs ...any) bool {
+	for i, ptr := range ptrs {
+		err := parseParam(vals.GetByIndex(i), ptr)
+		if err != nil {
+			ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("invalid path parameter '%s'", paramName(vals, i)), err)
+			return false
+		}
+	}
+	return true
+}
+
+// parseStoredParams parses the path values stored with an async operation
+func parseStoredParams(stored []string, ptrs ...any) error {
+	if len(stored) != len(ptrs) {
+		return errors.New("unexpected number of stored path values")
+	}
+	for i, ptr := range ptrs {
+		err := parseParam(stored[i], ptr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParams tells whether the path values parse as the parameter types
+func checkParams(vals values, types []reflect.Type) (name string, err error) {
+	for i, t := range types {
+		err = parseParam(vals.GetByIndex(i), reflect.New(t).Interface())
+		if err != nil {
+			return paramName(vals, i), err
+		}
+	}
+	return
+}
+
+func paramName(vals values, i int) string {
+	if i < len(vals) {
+		return vals[i].Name
+	}
+	return strconv.Itoa(i)
+}
+
+// parameterSchema returns the OpenAPI type and format of the i-th path
+// parameter; string for endpoints without typed parameters
+func (desc *descriptor) parameterSchema(i int) (typ objectType, format string) {
+	if i < len(desc.params) {
+		return paramSchema(desc.params[i])
+	}
+	return objectTypeString, ""
+}
+
+// paramSchema returns the OpenAPI type and format of a path parameter type
+func paramSchema(t reflect.Type) (typ objectType, format string) {
+
+	switch t {
+	case reflect.TypeFor[uuid.UUID]():
+		return objectTypeString, "uuid"
+	case reflect.TypeFor[time.Time]():
+		return objectTypeString, "date-time"
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return objectTypeString, ""
+	}
+
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return objectTypeInteger, "int32"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return objectTypeInteger, "int64"
+	default:
+		return objectTypeString, ""
+	}
+}
@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// day is a path parameter formatted as a calendar date
+type day time.Time
+
+func (d day) MarshalText() ([]byte, error) {
+	return []byte(time.Time(d).Format(time.DateOnly)), nil
+}
+
+func (d *day) UnmarshalText(text []byte) error {
+	t, err := time.Parse(time.DateOnly, string(text))
+	if err != nil {
+		return err
+	}
+	*d = day(t)
+	return nil
+}
+
+// parseOnlyDay can be parsed but not formatted as a path parameter
+type parseOnlyDay struct{ t time.Time }
+
+func (d *parseOnlyDay) UnmarshalText(text []byte) (err error) {
+	d.t, err = time.Parse(time.DateOnly, string(text))
+	return
+}
+
+type paramAPI struct {
+	Get convAPI.OutP3[string, int64, uuid.UUID, day] `api:"GET /test/v1/orders/{number}/items/{item}/days/{day}"`
+}
+
+// paramLooseAPI calls the endpoints of paramAPI with any path values
+type paramLooseAPI struct {
+	Get convAPI.OutP3[string, string, string, string] `api:"GET /test/v1/orders/{number}/items/{item}/days/{day}"`
+}
+
+func Test_param(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/orders/{any}/items/{any}/days/{any}",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_param"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &paramAPI{
+		Get: convAPI.NewOutP3(func(ctx convCtx.Context, number int64, item uuid.UUID, d day) (string, error) {
+			return fmt.Sprintf("%d/%s/%s", number, item, time.Time(d).Format(time.DateOnly)), nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1"})
+
+	item := uuid.MustParse("6f1c2c5e-8d0b-4e4f-9a43-2b6c1f0a9d11")
+
+	t.Run("typed", func(t *testing.T) {
+		client := convAPI.NewClient[paramAPI]("localhost", port)
+
+		res, err := client.Get.Call(callerCtx, 42, item, day(time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)))
+		if err != nil || res != "42/"+item.String()+"/2026-03-14" {
+			t.Fatalf("Get() = %q, %v; want 42/%s/2026-03-14", res, err, item)
+		}
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		client := convAPI.NewClient[paramLooseAPI]("localhost", port)
+
+		for _, c := range [][3]string{
+			{"forty-two", item.String(), "2026-03-14"},
+			{"42", "not-a-uuid", "2026-03-14"},
+			{"42", item.String(), "14.03.2026"},
+			{"9223372036854775808", item.String(), "2026-03-14"}, // overflows int64
+		} {
+			_, err := client.Get.Call(callerCtx, c[0], c[1], c[2])
+			if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeBadRequest) {
+				t.Fatalf("Get(%v) = %v; want bad request", c, err)
+			}
+		}
+	})
+
+	t.Run("unsupported_type", func(t *testing.T) {
+		_, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &struct {
+			Get convAPI.OutP1[string, float64] `api:"GET /test/v1/prices/{price}"`
+		}{})
+		if err == nil {
+			t.Fatal("NewServer() with a float64 path parameter = nil; want error")
+		}
+	})
+	t.Run("parse_only_type", func(t *testing.T) {
+		_, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &struct {
+			Get convAPI.OutP1[string, parseOnlyDay] `api:"GET /test/v1/days/{day}"`
+		}{})
+		if err == nil {
+			t.Fatal("NewServer() with a path parameter without MarshalText = nil; want error")
+		}
+	})
+
+	t.Run("unsupported_type_handler", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("NewHandler() with a float64 path parameter does not panic")
+			}
+		}()
+		convAPI.NewHandler(agentCtx, "localhost", port, nil, &struct {
+			Get convAPI.OutP1[string, float64] `api:"GET /test/v1/prices/{price}"`
+		}{})
+	})
+
+	t.Run("unsupported_type_client", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("NewClient() with a float64 path parameter does not panic")
+			}
+		}()
+		convAPI.NewClient[struct {
+			Get convAPI.OutP1[string, float64] `api:"GET /test/v1/prices/{price}"`
+		}]("localhost", port)
+	})
+}
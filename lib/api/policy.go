@@ -0,0 +1,243 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+// policy tags of an endpoint:
+//
+//	perm:"read_messages,write_messages"  the permissions granting the endpoint
+//	public:"true"                        the endpoint needs no authorization
+func parsePermTag(tag string) (perms []convAuth.Permission) {
+	for _, p := range strings.Split(tag, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			perms = append(perms, convAuth.Permission(p))
+		}
+	}
+	return
+}
+
+func parsePublicTag(tag string) bool {
+	public, _ := strconv.ParseBool(tag)
+	return public
+}
+
+// policyMethods are the actions derived for endpoints of any method
+var policyMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// endpointsOf computes the endpoints of an API definition without touching svc
+func endpointsOf(svc any) endpoints {
+	return computeEndpoints("", 0, reflect.New(reflect.TypeOf(svc).Elem()).Interface())
+}
+
+// NewPolicy derives the permission actions and public actions of the policy
+// from the `perm` and `public` tags of the endpoints of svc (a pointer to an
+// API struct); the roles are taken as given. Path parameters named tenant,
+// user and entity keep their meaning in the actions; others match any value.
+func NewPolicy(svc any, roles convAuth.RolePermissions) (policy convAuth.Policy) {
+
+	policy.Roles = roles
+	policy.Permissions = convAuth.PermissionActions{}
+
+	for _, ep := range endpointsOf(svc) {
+		desc := ep.getDescriptor()
+
+		actions := desc.policyActions()
+
+		if desc.public {
+			policy.Public = append(policy.Public, actions...)
+			continue
+		}
+
+		for _, perm := range desc.perms {
+			for _, a := range actions {
+				if !slices.Contains(policy.Permissions[perm], a) {
+					policy.Permissions[perm] = append(policy.Permissions[perm], a)
+				}
+			}
+		}
+	}
+
+	for perm := range policy.Permissions {
+		slices.Sort(policy.Permissions[perm])
+	}
+	slices.Sort(policy.Public)
+	policy.Public = slices.Compact(policy.Public)
+
+	return
+}
+
+func (desc *descriptor) policyActions() (actions convAuth.Actions) {
+
+	sb := strings.Builder{}
+	for _, segment := range desc.segments {
+		sb.WriteRune('/')
+		switch {
+		case !segment.Param:
+			sb.WriteString(segment.Value)
+		case segment.Value == "tenant", segment.Value == "user", segment.Value == "entity":
+			sb.WriteString("{" + segment.Value + "}")
+		default:
+			sb.WriteString("{any}")
+		}
+	}
+	if desc.open {
+		sb.WriteString("/{any...}")
+	}
+	path := sb.String()
+
+	if desc.method != "{any}" {
+		return convAuth.Actions{convAuth.Action(desc.method + " " + path)}
+	}
+
+	for _, m := range policyMethods {
+		actions = append(actions, convAuth.Action(m+" "+path))
+	}
+	return
+}
+
+type PolicyIssueKind string
+
+const (
+	PolicyIssueUnreachableEndpoint PolicyIssueKind = "unreachable_endpoint" // no public action and no action of a granted permission matches the endpoint
+	PolicyIssueUnmatchedAction     PolicyIssueKind = "unmatched_action"     // the action of the policy matches no endpoint
+)
+
+// PolicyIssue is a mismatch between a policy and the endpoints of an API.
+type PolicyIssue struct {
+	Kind       PolicyIssueKind
+	Action     convAuth.Action     // the endpoint or the action of the policy
+	Permission convAuth.Permission // of an unmatched action; empty for public actions
+}
+
+func (i PolicyIssue) String() string {
+	switch i.Kind {
+	case PolicyIssueUnreachableEndpoint:
+		return fmt.Sprintf("endpoint '%s' is public in no action and granted to no role", i.Action)
+	case PolicyIssueUnmatchedAction:
+		if i.Permission == "" {
+			return fmt.Sprintf("public action '%s' matches no endpoint", i.Action)
+		}
+		return fmt.Sprintf("action '%s' of permission '%s' matches no endpoint", i.Action, i.Permission)
+	default:
+		return string(i.Kind) + " " + string(i.Action)
+	}
+}
+
+// ValidatePolicy cross-checks the policy with the endpoints of svc (a pointer
+// to an API struct), reporting the endpoints no role can reach and the
+// actions matching no endpoint.
+func ValidatePolicy(policy convAuth.Policy, svc any) []PolicyIssue {
+	return validatePolicy(policy, endpointsOf(svc))
+}
+
+func validatePolicy(policy convAuth.Policy, eps endpoints) (issues []PolicyIssue) {
+
+	granted := map[convAuth.Permission]bool{}
+	for _, perms := range policy.Roles {
+		for _, p := range perms {
+			granted[p] = true
+		}
+	}
+
+	reached := make([]bool, len(eps))
+
+	check := func(perm convAuth.Permission, a convAuth.Action, reaches bool) {
+		matched := false
+		for i, ep := range eps {
+			desc := ep.getDescriptor()
+			if !desc.matchAction(a) {
+				continue
+			}
+			matched = true
+			if reaches {
+				reached[i] = true
+			}
+		}
+		if !matched {
+			issues = append(issues, PolicyIssue{Kind: PolicyIssueUnmatchedAction, Action: a, Permission: perm})
+		}
+	}
+
+	for _, a := range policy.Public {
+		check("", a, true)
+	}
+
+	perms := make([]convAuth.Permission, 0, len(policy.Permissions))
+	for p := range policy.Permissions {
+		perms = append(perms, p)
+	}
+	slices.Sort(perms)
+
+	for _, p := range perms {
+		for _, a := range policy.Permissions[p] {
+			check(p, a, granted[p])
+		}
+	}
+
+	for i, ep := range eps {
+		if reached[i] {
+			continue
+		}
+		desc := ep.getDescriptor()
+		issues = append(issues, PolicyIssue{Kind: PolicyIssueUnreachableEndpoint, Action: convAuth.Action(desc.method + " " + desc.path())})
+	}
+
+	return
+}
+
+// matchAction tells whether the action can match requests to the endpoint
+func (desc *descriptor) matchAction(a convAuth.Action) bool {
+
+	method, resource, err := a.MethodPath()
+	if err != nil {
+		return false
+	}
+
+	if desc.method != "{any}" && desc.method != method {
+		return false
+	}
+
+	segments := strings.Split(resource, "/")
+
+	openEnd := len(segments) > 0 && segments[len(segments)-1] == "{any...}"
+	if openEnd {
+		segments = segments[:len(segments)-1]
+	}
+
+	switch {
+	case openEnd && desc.open:
+	case openEnd:
+		if len(segments) > len(desc.segments) {
+			return false
+		}
+	case desc.open:
+		if len(segments) < len(desc.segments) {
+			return false
+		}
+	default:
+		if len(segments) != len(desc.segments) {
+			return false
+		}
+	}
+
+	for i := 0; i < min(len(segments), len(desc.segments)); i++ {
+		s := segments[i]
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			continue // placeholders match any value
+		}
+		if !desc.segments[i].Param && desc.segments[i].Value != s {
+			return false
+		}
+	}
+
+	return true
+}
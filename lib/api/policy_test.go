@@ -0,0 +1,259 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type policyAPI struct {
+	ListMessages convAPI.OutP1[[]string, convAuth.Tenant]       `api:"GET /test/v1/tenants/{tenant}/messages" perm:"read_tenant_messages"`
+	GetMessage   convAPI.OutP2[string, convAuth.Tenant, string] `api:"GET /test/v1/tenants/{tenant}/messages/{message}" perm:"read_tenant_messages,admin"`
+	PutMessage   convAPI.InP2[string, convAuth.Tenant, string]  `api:"PUT /test/v1/tenants/{tenant}/messages/{message}" perm:"write_tenant_messages"`
+	Health       convAPI.Out[string]                            `api:"GET /test/v1/health" public:"true"`
+	Untagged     convAPI.OutP1[string, convAuth.User]           `api:"GET /test/v1/users/{user}"`
+}
+
+func Test_policy(t *testing.T) {
+
+	roles := convAuth.RolePermissions{
+		"reader": convAuth.Permissions{"read_tenant_messages"},
+		"writer": convAuth.Permissions{"read_tenant_messages", "write_tenant_messages"},
+	}
+
+	policy := convAPI.NewPolicy(&policyAPI{}, roles)
+
+	t.Run("derived", func(t *testing.T) {
+		want := convAuth.Policy{
+			Roles: roles,
+			Permissions: convAuth.PermissionActions{
+				"read_tenant_messages": convAuth.Actions{
+					"GET /test/v1/tenants/{tenant}/messages",
+					"GET /test/v1/tenants/{tenant}/messages/{any}",
+				},
+				"admin": convAuth.Actions{
+					"GET /test/v1/tenants/{tenant}/messages/{any}",
+				},
+				"write_tenant_messages": convAuth.Actions{
+					"PUT /test/v1/tenants/{tenant}/messages/{any}",
+				},
+			},
+			Public: convAuth.Actions{"GET /test/v1/health"},
+		}
+		if !reflect.DeepEqual(policy, want) {
+			t.Fatalf("NewPolicy() = %+v; want %+v", policy, want)
+		}
+	})
+
+	t.Run("authorizes", func(t *testing.T) {
+		check, err := convAuth.NewCheck(policy)
+		if err != nil {
+			t.Fatalf("NewCheck() = %v; want nil", err)
+		}
+
+		r := httptest.NewRequest("GET", "/test/v1/tenants/a/messages/m1", nil)
+		convAuth.EncodeHTTPRequestClaims(r, convAuth.Claims{User: "u", Tenants: convAuth.Tenants{"a"}, Roles: convAuth.Roles{"reader"}})
+		if _, err := check(r); err != nil {
+			t.Fatalf("check() of reader = %v; want nil", err)
+		}
+
+		r = httptest.NewRequest("PUT", "/test/v1/tenants/a/messages/m1", nil)
+		convAuth.EncodeHTTPRequestClaims(r, convAuth.Claims{User: "u", Tenants: convAuth.Tenants{"a"}, Roles: convAuth.Roles{"reader"}})
+		if _, err := check(r); err == nil {
+			t.Fatal("check() of reader writing = nil; want error")
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		policy := convAuth.Policy{
+			Roles: convAuth.RolePermissions{
+				"reader": convAuth.Permissions{"read_tenant_messages"},
+			},
+			Permissions: convAuth.PermissionActions{
+				"read_tenant_messages": convAuth.Actions{
+					"GET /test/v1/tenants/{tenant}/messages",
+					"GET /test/v1/tenants/{tenant}/messages/{any}",
+					"GET /test/v1/tenants/{tenant}/massages", // typo
+				},
+				"write_tenant_messages": convAuth.Actions{ // granted to no role
+					"PUT /test/v1/tenants/{tenant}/messages/{any}",
+				},
+			},
+			Public: convAuth.Actions{
+				"GET /test/v1/health",
+				"GET /test/v1/users/me",
+			},
+		}
+
+		issues := convAPI.ValidatePolicy(policy, &policyAPI{})
+
+		want := []convAPI.PolicyIssue{
+			{Kind: convAPI.PolicyIssueUnmatchedAction, Action: "GET /test/v1/tenants/{tenant}/massages", Permission: "read_tenant_messages"},
+			{Kind: convAPI.PolicyIssueUnreachableEndpoint, Action: "PUT /test/v1/tenants/{tenant}/messages/{message}"},
+		}
+		if !reflect.DeepEqual(issues, want) {
+			t.Fatalf("ValidatePolicy() = %v; want %v", issues, want)
+		}
+
+		if issues := convAPI.ValidatePolicy(convAPI.NewPolicy(&policyAPI{}, roles), &policyAPI{}); len(issues) != 1 || issues[0].Action != "GET /test/v1/users/{user}" {
+			t.Fatalf("ValidatePolicy() of the derived policy = %v; want only the untagged endpoint", issues)
+		}
+	})
+}
+
+type reloadablePolicyAPI struct {
+	Orders   convAPI.Out[string] `api:"GET /test/v1/orders"`
+	Invoices convAPI.Out[string] `api:"GET /test/v1/invoices"`
+}
+
+func Test_reloadable_policy(t *testing.T) {
+
+	// the test config with the policy to reload
+	dir := t.TempDir()
+	files, _ := os.ReadDir("../../.secret")
+	for _, f := range files {
+		data, _ := os.ReadFile(filepath.Join("../../.secret", f.Name()))
+		os.WriteFile(filepath.Join(dir, f.Name()), data, 0600)
+	}
+	writePolicy := func(public convAuth.Action) {
+		data, _ := json.Marshal(convAuth.Policy{Public: convAuth.Actions{public}})
+		os.WriteFile(filepath.Join(dir, "policy"), data, 0600)
+	}
+	writePolicy("GET /test/v1/orders")
+
+	convCfg.SetConfigLocation(dir)
+	defer convCfg.SetConfigLocation("../../.secret")
+
+	policy, err := convAuth.LoadPolicy("policy")
+	if err != nil {
+		t.Fatalf("LoadPolicy() = %v; want nil", err)
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_reloadable_policy"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, convAuth.Policy{}, &reloadablePolicyAPI{
+		Orders: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "orders", nil
+		}),
+		Invoices: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "invoices", nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+	srv.UseReloadablePolicy(agentCtx, policy)
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	status := func(path string) int {
+		res, err := http.Get(fmt.Sprintf("https://localhost:%d%s", port, path))
+		if err != nil {
+			t.Fatalf("http.Get() = %v; want nil", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if status("/test/v1/orders") != http.StatusOK || status("/test/v1/invoices") == http.StatusOK {
+		t.Fatal("calls are not checked against the loaded policy")
+	}
+
+	writePolicy("GET /test/v1/invoices")
+
+	changed, err := policy.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v; want true, nil", changed, err)
+	}
+
+	if status("/test/v1/orders") == http.StatusOK || status("/test/v1/invoices") != http.StatusOK {
+		t.Fatal("calls are not checked against the reloaded policy")
+	}
+}
+
+func Test_forbidden_explanation(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			"reader": convAuth.Permissions{"read_orders"},
+		},
+		Permissions: convAuth.PermissionActions{
+			"read_orders": convAuth.Actions{"GET /test/v1/orders"},
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_forbidden_explanation"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &reloadablePolicyAPI{
+		Orders: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "orders", nil
+		}),
+		Invoices: convAPI.NewOut(func(ctx convCtx.Context) (string, error) {
+			return "invoices", nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	forbidden := func(t *testing.T) (apiErr convAPI.Error) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/test/v1/invoices", port), nil)
+		convAuth.EncodeHTTPRequestServiceClaims(req, convAuth.Claims{User: "jane", Roles: convAuth.Roles{"reader"}}, "localhost")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Do() = %v; want nil", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d; want %d", res.StatusCode, http.StatusForbidden)
+		}
+		json.NewDecoder(res.Body).Decode(&apiErr)
+		return
+	}
+
+	t.Run("explained", func(t *testing.T) {
+		apiErr := forbidden(t)
+		want := &convAuth.Explanation{
+			Action:   "GET /test/v1/invoices",
+			Decision: convAuth.DecisionForbidden,
+			Candidates: []convAuth.Candidate{
+				{Template: "GET /test/v1/orders", Role: "reader", Permission: "read_orders", Reason: "'orders' does not match 'invoices'"},
+			},
+		}
+		if !reflect.DeepEqual(apiErr.Explanation, want) {
+			t.Fatalf("Explanation = %+v; want %+v", apiErr.Explanation, want)
+		}
+	})
+
+	t.Run("prod", func(t *testing.T) {
+		defer convAPI.SetProdEnvForTest(true)()
+
+		if apiErr := forbidden(t); apiErr.Explanation != nil {
+			t.Fatalf("Explanation = %+v; want none in production", apiErr.Explanation)
+		}
+	})
+}
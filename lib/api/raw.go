@@ -86,7 +86,7 @@ func (x *Raw) Call(ctx convCtx.Context, body io.Reader) (err error) {
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
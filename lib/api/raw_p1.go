@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewRawP1[p1T ~string](fn func(ctx convCtx.Context, p1 p1T, w http.ResponseWriter, r *http.Request)) RawP1[p1T] {
+func NewRawP1[p1T any](fn func(ctx convCtx.Context, p1 p1T, w http.ResponseWriter, r *http.Request)) RawP1[p1T] {
 	return RawP1[p1T]{
 		fn: fn,
 	}
@@ -34,7 +34,7 @@ func (x RawP1[p1T]) WithPreCheck(check Check) RawP1[p1T] {
 	}
 }
 
-type RawP1[p1T ~string] struct {
+type RawP1[p1T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, w http.ResponseWriter, r *http.Request)
 }
@@ -46,9 +46,14 @@ func (x *RawP1[p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *
 		return false
 	}
 
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
 	x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
+		p1,
 		w,
 		r,
 	)
@@ -70,6 +75,10 @@ func (x *RawP1[p1T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *RawP1[p1T]) setEndpoints(eps endpoints) {}
 
+func (x *RawP1[p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
 func (x *RawP1[p1T]) Call(ctx convCtx.Context, p1 p1T, body io.Reader) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -77,8 +86,9 @@ func (x *RawP1[p1T]) Call(ctx convCtx.Context, p1 p1T, body io.Reader) (err erro
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
+	values, err := formatParams(p1)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, body)
@@ -91,7 +101,7 @@ func (x *RawP1[p1T]) Call(ctx convCtx.Context, p1 p1T, body io.Reader) (err erro
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewRawP2[p1T, p2T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, w http.ResponseWriter, r *http.Request)) RawP2[p1T, p2T] {
+func NewRawP2[p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, w http.ResponseWriter, r *http.Request)) RawP2[p1T, p2T] {
 	return RawP2[p1T, p2T]{
 		fn: fn,
 	}
@@ -34,7 +34,7 @@ func (x RawP2[p1T, p2T]) WithPreCheck(check Check) RawP2[p1T, p2T] {
 	}
 }
 
-type RawP2[p1T, p2T ~string] struct {
+type RawP2[p1T, p2T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, w http.ResponseWriter, r *http.Request)
 }
@@ -46,10 +46,18 @@ func (x *RawP2[p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2) {
+		return true
+	}
+
 	x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
+		p1,
+		p2,
 		w,
 		r,
 	)
@@ -71,6 +79,10 @@ func (x *RawP2[p1T, p2T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *RawP2[p1T, p2T]) setEndpoints(eps endpoints) {}
 
+func (x *RawP2[p1T, p2T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()}
+}
+
 func (x *RawP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, body io.Reader) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -78,9 +90,9 @@ func (x *RawP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, body io.Read
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
+	values, err := formatParams(p1, p2)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, body)
@@ -93,7 +105,7 @@ func (x *RawP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, body io.Read
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewRawP3[p1T, p2T, p3T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, w http.ResponseWriter, r *http.Request)) RawP3[p1T, p2T, p3T] {
+func NewRawP3[p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, w http.ResponseWriter, r *http.Request)) RawP3[p1T, p2T, p3T] {
 	return RawP3[p1T, p2T, p3T]{
 		fn: fn,
 	}
@@ -34,7 +34,7 @@ func (x RawP3[p1T, p2T, p3T]) WithPreCheck(check Check) RawP3[p1T, p2T, p3T] {
 	}
 }
 
-type RawP3[p1T, p2T, p3T ~string] struct {
+type RawP3[p1T, p2T, p3T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, w http.ResponseWriter, r *http.Request)
 }
@@ -46,11 +46,20 @@ func (x *RawP3[p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.ResponseW
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
 	x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
+		p1,
+		p2,
+		p3,
 		w,
 		r,
 	)
@@ -72,6 +81,10 @@ func (x *RawP3[p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *RawP3[p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
 
+func (x *RawP3[p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
 func (x *RawP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, body io.Reader) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -79,10 +92,9 @@ func (x *RawP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T,
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, body)
@@ -95,7 +107,7 @@ func (x *RawP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T,
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewRawP4[p1T, p2T, p3T, p4T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, w http.ResponseWriter, r *http.Request)) RawP4[p1T, p2T, p3T, p4T] {
+func NewRawP4[p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, w http.ResponseWriter, r *http.Request)) RawP4[p1T, p2T, p3T, p4T] {
 	return RawP4[p1T, p2T, p3T, p4T]{
 		fn: fn,
 	}
@@ -34,7 +34,7 @@ func (x RawP4[p1T, p2T, p3T, p4T]) WithPreCheck(check Check) RawP4[p1T, p2T, p3T
 	}
 }
 
-type RawP4[p1T, p2T, p3T, p4T ~string] struct {
+type RawP4[p1T, p2T, p3T, p4T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, w http.ResponseWriter, r *http.Request)
 }
@@ -46,12 +46,22 @@ func (x *RawP4[p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context, w http.Resp
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4) {
+		return true
+	}
+
 	x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
+		p1,
+		p2,
+		p3,
+		p4,
 		w,
 		r,
 	)
@@ -73,6 +83,10 @@ func (x *RawP4[p1T, p2T, p3T, p4T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *RawP4[p1T, p2T, p3T, p4T]) setEndpoints(eps endpoints) {}
 
+func (x *RawP4[p1T, p2T, p3T, p4T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()}
+}
+
 func (x *RawP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, body io.Reader) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -80,11 +94,9 @@ func (x *RawP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
+	values, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, body)
@@ -97,7 +109,7 @@ func (x *RawP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
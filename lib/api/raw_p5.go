@@ -9,7 +9,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewRawP5[p1T, p2T, p3T, p4T, p5T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, w http.ResponseWriter, r *http.Request)) RawP5[p1T, p2T, p3T, p4T, p5T] {
+func NewRawP5[p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, w http.ResponseWriter, r *http.Request)) RawP5[p1T, p2T, p3T, p4T, p5T] {
 	return RawP5[p1T, p2T, p3T, p4T, p5T]{
 		fn: fn,
 	}
@@ -34,7 +34,7 @@ func (x RawP5[p1T, p2T, p3T, p4T, p5T]) WithPreCheck(check Check) RawP5[p1T, p2T
 	}
 }
 
-type RawP5[p1T, p2T, p3T, p4T, p5T ~string] struct {
+type RawP5[p1T, p2T, p3T, p4T, p5T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, w http.ResponseWriter, r *http.Request)
 }
@@ -46,13 +46,24 @@ func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context, w http
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
 	x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
-		p5T(values.GetByIndex(4)),
+		p1,
+		p2,
+		p3,
+		p4,
+		p5,
 		w,
 		r,
 	)
@@ -74,6 +85,10 @@ func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out reflect.Type)
 
 func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
 
+func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
 func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, body io.Reader) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -81,12 +96,9 @@ func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
-		{Name: "", Value: string(p5)},
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, body)
@@ -99,7 +111,7 @@ func (x *RawP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
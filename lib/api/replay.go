@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// The calls logged by EnableCallsLogging (as JSON lines) can be replayed
+// against a local agent to reproduce them, e.g. a production bug:
+//
+//	calls, err := convAPI.ReadRecordedCalls(logs)
+//	res, err := calls[0].Replay(ctx, "https://localhost:8443")
+//	fmt.Println(res.Diffs) // /total: 12 → 14
+//
+// Replays are authorized by freshly minted tokens for the recorded claims
+// (logged with CallsLogging.LogClaims), issued at the recorded time, and run
+// at that time through the Time-Now header.
+
+const callsLogMessage = "API call"
+
+var errNotReplayable = errors.New("the recorded body is not replayable")
+
+// replaySkipHeaders are not replayed as recorded
+var replaySkipHeaders = []string{
+	convAuth.HttpHeaderAuthorization,
+	convCtx.HttpHeaderWorkflow,
+	convCtx.HTTPHeaderTimeNow,
+	convCtx.HttpHeaderTimeBudget,
+	"Content-Length",
+	"Accept-Encoding",
+	"Connection",
+}
+
+// RecordedCall is an API call as logged by EnableCallsLogging
+type RecordedCall struct {
+	Time     time.Time // when the call was served
+	Now      time.Time // the time of the agent when the call was served
+	Workflow string
+	Claims   convAuth.Claims
+	Method   string
+	URL      string
+	Headers  map[string]string
+	Body     any // decoded JSON, text or nil
+	Status   int
+	Response any // decoded JSON, text or nil
+}
+
+type recordedCallLine struct {
+	Time       time.Time       `json:"time"`
+	Msg        string          `json:"msg"`
+	Now        time.Time       `json:"now"`
+	Workflow   string          `json:"workflow"`
+	Claims     convAuth.Claims `json:"claims"`
+	DurationMS int64           `json:"duration_ms"`
+	Request    struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    any               `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status int `json:"status"`
+		Body   any `json:"body"`
+	} `json:"response"`
+}
+
+// ReadRecordedCalls reads the calls logged as JSON lines, skipping other lines
+func ReadRecordedCalls(r io.Reader) (calls []RecordedCall, err error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+
+	for scanner.Scan() {
+
+		var l recordedCallLine
+		if json.Unmarshal(scanner.Bytes(), &l) != nil || l.Msg != callsLogMessage {
+			continue
+		}
+
+		now := l.Now
+		if now.IsZero() {
+			now = l.Time.Add(-time.Duration(l.DurationMS) * time.Millisecond) // when the call started
+		}
+
+		calls = append(calls, RecordedCall{
+			Time:     l.Time,
+			Now:      now,
+			Workflow: l.Workflow,
+			Claims:   l.Claims,
+			Method:   l.Request.Method,
+			URL:      l.Request.URL,
+			Headers:  l.Request.Headers,
+			Body:     l.Request.Body,
+			Status:   l.Response.Status,
+			Response: l.Response.Body,
+		})
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+// ReplayFilter selects recorded calls; zero fields select all
+type ReplayFilter struct {
+	Workflow string
+	User     convAuth.User
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f ReplayFilter) Match(c RecordedCall) bool {
+	return (f.Workflow == "" || f.Workflow == c.Workflow) &&
+		(f.User == "" || f.User == c.Claims.User) &&
+		(f.Since.IsZero() || !c.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || !c.Time.After(f.Until))
+}
+
+// ReplayResult is the response of a replayed call and how it differs from
+// the recorded one
+type ReplayResult struct {
+	Call     RecordedCall
+	Status   int
+	Response any
+	Diffs    []string
+}
+
+func (res ReplayResult) Matches() bool {
+	return len(res.Diffs) == 0
+}
+
+// Replay sends the call again to the agent at baseURL and compares the
+// response with the recorded one, ignoring the fields (dot notation, applied
+// to all elements of lists) and the fields recorded as redacted.
+func (c RecordedCall) Replay(ctx convCtx.Context, baseURL string, ignore ...string) (res ReplayResult, err error) {
+
+	res.Call = c
+
+	body, err := replayBody(c.Body)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.Method, strings.TrimSuffix(baseURL, "/")+c.URL, body)
+	if err != nil {
+		return
+	}
+
+	for name, value := range c.Headers {
+		if value == auditRedacted || containsHeader(replaySkipHeaders, name) {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	token, _, err := convAuth.GenerateTokenWithOptions(c.Claims, convAuth.TokenOptions{
+		IssuedAt: c.Now,
+		Audience: []string{req.URL.Hostname()}, // bound to the agent, as the calls of clients
+	})
+	if err != nil {
+		return
+	}
+	req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+token)
+	if c.Workflow != "" {
+		req.Header.Set(convCtx.HttpHeaderWorkflow, c.Workflow)
+	}
+	req.Header.Set(convCtx.HTTPHeaderTimeNow, c.Now.UTC().Format(time.RFC3339))
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	res.Status = r.StatusCode
+	res.Response = processBody(r.Header.Get("Content-Type"), data)
+
+	if res.Status != c.Status {
+		res.Diffs = append(res.Diffs, fmt.Sprintf("status: %d → %d", c.Status, res.Status))
+	}
+
+	if s, ok := c.Response.(string); ok && (s == "{{binary data}}" || strings.HasSuffix(s, "{{truncated}}")) {
+		return // only the beginning of the body was recorded
+	}
+
+	ignored := make([][]string, len(ignore))
+	for i, f := range ignore {
+		ignored[i] = strings.Split(f, ".")
+	}
+
+	diffResponses(&res.Diffs, "", nil, c.Response, res.Response, ignored)
+
+	return
+}
+
+// replayBody returns the request body as recorded; nil for no body
+func replayBody(recorded any) (io.Reader, error) {
+	switch b := recorded.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if b == "{{binary data}}" || strings.HasSuffix(b, "{{truncated}}") {
+			return nil, errNotReplayable
+		}
+		return strings.NewReader(b), nil
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+}
+
+func containsHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffResponses adds the differences of two JSON values, located by JSON
+// Pointers; names is the path of field names, for the ignored fields
+func diffResponses(diffs *[]string, pointer string, names []string, recorded, actual any, ignored [][]string) {
+
+	if isIgnored(names, ignored) || recorded == auditRedacted {
+		return
+	}
+
+	switch r := recorded.(type) {
+
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := sortedKeys(r)
+		for k := range a {
+			if _, ok := r[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rv, rok := r[k]
+			av, aok := a[k]
+			p := pointer + "/" + escapePointer(k)
+			n := append(append([]string{}, names...), k)
+			switch {
+			case !aok:
+				if !isIgnored(n, ignored) && rv != auditRedacted {
+					*diffs = append(*diffs, fmt.Sprintf("%s: removed", p))
+				}
+			case !rok:
+				if !isIgnored(n, ignored) {
+					*diffs = append(*diffs, fmt.Sprintf("%s: added %s", p, diffValue(av)))
+				}
+			default:
+				diffResponses(diffs, p, n, rv, av, ignored)
+			}
+		}
+		return
+
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			break
+		}
+		if len(r) != len(a) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d → %d elements", pointerOrRoot(pointer), len(r), len(a)))
+		}
+		for i := 0; i < len(r) && i < len(a); i++ {
+			diffResponses(diffs, pointer+"/"+strconv.Itoa(i), names, r[i], a[i], ignored)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(recorded, actual) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s → %s", pointerOrRoot(pointer), diffValue(recorded), diffValue(actual)))
+	}
+}
+
+func isIgnored(names []string, ignored [][]string) bool {
+	for _, i := range ignored {
+		if slices.Equal(i, names) {
+			return true
+		}
+	}
+	return false
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "body"
+	}
+	return pointer
+}
+
+func diffValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
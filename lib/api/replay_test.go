@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type replayQuoteRequest struct {
+	Qty int `json:"qty"`
+}
+
+type replayQuote struct {
+	User  convAuth.User `json:"user"`
+	At    time.Time     `json:"at"`
+	Total int           `json:"total"`
+}
+
+type replayAPI struct {
+	Quote convAPI.InOut[replayQuoteRequest, replayQuote] `api:"POST /test/v1/quotes"`
+}
+
+// syncBuffer collects the log lines written while the server is serving
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_replay(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"POST /test/v1/quotes",
+		},
+	}
+
+	logs := &syncBuffer{}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_replay"}).
+		WithLogger(slog.New(slog.NewJSONHandler(logs, nil)))
+
+	port := portForAPITest(t)
+
+	var price atomic.Int64
+	price.Store(10)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &replayAPI{
+		Quote: convAPI.NewInOut(func(ctx convCtx.Context, in replayQuoteRequest) (replayQuote, error) {
+			return replayQuote{User: ctx.Claims().User, At: ctx.Now(), Total: in.Qty * int(price.Load())}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+	srv.EnableCallsLoggingWith(convAPI.CallsLogging{LogClaims: true})
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	call := func(t *testing.T, claims convAuth.Claims, workflow, now, body string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("https://localhost:%d/test/v1/quotes", port), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(convCtx.HttpHeaderWorkflow, workflow)
+		req.Header.Set(convCtx.HTTPHeaderTimeNow, now)
+		at, _ := time.Parse(time.RFC3339, now)
+		token, _, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{IssuedAt: at, Audience: []string{"localhost"}}) // valid at the time of the call
+		if err != nil {
+			t.Fatalf("GenerateTokenWithOptions() = %v; want nil", err)
+		}
+		req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Do() = %v; want nil", err)
+		}
+		res.Body.Close()
+	}
+
+	alice := convAuth.Claims{User: "alice", Tenants: convAuth.Tenants{"test"}, Roles: convAuth.Roles{"buyer"}}
+
+	call(t, alice, "wf-alice", "2026-03-14T10:00:00Z", `{"qty": 2}`)
+	call(t, convAuth.Claims{User: "bob"}, "wf-bob", "2026-03-14T11:00:00Z", `{"qty": 5}`)
+
+	for i := 0; i < 100 && strings.Count(logs.String(), `"msg":"API call"`) < 2; i++ {
+		time.Sleep(10 * time.Millisecond) // the calls are logged after they are served
+	}
+
+	calls, err := convAPI.ReadRecordedCalls(strings.NewReader("not a log line\n" + logs.String()))
+	if err != nil {
+		t.Fatalf("ReadRecordedCalls() = %v; want nil", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("len(calls) = %d; want 2", len(calls))
+	}
+
+	var recorded []convAPI.RecordedCall
+	for _, c := range calls {
+		if (convAPI.ReplayFilter{User: "alice"}).Match(c) {
+			recorded = append(recorded, c)
+		}
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("len(recorded) = %d; want 1", len(recorded))
+	}
+
+	c := recorded[0]
+	if !reflect.DeepEqual(c.Claims, alice) || c.Workflow != "wf-alice" || !c.Now.Equal(time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("recorded = %+v; want the call of alice", c)
+	}
+
+	t.Run("same", func(t *testing.T) {
+		res, err := c.Replay(agentCtx, fmt.Sprintf("https://localhost:%d", port))
+		if err != nil {
+			t.Fatalf("Replay() = %v; want nil", err)
+		}
+		if !res.Matches() {
+			t.Fatalf("Replay().Diffs = %v; want none", res.Diffs)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		price.Store(15)
+		defer price.Store(10)
+
+		res, err := c.Replay(agentCtx, fmt.Sprintf("https://localhost:%d", port))
+		if err != nil {
+			t.Fatalf("Replay() = %v; want nil", err)
+		}
+		if want := []string{"/total: 20 → 30"}; !reflect.DeepEqual(res.Diffs, want) {
+			t.Fatalf("Replay().Diffs = %v; want %v", res.Diffs, want)
+		}
+
+		res, err = c.Replay(agentCtx, fmt.Sprintf("https://localhost:%d", port), "total")
+		if err != nil {
+			t.Fatalf("Replay() = %v; want nil", err)
+		}
+		if !res.Matches() {
+			t.Fatalf("Replay(ignore total).Diffs = %v; want none", res.Diffs)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// Instance is one address serving a client target.
+type Instance struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func (i Instance) String() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// Resolver returns the instances serving a client target.
+type Resolver interface {
+	Resolve(ctx convCtx.Context) ([]Instance, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ctx convCtx.Context) ([]Instance, error)
+
+func (f ResolverFunc) Resolve(ctx convCtx.Context) ([]Instance, error) {
+	return f(ctx)
+}
+
+var ErrNoInstances = errors.New("no instances resolved")
+
+const resolverDefaultTTL = 30 * time.Second
+
+// NewStaticResolver always resolves to the given instances.
+func NewStaticResolver(instances ...Instance) Resolver {
+	return ResolverFunc(func(ctx convCtx.Context) ([]Instance, error) {
+		return instances, nil
+	})
+}
+
+// NewDNSResolver resolves the A and AAAA records of host, all served on port;
+// the records are cached for ttl (0 for 30 seconds).
+func NewDNSResolver(host string, port int, ttl time.Duration) Resolver {
+	return newCachedResolver(ttl, func(ctx convCtx.Context) (res []Instance, err error) {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return
+		}
+		for _, addr := range addrs {
+			res = append(res, Instance{Host: addr, Port: port})
+		}
+		return
+	})
+}
+
+// NewSRVResolver resolves the SRV records of _service._proto.name (e.g.
+// "https", "tcp", "orders.default.svc"); the records are cached for ttl
+// (0 for 30 seconds).
+func NewSRVResolver(service, proto, name string, ttl time.Duration) Resolver {
+	return newCachedResolver(ttl, func(ctx convCtx.Context) (res []Instance, err error) {
+		_, srvs, err := net.DefaultResolver.LookupSRV(ctx, service, proto, name)
+		if err != nil {
+			return
+		}
+		for _, srv := range srvs {
+			res = append(res, Instance{Host: strings.TrimSuffix(srv.Target, "."), Port: int(srv.Port)})
+		}
+		return
+	})
+}
+
+// cachedResolver keeps the last lookup for ttl, and after that as long as
+// new lookups fail.
+type cachedResolver struct {
+	ttl    time.Duration
+	lookup func(ctx convCtx.Context) ([]Instance, error)
+
+	mu        sync.Mutex
+	instances []Instance
+	expires   time.Time
+}
+
+func newCachedResolver(ttl time.Duration, lookup func(ctx convCtx.Context) ([]Instance, error)) *cachedResolver {
+	if ttl <= 0 {
+		ttl = resolverDefaultTTL
+	}
+	return &cachedResolver{ttl: ttl, lookup: lookup}
+}
+
+func (cr *cachedResolver) Resolve(ctx convCtx.Context) ([]Instance, error) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if time.Now().Before(cr.expires) {
+		return cr.instances, nil
+	}
+
+	instances, err := cr.lookup(ctx)
+	if err != nil || len(instances) == 0 {
+		if len(cr.instances) > 0 {
+			ctx.Logger().Warn("unable to resolve instances; using the last resolved", "error", err)
+			return cr.instances, nil
+		}
+		if err == nil {
+			err = ErrNoInstances
+		}
+		return nil, err
+	}
+
+	cr.instances = instances
+	cr.expires = time.Now().Add(cr.ttl)
+
+	return instances, nil
+}
+
+// NewConfigResolver resolves to the instances listed in the config file of
+// key, as a JSON array of "host:port" strings. The file is read again when it
+// changes, checked at most once a second.
+func NewConfigResolver(key convCfg.ConfigKey) Resolver {
+	return &configResolver{key: key}
+}
+
+const configResolverCheckEvery = time.Second
+
+type configResolver struct {
+	key convCfg.ConfigKey
+
+	mu        sync.Mutex
+	instances []Instance
+	modTime   time.Time
+	checked   time.Time
+}
+
+func (cr *configResolver) Resolve(ctx convCtx.Context) ([]Instance, error) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if time.Since(cr.checked) < configResolverCheckEvery && len(cr.instances) > 0 {
+		return cr.instances, nil
+	}
+	cr.checked = time.Now()
+
+	fi, err := os.Stat(convCfg.FilePath(cr.key))
+	if err != nil {
+		return cr.fallback(ctx, err)
+	}
+	if fi.ModTime().Equal(cr.modTime) && len(cr.instances) > 0 {
+		return cr.instances, nil
+	}
+
+	addrs, err := convCfg.Object[[]string](cr.key)
+	if err != nil {
+		return cr.fallback(ctx, err)
+	}
+
+	var instances []Instance
+	for _, addr := range addrs {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return cr.fallback(ctx, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return cr.fallback(ctx, err)
+		}
+		instances = append(instances, Instance{Host: host, Port: port})
+	}
+	if len(instances) == 0 {
+		return cr.fallback(ctx, ErrNoInstances)
+	}
+
+	if len(cr.instances) > 0 {
+		ctx.Logger().Info("instances reloaded", "config", string(cr.key), "instances", len(instances))
+	}
+
+	cr.instances = instances
+	cr.modTime = fi.ModTime()
+
+	return instances, nil
+}
+
+func (cr *configResolver) fallback(ctx convCtx.Context, err error) ([]Instance, error) {
+	if len(cr.instances) > 0 {
+		ctx.Logger().Warn("unable to reload instances; using the last loaded", "config", string(cr.key), "error", err)
+		return cr.instances, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// JSONSchema is a standalone JSON Schema (2020-12) document of a type, built
+// from the same type model as the OpenAPI specification, for teams and
+// non-Go clients to validate payloads:
+//
+//	schema, err := json.Marshal(convAPI.NewJSONSchema[Order]())
+//
+// Validate checks a payload against the schema, locating each violation by a
+// JSON Pointer.
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// objectEncoding is how the values of types with their own encoding are encoded
+type objectEncoding string
+
+const (
+	objectEncodingText objectEncoding = "text" // a JSON string, by encoding.TextMarshaler
+	objectEncodingJSON objectEncoding = "json" // any JSON value, by json.Marshaler
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	timeType            = reflect.TypeFor[time.Time]()
+)
+
+func encodingOfType(t reflect.Type) objectEncoding {
+
+	if t == timeType {
+		return "" // a date-time
+	}
+
+	pt := reflect.PointerTo(t)
+
+	switch {
+	case t.Implements(jsonMarshalerType), pt.Implements(jsonMarshalerType), pt.Implements(jsonUnmarshalerType):
+		return objectEncodingJSON
+	case t.Implements(textMarshalerType), pt.Implements(textMarshalerType), pt.Implements(textUnmarshalerType):
+		return objectEncodingText
+	default:
+		return ""
+	}
+}
+
+type JSONSchema struct {
+	object        *object
+	substitutions map[string]*object
+	enums         map[string][]string
+}
+
+func NewJSONSchema[T any]() JSONSchema {
+	return JSONSchema{
+		object: objectFromType(reflect.TypeFor[T](), false),
+	}
+}
+
+func (s JSONSchema) WithTypeSubstitutions(subs ...typeSubstitution) JSONSchema {
+	if s.substitutions == nil {
+		s.substitutions = make(map[string]*object)
+	}
+	for _, sub := range subs {
+		s.substitutions[sub.from.ID] = sub.to
+	}
+	return s
+}
+
+func (s JSONSchema) WithEnums(enums ...enum) JSONSchema {
+	if s.enums == nil {
+		s.enums = make(map[string][]string)
+	}
+	for _, e := range enums {
+		s.enums[e.object.ID] = e.values
+	}
+	return s
+}
+
+func (s JSONSchema) objOrSub(o *object) *object {
+	if sub, ok := s.substitutions[o.ID]; ok {
+		return sub
+	}
+	return o
+}
+
+// isDef tells whether the schema of the object is in $defs, referenced by name
+func (s JSONSchema) isDef(o *object) bool {
+	if _, ok := s.enums[o.ID]; ok {
+		return true
+	}
+	return o.Encoding == "" && o.Type == objectTypeObject && o.Fields != nil
+}
+
+func (s JSONSchema) MarshalJSON() ([]byte, error) {
+
+	if s.object == nil {
+		return nil, errors.New("json schema of no type")
+	}
+
+	defs := map[string]*object{}
+	s.collectDefs(defs, map[*object]bool{}, s.object)
+
+	names := defNames(defs)
+
+	doc := s.schemaOf(s.object, names)
+	doc["$schema"] = jsonSchemaDialect
+	doc["title"] = s.objOrSub(s.object).Name
+
+	if len(defs) > 0 {
+		schemas := map[string]any{}
+		for id, o := range defs {
+			schemas[names[id]] = s.defSchema(o, names)
+		}
+		doc["$defs"] = schemas
+	}
+
+	return json.Marshal(doc)
+}
+
+func (s JSONSchema) collectDefs(defs map[string]*object, visited map[*object]bool, o *object) {
+
+	if o == nil {
+		return
+	}
+
+	o = s.objOrSub(o)
+
+	if visited[o] {
+		return
+	}
+	visited[o] = true
+
+	if o.Type == objectTypeTime || o.Encoding != "" {
+		return
+	}
+
+	if s.isDef(o) {
+		if _, ok := defs[o.ID]; ok {
+			return
+		}
+		defs[o.ID] = o
+	}
+
+	s.collectDefs(defs, visited, o.Elem)
+	for _, f := range o.Fields {
+		s.collectDefs(defs, visited, f)
+	}
+}
+
+// defNames names the $defs by their types, numbering the ones of the same name
+func defNames(defs map[string]*object) map[string]string {
+
+	sorted := make([]*object, 0, len(defs))
+	for _, o := range defs {
+		sorted = append(sorted, o)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	names := map[string]string{}
+	seen := map[string]int{}
+	for _, o := range sorted {
+		name := o.Name
+		if n, ok := seen[o.Name]; ok {
+			seen[o.Name] = n + 1
+			name = fmt.Sprintf("%s_%d", o.Name, n+1)
+		} else {
+			seen[o.Name] = 0
+		}
+		names[o.ID] = name
+	}
+
+	return names
+}
+
+// schemaOf returns the schema of a value of the object where it is used
+func (s JSONSchema) schemaOf(o *object, names map[string]string) (sc map[string]any) {
+
+	nullable := !o.Mandatory
+	o = s.objOrSub(o)
+
+	switch {
+	case o.Type == objectTypeTime:
+		sc = map[string]any{"type": "string", "format": "date-time"}
+	case o.Encoding == objectEncodingJSON:
+		return map[string]any{} // any value
+	case o.Encoding == objectEncodingText:
+		sc = map[string]any{"type": "string"}
+	case s.isDef(o):
+		sc = map[string]any{"$ref": "#/$defs/" + names[o.ID]}
+	case o.Type == objectTypeArray:
+		sc = map[string]any{"type": "array", "items": s.schemaOf(o.Elem, names)}
+	case o.Type == objectTypeMap:
+		sc = map[string]any{"type": "object", "additionalProperties": s.schemaOf(o.Elem, names)}
+	case o.Type.IsSimple():
+		sc = map[string]any{"type": string(o.Type)}
+	default:
+		return map[string]any{} // interfaces hold any value
+	}
+
+	if !nullable {
+		return
+	}
+
+	if typ, ok := sc["type"].(string); ok {
+		sc["type"] = []string{typ, "null"}
+		return
+	}
+
+	return map[string]any{"anyOf": []any{sc, map[string]any{"type": "null"}}}
+}
+
+// defSchema returns the schema in $defs of the object
+func (s JSONSchema) defSchema(o *object, names map[string]string) map[string]any {
+
+	if values, ok := s.enums[o.ID]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	props := map[string]any{}
+	required := []string{}
+	for name, f := range o.Fields {
+		props[name] = s.schemaOf(f, names)
+		if f.Mandatory {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	sc := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sc["required"] = required
+	}
+
+	return sc
+}
+
+// SchemaViolation is a part of a JSON payload not matching its schema
+type SchemaViolation struct {
+	Pointer string // JSON Pointer (RFC 6901) to the part; empty for the whole payload
+	Message string
+}
+
+type SchemaViolations []SchemaViolation
+
+func (vs SchemaViolations) Error() string {
+	sb := strings.Builder{}
+	for i, v := range vs {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString("#")
+		sb.WriteString(v.Pointer)
+		sb.WriteString(": ")
+		sb.WriteString(v.Message)
+	}
+	return sb.String()
+}
+
+// Validate checks the JSON payload against the schema, returning the
+// SchemaViolations found, or the error of an invalid JSON payload
+func (s JSONSchema) Validate(payload []byte) error {
+
+	if s.object == nil {
+		return errors.New("json schema of no type")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return err
+	}
+
+	var vs SchemaViolations
+	s.validate(v, s.object, "", &vs)
+	if len(vs) > 0 {
+		return vs
+	}
+
+	return nil
+}
+
+func (s JSONSchema) validate(v any, o *object, pointer string, vs *SchemaViolations) {
+
+	add := func(format string, args ...any) {
+		*vs = append(*vs, SchemaViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	expect := func(typ string) bool {
+		if got := jsonTypeName(v); got != typ {
+			add("expected %s, got %s", typ, got)
+			return false
+		}
+		return true
+	}
+
+	mandatory := o.Mandatory
+	o = s.objOrSub(o)
+
+	if o.Encoding == objectEncodingJSON || (o.Type == objectTypeObject && o.Fields == nil) {
+		return // any value
+	}
+
+	if v == nil {
+		if mandatory {
+			add("expected %s, got null", schemaTypeName(o))
+		}
+		return
+	}
+
+	if values, ok := s.enums[o.ID]; ok {
+		if expect("string") && !slices.Contains(values, v.(string)) {
+			add("expected one of %s", strings.Join(values, ", "))
+		}
+		return
+	}
+
+	switch {
+	case o.Type == objectTypeTime:
+		if expect("string") {
+			_, err := time.Parse(time.RFC3339Nano, v.(string))
+			if err != nil {
+				add("expected a date-time")
+			}
+		}
+		return
+	case o.Encoding == objectEncodingText:
+		expect("string")
+		return
+	}
+
+	switch o.Type {
+
+	case objectTypeString:
+		expect("string")
+
+	case objectTypeBoolean:
+		expect("boolean")
+
+	case objectTypeNumber:
+		expect("number")
+
+	case objectTypeInteger:
+		if n, ok := v.(json.Number); !ok || strings.ContainsAny(string(n), ".eE") {
+			add("expected integer, got %s", jsonTypeName(v))
+		}
+
+	case objectTypeArray:
+		if expect("array") {
+			for i, e := range v.([]any) {
+				s.validate(e, o.Elem, pointer+"/"+strconv.Itoa(i), vs)
+			}
+		}
+
+	case objectTypeMap:
+		if expect("object") {
+			m := v.(map[string]any)
+			for _, k := range sortedKeys(m) {
+				s.validate(m[k], o.Elem, pointer+"/"+escapePointer(k), vs)
+			}
+		}
+
+	case objectTypeObject:
+		if expect("object") {
+			m := v.(map[string]any)
+			for _, name := range sortedKeys(o.Fields) {
+				f := o.Fields[name]
+				fv, ok := m[name]
+				if !ok {
+					if f.Mandatory {
+						*vs = append(*vs, SchemaViolation{Pointer: pointer + "/" + escapePointer(name), Message: "missing required field"})
+					}
+					continue
+				}
+				s.validate(fv, f, pointer+"/"+escapePointer(name), vs)
+			}
+		}
+	}
+}
+
+// jsonTypeName returns the JSON type of a value decoded with numbers
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func schemaTypeName(o *object) string {
+	switch o.Type {
+	case objectTypeTime, objectTypeEnum:
+		return "string"
+	case objectTypeMap:
+		return "object"
+	}
+	if o.Encoding == objectEncodingText {
+		return "string"
+	}
+	return string(o.Type)
+}
+
+// escapePointer escapes a name as a JSON Pointer reference token
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// validateBody serves bad request when the body of a call does not match the
+// schema of the endpoint input; the body is kept for decoding.
+func validateBody(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, desc descriptor) bool {
+
+	if desc.in == nil || r.Body == nil || isWebSocketUpgrade(r) {
+		return true
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to read http payload", err)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = JSONSchema{object: desc.in}.Validate(body)
+	var vs SchemaViolations
+	if errors.As(err, &vs) {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "http payload does not match the schema", vs)
+		return false
+	}
+
+	return true // invalid JSON is reported when decoding
+}
@@ -0,0 +1,271 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type schemaStatus string
+
+type schemaLine struct {
+	SKU   string   `json:"sku"`
+	Qty   int      `json:"qty"`
+	Price *float64 `json:"price"`
+}
+
+type schemaOrder struct {
+	ID      uuid.UUID         `json:"id"`
+	Status  schemaStatus      `json:"status"`
+	Notes   string            `json:"notes,omitempty"`
+	Lines   []schemaLine      `json:"lines"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+	Parent  *schemaOrder      `json:"parent"`
+	Extra   json.RawMessage   `json:"extra"`
+}
+
+func Test_json_schema(t *testing.T) {
+
+	schema := convAPI.NewJSONSchema[schemaOrder]().
+		WithEnums(convAPI.NewEnum[schemaStatus]("open", "closed"))
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent() = %v; want nil", err)
+	}
+
+	expected := `{
+  "$defs": {
+    "schema_line": {
+      "properties": {
+        "price": {
+          "type": [
+            "number",
+            "null"
+          ]
+        },
+        "qty": {
+          "type": "integer"
+        },
+        "sku": {
+          "type": "string"
+        }
+      },
+      "required": [
+        "qty",
+        "sku"
+      ],
+      "type": "object"
+    },
+    "schema_order": {
+      "properties": {
+        "created": {
+          "format": "date-time",
+          "type": "string"
+        },
+        "extra": {},
+        "id": {
+          "type": [
+            "string",
+            "null"
+          ]
+        },
+        "labels": {
+          "additionalProperties": {
+            "type": "string"
+          },
+          "type": [
+            "object",
+            "null"
+          ]
+        },
+        "lines": {
+          "items": {
+            "$ref": "#/$defs/schema_line"
+          },
+          "type": [
+            "array",
+            "null"
+          ]
+        },
+        "notes": {
+          "type": [
+            "string",
+            "null"
+          ]
+        },
+        "parent": {
+          "anyOf": [
+            {
+              "$ref": "#/$defs/schema_order"
+            },
+            {
+              "type": "null"
+            }
+          ]
+        },
+        "status": {
+          "$ref": "#/$defs/schema_status"
+        }
+      },
+      "required": [
+        "created",
+        "status"
+      ],
+      "type": "object"
+    },
+    "schema_status": {
+      "enum": [
+        "open",
+        "closed"
+      ],
+      "type": "string"
+    }
+  },
+  "$ref": "#/$defs/schema_order",
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "schema_order"
+}`
+
+	if string(data) != expected {
+		t.Fatalf("json schema = %s; want %s", data, expected)
+	}
+}
+
+func Test_json_schema_validate(t *testing.T) {
+
+	schema := convAPI.NewJSONSchema[schemaOrder]().
+		WithEnums(convAPI.NewEnum[schemaStatus]("open", "closed"))
+
+	err := schema.Validate([]byte(`{
+		"id": "3b241101-e2bb-4255-8caf-4136c566a962",
+		"status": "open",
+		"lines": [{"sku": "a", "qty": 1, "price": null}],
+		"labels": {"color": "red"},
+		"created": "2026-03-14T00:00:00Z",
+		"extra": [1, "any"]
+	}`))
+	if err != nil {
+		t.Fatalf("Validate() = %v; want nil", err)
+	}
+
+	err = schema.Validate([]byte(`{
+		"id": 7,
+		"status": "pending",
+		"lines": [{"sku": "a", "qty": 1.5}, {"qty": "2"}],
+		"labels": {"a/b": 1},
+		"created": "yesterday",
+		"parent": {"status": null, "created": "2026-03-14T00:00:00Z"}
+	}`))
+
+	var vs convAPI.SchemaViolations
+	if !errors.As(err, &vs) {
+		t.Fatalf("Validate() = %v; want SchemaViolations", err)
+	}
+
+	expected := convAPI.SchemaViolations{
+		{Pointer: "/created", Message: "expected a date-time"},
+		{Pointer: "/id", Message: "expected string, got number"},
+		{Pointer: "/labels/a~1b", Message: "expected string, got number"},
+		{Pointer: "/lines/0/qty", Message: "expected integer, got number"},
+		{Pointer: "/lines/1/qty", Message: "expected integer, got string"},
+		{Pointer: "/lines/1/sku", Message: "missing required field"},
+		{Pointer: "/parent/status", Message: "expected string, got null"},
+		{Pointer: "/status", Message: "expected one of open, closed"},
+	}
+	if !reflect.DeepEqual(vs, expected) {
+		t.Fatalf("Validate() = %v; want %v", vs, expected)
+	}
+
+	err = schema.Validate([]byte(`{`))
+	if err == nil || errors.As(err, &vs) {
+		t.Fatalf("Validate() = %v; want a JSON syntax error", err)
+	}
+}
+
+type schemaAPI struct {
+	Place convAPI.In[schemaOrder] `api:"POST /test/v1/orders"`
+}
+
+func Test_schema_validation(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"POST /test/v1/orders",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_schema_validation"})
+
+	port := portForAPITest(t)
+
+	placed := make(chan schemaOrder, 16)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &schemaAPI{
+		Place: convAPI.NewIn(func(ctx convCtx.Context, in schemaOrder) error {
+			placed <- in
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+	srv.EnableSchemaValidation()
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	post := func(t *testing.T, body string) (status int, message string) {
+		t.Helper()
+		res, err := http.Post(fmt.Sprintf("https://localhost:%d/test/v1/orders", port), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("http.Post() = %v; want nil", err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(data)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		status, _ := post(t, `{"status": "open", "created": "2026-03-14T00:00:00Z", "lines": [{"sku": "a", "qty": 2}]}`)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d; want %d", status, http.StatusOK)
+		}
+		in := <-placed
+		if in.Status != "open" || len(in.Lines) != 1 || in.Lines[0].Qty != 2 {
+			t.Fatalf("placed = %+v; want the posted order", in)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		status, message := post(t, `{"status": "open", "lines": [{"sku": "a", "qty": "2"}]}`)
+		if status != http.StatusBadRequest {
+			t.Fatalf("status = %d; want %d", status, http.StatusBadRequest)
+		}
+		for _, want := range []string{"#/created: missing required field", "#/lines/0/qty: expected integer, got string"} {
+			if !strings.Contains(message, want) {
+				t.Fatalf("message = %s; want it to contain %q", message, want)
+			}
+		}
+		select {
+		case in := <-placed:
+			t.Fatalf("placed = %+v; want no call", in)
+		default:
+		}
+	})
+}
@@ -1,16 +1,21 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
-	"net/http/httptest"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	convAuth "github.com/sofmon/convention/lib/auth"
 	convCfg "github.com/sofmon/convention/lib/cfg"
@@ -19,6 +24,8 @@ import (
 
 type server struct {
 	httpServer *http.Server
+	policy     convAuth.Policy
+	reloadable *convAuth.ReloadablePolicy // nil unless UseReloadablePolicy
 }
 
 func NewServer(ctx convCtx.Context, host string, port int, policy convAuth.Policy, svc any) (srv *server, err error) {
@@ -27,25 +34,140 @@ func NewServer(ctx convCtx.Context, host string, port int, policy convAuth.Polic
 		port = 443
 	}
 
-	check, err := convAuth.NewCheck(policy)
+	audiences := serverAudiences(ctx, host)
+
+	check, err := convAuth.NewCheck(policy, audiences...)
+	if err != nil {
+		return
+	}
+
+	h := newHandler(ctx, host, port, check, svc)
+	h.(*httpHandler).audiences = audiences
+	h.(*httpHandler).policy = func() convAuth.Policy { return policy }
+
+	err = checkEndpointTags(h.(*httpHandler).eps)
+	if err != nil {
+		return
+	}
+
+	err = checkEndpointParams(h.(*httpHandler).eps)
 	if err != nil {
 		return
 	}
 
+	for _, issue := range validatePolicy(policy, h.(*httpHandler).eps) {
+		ctx.Logger().Warn("policy does not fit the api", "issue", issue.String())
+	}
+
 	srv = &server{
-		&http.Server{
+		httpServer: &http.Server{
 			Addr:    fmt.Sprintf("%s:%d", host, port),
-			Handler: NewHandler(ctx, host, port, check, svc),
+			Handler: h,
 		},
+		policy: policy,
 	}
 
 	return
 }
 
+// serverAudiences returns the audiences of the tokens a server accepts: the
+// name of its agent, and its host unless the host is only a listening address
+func serverAudiences(ctx convCtx.Context, host string) (audiences []string) {
+	if agent := string(ctx.Agent()); agent != "" {
+		audiences = append(audiences, agent)
+	}
+	if host != "" && net.ParseIP(host) == nil && !slices.Contains(audiences, host) {
+		audiences = append(audiences, host)
+	}
+	return
+}
+
+// AcceptAudiences accepts tokens minted for other names of the server, such
+// as the fully qualified name of its host.
+func (srv *server) AcceptAudiences(audiences ...string) error {
+
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if !ok {
+		return nil
+	}
+
+	var check convAuth.Check
+	if srv.reloadable != nil {
+		check = srv.reloadable.Check(append(h.audiences, audiences...)...)
+	} else {
+		var err error
+		check, err = convAuth.NewCheck(srv.policy, append(h.audiences, audiences...)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	h.audiences = append(h.audiences, audiences...)
+	h.check = check
+
+	return nil
+}
+
+// UseReloadablePolicy checks the calls against the policy, instead of the
+// one of NewServer, picking up its new versions as they are reloaded:
+//
+//	policy, err := convAuth.LoadPolicy("policy")
+//	// ...
+//	go policy.Watch(ctx, ctx.Logger())
+//	srv.UseReloadablePolicy(ctx, policy)
+func (srv *server) UseReloadablePolicy(ctx convCtx.Context, policy *convAuth.ReloadablePolicy) {
+
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if !ok {
+		return
+	}
+
+	for _, issue := range validatePolicy(policy.Policy(), h.eps) {
+		ctx.Logger().Warn("policy does not fit the api", "issue", issue.String())
+	}
+
+	srv.reloadable = policy
+	h.check = policy.Check(h.audiences...)
+	h.policy = policy.Policy
+}
+
 func (srv *server) EnableCallsLogging() {
+	srv.EnableCallsLoggingWith(CallsLogging{})
+}
+
+// EnableCallsLoggingWith logs the calls with body size caps and redaction.
+func (srv *server) EnableCallsLoggingWith(logging CallsLogging) {
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if ok {
+		h.logging = &logging
+	}
+}
+
+// EnableCORS answers browser preflight requests before authorization and
+// adds the CORS headers to the responses of allowed origins.
+func (srv *server) EnableCORS(cors CORS) {
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if ok {
+		h.cors = &cors
+	}
+}
+
+// SetDefaultTimeout limits the calls to endpoints without a `timeout` tag;
+// WebSocket connections are not limited by the default.
+func (srv *server) SetDefaultTimeout(timeout time.Duration) {
+	h, ok := srv.httpServer.Handler.(*httpHandler)
+	if ok {
+		h.timeout = timeout
+	}
+}
+
+// EnableSchemaValidation validates the bodies of calls against the JSON Schema
+// of the endpoint input before decoding them, serving bad request with the
+// JSON Pointer of each violation.
+func (srv *server) EnableSchemaValidation() {
 	h, ok := srv.httpServer.Handler.(*httpHandler)
 	if ok {
-		h.logCalls = true
+		h.validateSchemas = true
 	}
 }
 
@@ -56,7 +178,26 @@ func (srv *server) SkipDecodeClaims() {
 	}
 }
 
+// ListenAndServe serves the api over TLS. Servers checking no token audience
+// (of a context without agent, with an empty host or an IP address, and
+// without AcceptAudiences) would accept tokens minted for other agents, so
+// they are refused. Accepting tokens without aud (convAuth.RequireAudience
+// cleared) or without exp (convAuth.AcceptTokensWithoutExpiry) is logged as a
+// warning.
 func (srv *server) ListenAndServe() (err error) {
+
+	if h, ok := srv.httpServer.Handler.(*httpHandler); ok {
+		if len(h.audiences) == 0 {
+			return errors.New("server checks no token audience; create it with the context of its agent, its host name, or call AcceptAudiences")
+		}
+		if !convAuth.RequireAudience {
+			h.ctx.Logger().Warn("convAuth.RequireAudience is cleared, so tokens without aud are accepted")
+		}
+		if convAuth.AcceptTokensWithoutExpiry {
+			h.ctx.Logger().Warn("convAuth.AcceptTokensWithoutExpiry is set, so tokens without exp never expire")
+		}
+	}
+
 	return srv.httpServer.ListenAndServeTLS(
 		convCfg.FilePath("communication_certificate"), // following convention/v1
 		convCfg.FilePath("communication_key"),         // following convention/v1
@@ -67,8 +208,58 @@ func (srv *server) Shutdown(ctx convCtx.Context) (err error) {
 	return srv.httpServer.Shutdown(ctx)
 }
 
+// NewHandler panics on endpoints with an invalid struct tag or path
+// parameter type, which NewServer returns as an error.
 func NewHandler(ctx convCtx.Context, host string, port int, check convAuth.Check, svc any) http.Handler {
-	return &httpHandler{ctx, computeEndpoints(host, port, svc), check, false, false}
+
+	h := newHandler(ctx, host, port, check, svc)
+
+	err := checkEndpointTags(h.(*httpHandler).eps)
+	if err == nil {
+		err = checkEndpointParams(h.(*httpHandler).eps)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+func newHandler(ctx convCtx.Context, host string, port int, check convAuth.Check, svc any) http.Handler {
+
+	h := &httpHandler{
+		ctx:   ctx,
+		eps:   computeEndpoints(host, port, svc),
+		check: check,
+	}
+
+	// endpoints re-dispatching requests (e.g. Batch) go through the full handler
+	for _, ep := range h.eps {
+		if ha, ok := ep.(interface{ setHandler(h http.Handler) }); ok {
+			ha.setHandler(h)
+		}
+	}
+
+	return h
+}
+
+// serveForbidden serves the forbidden error, explaining the decision of the
+// policy outside production environments
+func (h *httpHandler) serveForbidden(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, err error) {
+
+	e := newError(ctx, http.StatusForbidden, ErrorCodeForbidden, "missing or wrong authentication token", err)
+
+	if h.policy != nil && !isProdEnv(ctx) {
+		claims, decodeErr := convAuth.DecodeHTTPRequestClaimsAt(r, ctx.Now(), h.audiences...)
+		if decodeErr == nil {
+			explanation, explainErr := convAuth.Explain(h.policy(), claims, convAuth.Action(r.Method+" "+r.URL.Path))
+			if explainErr == nil {
+				e.Explanation = &explanation
+			}
+		}
+	}
+
+	serveError(w, e)
 }
 
 func computeEndpoints(host string, port int, api any) (eps endpoints) {
@@ -83,9 +274,41 @@ func computeEndpoints(host string, port int, api any) (eps endpoints) {
 		apiTag := f.Tag.Get("api")
 		in, out := ep.getInOutTypes()
 		desc := newDescriptor(host, port, apiTag, in, out)
+		desc.audit = parseAuditTag(f.Tag.Get("audit"))
+		if tag := f.Tag.Get("timeout"); tag != "" { // absent: the server default applies
+			var err error
+			desc.timeout, err = time.ParseDuration(tag)
+			if err != nil {
+				desc.tagErr = fmt.Errorf("invalid timeout tag: %w", err)
+			}
+		}
+		desc.perms = parsePermTag(f.Tag.Get("perm"))
+		desc.public = parsePublicTag(f.Tag.Get("public"))
+		fields, err := parseFieldsTag(f.Tag.Get("fields"))
+		if err != nil && desc.tagErr == nil {
+			desc.tagErr = err
+		}
+		desc.fields = fields
+		if desc.fields {
+			desc.query = append(desc.query, queryParam{Name: queryFields, Type: objectTypeString, Description: queryFieldsDescription})
+		}
+		if pt, ok := ep.(interface{ getParamTypes() []reflect.Type }); ok {
+			desc.params = pt.getParamTypes()
+		}
+		desc.bindEndpoint(ep)
 		ep.setDescriptor(desc)
 
 		eps = append(eps, ep)
+
+		// endpoints serving additional routes (e.g. Async operations)
+		if sub, ok := ep.(interface{ subEndpoints() endpoints }); ok {
+			for _, s := range sub.subEndpoints() {
+				sd := s.getDescriptor()
+				sd.perms, sd.public, sd.params = desc.perms, desc.public, desc.params
+				s.setDescriptor(sd)
+				eps = append(eps, s)
+			}
+		}
 	}
 
 	sort.Slice(
@@ -102,26 +325,59 @@ func computeEndpoints(host string, port int, api any) (eps endpoints) {
 	return
 }
 
+// checkEndpointTags fails on the first endpoint with an invalid struct tag
+func checkEndpointTags(eps endpoints) error {
+	for _, ep := range eps {
+		desc := ep.getDescriptor()
+		if desc.tagErr != nil {
+			return fmt.Errorf("endpoint '%s %s': %w", desc.method, desc.path(), desc.tagErr)
+		}
+	}
+	return nil
+}
+
 type httpHandler struct {
 	ctx              convCtx.Context
 	eps              endpoints
 	check            convAuth.Check
-	logCalls         bool
+	logging          *CallsLogging
 	skipDecodeClaims bool
+	cors             *CORS
+	auditor          *auditor
+	timeout          time.Duration
+	audiences        []string
+	validateSchemas  bool
+	policy           func() convAuth.Policy // explaining forbidden calls; nil for NewHandler
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := h.ctx.WithRequest(r, !h.skipDecodeClaims)
+	ctx := h.ctx.WithRequest(r, !h.skipDecodeClaims, h.audiences...)
 
-	_, err := h.check(r)
+	if h.cors != nil && h.cors.serve(ctx, w, r, h.eps) {
+		return // preflight answered, no authorization needed
+	}
+
+	target, err := h.check(r.WithContext(convAuth.WithValidationTime(r.Context(), ctx.Now())))
 	if err != nil {
-		switch err {
-		case convAuth.ErrMissingRequest:
+		switch {
+		case errors.Is(err, convAuth.ErrMissingRequest):
 			ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "missing http request", err)
 			return
-		case convAuth.ErrForbidden,
-			convAuth.ErrMissingAuthorizationHeader,
-			convAuth.ErrInvalidAuthorizationToken:
+		case errors.Is(err, convAuth.ErrTokenExpired),
+			errors.Is(err, convAuth.ErrTokenNotValidYet):
+			ServeError(ctx, w, http.StatusUnauthorized, ErrorCodeUnauthorized, "expired or not yet valid authentication token", err)
+			return
+		case errors.Is(err, convAuth.ErrTokenRevoked):
+			ServeError(ctx, w, http.StatusUnauthorized, ErrorCodeUnauthorized, "revoked authentication token", err)
+			return
+		case errors.Is(err, convAuth.ErrForbidden):
+			h.serveForbidden(ctx, w, r, err)
+			return
+		case errors.Is(err, convAuth.ErrMissingAuthorizationHeader),
+			errors.Is(err, convAuth.ErrInvalidAuthorizationToken),
+			errors.Is(err, convAuth.ErrTokenMalformed),
+			errors.Is(err, convAuth.ErrInvalidIssuer),
+			errors.Is(err, convAuth.ErrInvalidAudience):
 			ServeError(ctx, w, http.StatusForbidden, ErrorCodeForbidden, "missing or wrong authentication token", err)
 			return
 		default:
@@ -130,13 +386,55 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if h.logCalls {
-		logCall(ctx, w, r, func(w http.ResponseWriter, r *http.Request) {
+	desc, matched := matchingDescriptor(r, h.eps)
+
+	timeout := desc.timeout
+	if timeout <= 0 && !isWebSocketUpgrade(r) {
+		timeout = h.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = ctx.WithTimeout(timeout)
+		defer cancel()
+	}
+
+	exec := func(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) {
+		if h.validateSchemas && matched && !validateBody(ctx, w, r, desc) {
+			return
+		}
+		if _, ok := ctx.Deadline(); ok {
+			execWithDeadline(ctx, w, r, h.eps)
+		} else {
 			execIfMatch(ctx, w, r, h.eps)
-		})
-	} else {
-		execIfMatch(ctx, w, r, h.eps)
+		}
 	}
+
+	serve := func(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) {
+		if h.logging != nil {
+			logCall(ctx, w, r, *h.logging, func(w http.ResponseWriter, r *http.Request) {
+				exec(ctx, w, r)
+			})
+		} else {
+			exec(ctx, w, r)
+		}
+	}
+
+	if h.auditor != nil && matched && desc.audit.applies(r.Method) {
+		h.auditor.serve(ctx, w, r, target, desc, serve)
+		return
+	}
+
+	serve(ctx, w, r)
+}
+
+func matchingDescriptor(r *http.Request, eps endpoints) (desc descriptor, ok bool) {
+	for _, ep := range eps {
+		desc = ep.getDescriptor()
+		if _, ok = desc.match(r); ok {
+			return
+		}
+	}
+	return
 }
 
 func execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, eps endpoints) {
@@ -152,7 +450,28 @@ func execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, ep
 	}
 }
 
-func logCall(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, handle func(w http.ResponseWriter, r *http.Request)) {
+// CallsLogging configures the logging of API calls.
+type CallsLogging struct {
+	MaxBodyBytes  int      // bodies are logged up to that size; 0 for the default, -1 for none
+	RedactHeaders []string // logged as [redacted], in addition to the masked Authorization
+	RedactFields  []string // JSON body fields (dot notation) logged as [redacted], also of the claim additions
+	LogClaims     bool     // logs the claims of the caller, to replay the calls as them; off as they can hold personal data
+}
+
+const callsLoggingDefaultMaxBody = 16 << 10
+
+func (cl CallsLogging) maxBody() int {
+	switch {
+	case cl.MaxBodyBytes == 0:
+		return callsLoggingDefaultMaxBody
+	case cl.MaxBodyBytes < 0:
+		return 0
+	default:
+		return cl.MaxBodyBytes
+	}
+}
+
+func logCall(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, cl CallsLogging, handle func(w http.ResponseWriter, r *http.Request)) {
 
 	logger := ctx.Logger()
 	if logger == nil {
@@ -160,77 +479,48 @@ func logCall(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, handle
 		return
 	}
 
-	// Check if request body should be logged
+	maxBody := cl.maxBody()
+
 	reqContentType := r.Header.Get("Content-Type")
 	reqHasBody := (r.ContentLength != 0 && r.Body != nil) || r.Header.Get("Transfer-Encoding") == "chunked"
-	reqLogBody := reqHasBody && shouldLogBody(reqContentType)
-
-	var reqBody []byte
-	if reqLogBody {
-		reqBody, _ = io.ReadAll(r.Body)
-		r.Body = io.NopCloser(bytes.NewReader(reqBody))
-	}
 
-	// Copy headers and mask Authorization for logging
-	reqHeaders := make(http.Header)
-	for k, v := range r.Header {
-		reqHeaders[k] = v
-	}
-	if authHeader := reqHeaders.Get(convAuth.HttpHeaderAuthorization); authHeader != "" {
-		l := len(authHeader) - 10
-		if l < 0 {
-			l = len(authHeader)
-		}
-		reqHeaders.Set(convAuth.HttpHeaderAuthorization, "..."+authHeader[l:])
+	// bodies are captured while the handler reads and writes them, not buffered
+	var reqBody *capturingReader
+	if reqHasBody {
+		reqBody = &capturingReader{ReadCloser: r.Body, limit: captureLimit(reqContentType, maxBody)}
+		r.Body = reqBody
 	}
 
-	rec := httptest.NewRecorder()
-	handle(rec, r)
-	res := rec.Result()
+	reqHeaders := redactHeaders(r.Header, cl.RedactHeaders)
 
-	// Read response body (always needed to forward to client)
-	var resBody []byte
-	if res.Body != nil {
-		resBody, _ = io.ReadAll(res.Body)
-	}
+	cw := &callWriter{ResponseWriter: w, limit: -1, maxBody: maxBody}
 
-	// Check if response body should be logged
-	resContentType := res.Header.Get("Content-Type")
-	resHasBody := len(resBody) > 0
-	resLogBody := resHasBody && shouldLogBody(resContentType)
+	start := time.Now()
+	handle(cw, r)
+	duration := time.Since(start)
 
-	// Write response to actual writer
-	for k, v := range res.Header {
-		for _, vv := range v {
-			w.Header().Add(k, vv)
-		}
-	}
-	w.WriteHeader(res.StatusCode)
-	if len(resBody) > 0 {
-		_, err := w.Write(resBody)
-		if err != nil {
-			logger.Warn("error writing response body after logging", "error", err)
-			return
-		}
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
 	}
 
-	// Build log entry
-	var reqBodyLog any
-	if reqHasBody {
-		if reqLogBody {
-			reqBodyLog = processBody(reqContentType, reqBody)
-		} else {
-			reqBodyLog = "{{binary data}}"
-		}
+	var (
+		reqBodyLog any
+		reqSize    int64
+	)
+	if reqBody != nil {
+		reqSize = reqBody.size
+		reqBodyLog = bodyLog(reqContentType, reqBody.captured.Bytes(), reqBody.size, cl.RedactFields)
 	}
 
+	resContentType := cw.Header().Get("Content-Type")
 	var resBodyLog any
-	if resHasBody {
-		if resLogBody {
-			resBodyLog = processBody(resContentType, resBody)
-		} else {
-			resBodyLog = "{{binary data}}"
-		}
+	if cw.size > 0 {
+		resBodyLog = bodyLog(resContentType, cw.captured.Bytes(), cw.size, cl.RedactFields)
+	}
+
+	if cl.LogClaims {
+		logger = logger.With("claims", claimsLog(ctx.Claims(), cl.RedactFields)) // to replay the call with the same claims
 	}
 
 	logger.
@@ -239,15 +529,172 @@ func logCall(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, handle
 				"method", r.Method,
 				"url", r.URL.String(),
 				slog.Group("headers", headersToAttrs(reqHeaders)...),
+				"size", reqSize,
 				"body", reqBodyLog,
 			),
 			slog.Group("response",
-				"status", res.StatusCode,
-				slog.Group("headers", headersToAttrs(res.Header)...),
+				"status", status,
+				slog.Group("headers", headersToAttrs(redactHeaders(cw.Header(), cl.RedactHeaders))...),
+				"size", cw.size,
 				"body", resBodyLog,
 			),
+			"duration_ms", duration.Milliseconds(),
 		).
-		Info("API call")
+		Info(callsLogMessage)
+}
+
+// claimsLog returns the claims as logged, with the fields of the additions
+// redacted
+func claimsLog(claims convAuth.Claims, redactFields []string) convAuth.Claims {
+
+	if len(claims.Additions) == 0 || len(redactFields) == 0 {
+		return claims
+	}
+
+	data, err := json.Marshal(claims.Additions)
+	if err != nil {
+		claims.Additions = nil
+		return claims
+	}
+
+	var additions map[string]any
+	if json.Unmarshal(redactPayload(data, redactFields), &additions) != nil {
+		additions = nil
+	}
+	claims.Additions = additions
+
+	return claims
+}
+
+func captureLimit(contentType string, maxBody int) int {
+	if !shouldLogBody(contentType) {
+		return 0
+	}
+	return maxBody
+}
+
+// bodyLog returns what is logged of a body of size bytes, of which captured
+// is the beginning
+func bodyLog(contentType string, captured []byte, size int64, redactFields []string) any {
+
+	if size == 0 {
+		return nil
+	}
+
+	if !shouldLogBody(contentType) {
+		return "{{binary data}}"
+	}
+
+	if int64(len(captured)) < size {
+		if len(redactFields) > 0 && strings.HasPrefix(contentType, "application/json") {
+			return "{{truncated}}" // partial json cannot be redacted
+		}
+		return string(captured) + "{{truncated}}"
+	}
+
+	if strings.HasPrefix(contentType, "application/json") && len(redactFields) > 0 {
+		redacted := redactPayload(captured, redactFields)
+		if redacted == nil {
+			return "{{invalid json}}"
+		}
+		captured = redacted
+	}
+
+	return processBody(contentType, captured)
+}
+
+func redactHeaders(headers http.Header, redact []string) http.Header {
+
+	res := make(http.Header, len(headers))
+	for k, v := range headers {
+		res[k] = v
+	}
+
+	if authHeader := res.Get(convAuth.HttpHeaderAuthorization); authHeader != "" {
+		l := len(authHeader) - 10
+		if l < 0 {
+			l = len(authHeader)
+		}
+		res.Set(convAuth.HttpHeaderAuthorization, "..."+authHeader[l:])
+	}
+
+	for _, h := range redact {
+		if res.Get(h) != "" {
+			res.Set(h, "[redacted]")
+		}
+	}
+
+	return res
+}
+
+// capturingReader passes a body through, keeping its first limit bytes
+type capturingReader struct {
+	io.ReadCloser
+	limit    int
+	size     int64
+	captured bytes.Buffer
+}
+
+func (cr *capturingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.ReadCloser.Read(p)
+	cr.size += int64(n)
+	if room := cr.limit - cr.captured.Len(); room > 0 {
+		cr.captured.Write(p[:min(n, room)])
+	}
+	return
+}
+
+// callWriter passes a response through, recording its status and size and
+// keeping the first limit bytes of the body; flushing and hijacking
+// (e.g. WebSockets) keep working through it.
+type callWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int64
+	limit    int // -1 until the first write, when the content type is known
+	maxBody  int
+	captured bytes.Buffer
+}
+
+func (cw *callWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *callWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.limit < 0 {
+		cw.limit = captureLimit(cw.Header().Get("Content-Type"), cw.maxBody)
+	}
+	if room := cw.limit - cw.captured.Len(); room > 0 {
+		cw.captured.Write(b[:min(len(b), room)])
+	}
+	n, err := cw.ResponseWriter.Write(b)
+	cw.size += int64(n)
+	return n, err
+}
+
+func (cw *callWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *callWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	cw.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func (cw *callWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
 }
 
 func headersToAttrs(headers http.Header) []any {
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewSocket[inT, outT any](fn func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error) Socket[inT, outT] {
+	return Socket[inT, outT]{
+		fn: fn,
+	}
+}
+
+// WithPreCheck runs the check before the connection is upgraded, so a
+// failing check is still served as a regular http error.
+func (x Socket[inT, outT]) WithPreCheck(check Check) Socket[inT, outT] {
+	return Socket[inT, outT]{
+		checks: append(append([]Check{}, x.checks...), check),
+		fn:     x.fn,
+	}
+}
+
+type Socket[inT, outT any] struct {
+	descriptor descriptor
+	checks     []Check
+	fn         func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error
+}
+
+func (x *Socket[inT, outT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	_, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	serveSocket(ctx, w, r, x.checks, x.fn)
+
+	return true
+}
+
+func (x *Socket[inT, outT]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *Socket[inT, outT]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *Socket[inT, outT]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[inT](), reflect.TypeFor[outT]()
+}
+
+func (x *Socket[inT, outT]) setEndpoints(eps endpoints) {}
+
+func (x *Socket[inT, outT]) Connect(ctx convCtx.Context) (conn *SocketConn[inT, outT], err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	return connectSocket[inT, outT](ctx, &x.descriptor, nil)
+}
+
+// SocketConn is the client side of a Socket endpoint. Messages written to
+// Send are delivered to the server; closing Send closes the connection.
+// Receive is closed once the connection ends, after which Err reports why.
+type SocketConn[inT, outT any] struct {
+	Send    chan<- inT
+	Receive <-chan outT
+
+	ws     *wsConn
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Close ends the connection without waiting for pending messages.
+func (c *SocketConn[inT, outT]) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Err returns the reason the connection ended; nil on normal closure.
+func (c *SocketConn[inT, outT]) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *SocketConn[inT, outT]) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil && err != nil && !errors.Is(err, errWebSocketClosed) {
+		c.err = err
+	}
+}
+
+func connectSocket[inT, outT any](ctx convCtx.Context, desc *descriptor, vls values) (conn *SocketConn[inT, outT], err error) {
+
+	req, err := desc.newRequest(vls, nil)
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	dialer := &wsDialer{}
+
+	res, err := desc.sendVia(ctx, req, dialer)
+	if err != nil {
+		return
+	}
+
+	ws := dialer.ws
+	if ws == nil {
+		defer res.Body.Close()
+		err = parseRemoteError(ctx, req, res)
+		return
+	}
+
+	send := make(chan inT)
+	receive := make(chan outT)
+
+	c, cancel := context.WithCancel(ctx)
+
+	conn = &SocketConn[inT, outT]{
+		Send:    send,
+		Receive: receive,
+		ws:      ws,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	readerDone := make(chan struct{})
+
+	// reader: decodes server messages until the connection ends
+	go func() {
+		defer close(readerDone)
+		defer close(receive)
+		defer cancel()
+
+		for {
+			data, err := ws.readMessage()
+			if err != nil {
+				if c.Err() == nil {
+					conn.setErr(err)
+				}
+				return
+			}
+
+			var msg outT
+			err = json.Unmarshal(data, &msg)
+			if err != nil {
+				conn.setErr(err)
+				ws.writeClose(wsCloseInvalidPayload, "unable to decode message")
+				return
+			}
+
+			select {
+			case receive <- msg:
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+
+	// writer: encodes client messages until Send is closed or the connection ends
+	go func() {
+		defer close(conn.done)
+		defer ws.close()
+
+		for {
+			select {
+			case msg, ok := <-send:
+				if !ok {
+					ws.writeClose(wsCloseNormal, "")
+					waitSocketReader(readerDone)
+					return
+				}
+				data, err := json.Marshal(msg)
+				if err != nil {
+					conn.setErr(err)
+					ws.writeClose(wsCloseInternalError, "unable to encode message")
+					waitSocketReader(readerDone)
+					return
+				}
+				err = ws.writeFrame(wsOpText, data)
+				if err != nil {
+					conn.setErr(err)
+					return
+				}
+			case <-c.Done():
+				ws.writeClose(wsCloseGoingAway, "")
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+// waitSocketReader gives the peer a moment to answer the closing handshake
+func waitSocketReader(readerDone <-chan struct{}) {
+	select {
+	case <-readerDone:
+	case <-time.After(time.Second):
+	}
+}
+
+func serveSocket[inT, outT any](ctx convCtx.Context, w http.ResponseWriter, r *http.Request, checks []Check, fn func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error) {
+
+	for _, check := range checks {
+		err := check(ctx)
+		if err != nil {
+			var apiErr *Error
+			if errors.As(err, &apiErr) {
+				serveError(w, apiErr)
+			} else {
+				ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+			}
+			return
+		}
+	}
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		ServeError(ctx, w, http.StatusBadRequest, ErrorCodeBadRequest, "unable to upgrade to websocket", err)
+		return
+	}
+	defer ws.close()
+
+	c, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sctx := convCtx.Context{Context: c}
+
+	in := make(chan inT)
+	out := make(chan outT)
+	readerDone := make(chan struct{})
+
+	// reader: decodes client messages until the connection ends
+	go func() {
+		defer close(readerDone)
+		defer close(in)
+		defer cancel()
+
+		for {
+			data, err := ws.readMessage()
+			if err != nil {
+				switch {
+				case errors.Is(err, errWebSocketTooBig):
+					ws.writeClose(wsCloseTooBig, err.Error())
+				case errors.Is(err, errWebSocketProtocol):
+					ws.writeClose(wsCloseUnsupported, err.Error())
+				}
+				return
+			}
+
+			var msg inT
+			err = json.Unmarshal(data, &msg)
+			if err != nil {
+				ws.writeClose(wsCloseInvalidPayload, "unable to decode message")
+				return
+			}
+
+			select {
+			case in <- msg:
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(sctx, in, out)
+	}()
+
+	ticker := time.NewTicker(socketPingInterval)
+	defer ticker.Stop()
+
+	alive := c.Done() // set to nil once the connection is gone
+
+	for {
+		select {
+
+		case msg := <-out:
+			if alive == nil {
+				continue // connection gone; drain until the handler returns
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				ctx.Logger().Error("unable to encode websocket message", "error", err)
+				ws.writeClose(wsCloseInternalError, "unable to encode message")
+				cancel()
+				continue
+			}
+			if ws.writeFrame(wsOpText, data) != nil {
+				cancel()
+			}
+
+		case <-ticker.C:
+			if alive != nil && ws.writeFrame(wsOpPing, nil) != nil {
+				cancel()
+			}
+
+		case <-alive:
+			alive = nil
+
+		case err := <-done:
+			if err != nil {
+				msg := err.Error()
+				var apiErr *Error
+				if errors.As(err, &apiErr) {
+					msg = apiErr.Message
+				}
+				ws.writeClose(wsCloseInternalError, msg)
+			} else {
+				ws.writeClose(wsCloseNormal, "")
+			}
+			waitSocketReader(readerDone)
+			return
+		}
+	}
+}
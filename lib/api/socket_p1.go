@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewSocketP1[inT, outT, p1T any](fn func(ctx convCtx.Context, p1 p1T, in <-chan inT, out chan<- outT) error) SocketP1[inT, outT, p1T] {
+	return SocketP1[inT, outT, p1T]{
+		fn: fn,
+	}
+}
+
+func (x SocketP1[inT, outT, p1T]) WithPreCheck(check Check) SocketP1[inT, outT, p1T] {
+	return SocketP1[inT, outT, p1T]{
+		checks: append(append([]Check{}, x.checks...), check),
+		fn:     x.fn,
+	}
+}
+
+type SocketP1[inT, outT, p1T any] struct {
+	descriptor descriptor
+	checks     []Check
+	fn         func(ctx convCtx.Context, p1 p1T, in <-chan inT, out chan<- outT) error
+}
+
+func (x *SocketP1[inT, outT, p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
+	serveSocket(ctx, w, r, x.checks, func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error {
+		return x.fn(
+			ctx,
+			p1,
+			in,
+			out,
+		)
+	})
+
+	return true
+}
+
+func (x *SocketP1[inT, outT, p1T]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *SocketP1[inT, outT, p1T]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *SocketP1[inT, outT, p1T]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[inT](), reflect.TypeFor[outT]()
+}
+
+func (x *SocketP1[inT, outT, p1T]) setEndpoints(eps endpoints) {}
+
+func (x *SocketP1[inT, outT, p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
+func (x *SocketP1[inT, outT, p1T]) Connect(ctx convCtx.Context, p1 p1T) (conn *SocketConn[inT, outT], err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	values, err := formatParams(p1)
+	if err != nil {
+		return
+	}
+
+	return connectSocket[inT, outT](ctx, &x.descriptor, values)
+}
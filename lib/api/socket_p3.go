@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewSocketP3[inT, outT, p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in <-chan inT, out chan<- outT) error) SocketP3[inT, outT, p1T, p2T, p3T] {
+	return SocketP3[inT, outT, p1T, p2T, p3T]{
+		fn: fn,
+	}
+}
+
+func (x SocketP3[inT, outT, p1T, p2T, p3T]) WithPreCheck(check Check) SocketP3[inT, outT, p1T, p2T, p3T] {
+	return SocketP3[inT, outT, p1T, p2T, p3T]{
+		checks: append(append([]Check{}, x.checks...), check),
+		fn:     x.fn,
+	}
+}
+
+type SocketP3[inT, outT, p1T, p2T, p3T any] struct {
+	descriptor descriptor
+	checks     []Check
+	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, in <-chan inT, out chan<- outT) error
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
+	serveSocket(ctx, w, r, x.checks, func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error {
+		return x.fn(
+			ctx,
+			p1,
+			p2,
+			p3,
+			in,
+			out,
+		)
+	})
+
+	return true
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[inT](), reflect.TypeFor[outT]()
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
+func (x *SocketP3[inT, outT, p1T, p2T, p3T]) Connect(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (conn *SocketConn[inT, outT], err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
+	}
+
+	return connectSocket[inT, outT](ctx, &x.descriptor, values)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewSocketP4[inT, outT, p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in <-chan inT, out chan<- outT) error) SocketP4[inT, outT, p1T, p2T, p3T, p4T] {
+	return SocketP4[inT, outT, p1T, p2T, p3T, p4T]{
+		fn: fn,
+	}
+}
+
+func (x SocketP4[inT, outT, p1T, p2T, p3T, p4T]) WithPreCheck(check Check) SocketP4[inT, outT, p1T, p2T, p3T, p4T] {
+	return SocketP4[inT, outT, p1T, p2T, p3T, p4T]{
+		checks: append(append([]Check{}, x.checks...), check),
+		fn:     x.fn,
+	}
+}
+
+type SocketP4[inT, outT, p1T, p2T, p3T, p4T any] struct {
+	descriptor descriptor
+	checks     []Check
+	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, in <-chan inT, out chan<- outT) error
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4) {
+		return true
+	}
+
+	serveSocket(ctx, w, r, x.checks, func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error {
+		return x.fn(
+			ctx,
+			p1,
+			p2,
+			p3,
+			p4,
+			in,
+			out,
+		)
+	})
+
+	return true
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[inT](), reflect.TypeFor[outT]()
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) setEndpoints(eps endpoints) {}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()}
+}
+
+func (x *SocketP4[inT, outT, p1T, p2T, p3T, p4T]) Connect(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (conn *SocketConn[inT, outT], err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	values, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
+	}
+
+	return connectSocket[inT, outT](ctx, &x.descriptor, values)
+}
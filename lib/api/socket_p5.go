@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewSocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in <-chan inT, out chan<- outT) error) SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
+	return SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]{
+		fn: fn,
+	}
+}
+
+func (x SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) WithPreCheck(check Check) SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T] {
+	return SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]{
+		checks: append(append([]Check{}, x.checks...), check),
+		fn:     x.fn,
+	}
+}
+
+type SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T any] struct {
+	descriptor descriptor
+	checks     []Check
+	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T, in <-chan inT, out chan<- outT) error
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
+	serveSocket(ctx, w, r, x.checks, func(ctx convCtx.Context, in <-chan inT, out chan<- outT) error {
+		return x.fn(
+			ctx,
+			p1,
+			p2,
+			p3,
+			p4,
+			p5,
+			in,
+			out,
+		)
+	})
+
+	return true
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out reflect.Type) {
+	return reflect.TypeFor[inT](), reflect.TypeFor[outT]()
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
+func (x *SocketP5[inT, outT, p1T, p2T, p3T, p4T, p5T]) Connect(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (conn *SocketConn[inT, outT], err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
+	}
+
+	return connectSocket[inT, outT](ctx, &x.descriptor, values)
+}
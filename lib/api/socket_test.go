@@ -0,0 +1,193 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type socketMessage struct {
+	Text string `json:"text"`
+}
+
+type socketAPI struct {
+	Echo convAPI.SocketP1[socketMessage, socketMessage, string] `api:"GET /test/v1/rooms/{room}/echo"`
+	Fail convAPI.Socket[socketMessage, socketMessage]           `api:"GET /test/v1/fail"`
+}
+
+func Test_socket(t *testing.T) {
+
+	const (
+		roleChat       convAuth.Role       = "chat"
+		permissionChat convAuth.Permission = "chat"
+	)
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleChat: convAuth.Permissions{permissionChat},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionChat: convAuth.Actions{
+				"GET /test/v1/rooms/{any}/echo",
+				"GET /test/v1/fail",
+			},
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_socket"})
+
+	port := portForAPITest(t)
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", port, policy, &socketAPI{
+		Echo: convAPI.NewSocketP1(func(ctx convCtx.Context, room string, in <-chan socketMessage, out chan<- socketMessage) error {
+			for msg := range in {
+				out <- socketMessage{Text: room + ":" + string(ctx.User()) + ":" + strings.ToUpper(msg.Text)}
+			}
+			return nil
+		}),
+		Fail: convAPI.NewSocket(func(ctx convCtx.Context, in <-chan socketMessage, out chan<- socketMessage) error {
+			<-in
+			return errors.New("boom")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	client := convAPI.NewClient[socketAPI]("localhost", port)
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "alice", Roles: convAuth.Roles{roleChat}})
+
+	t.Run("echo", func(t *testing.T) {
+		conn, err := client.Echo.Connect(callerCtx, "lobby")
+		if err != nil {
+			t.Fatalf("Connect() = %v; want nil", err)
+		}
+
+		for _, text := range []string{"hello", "world"} {
+			conn.Send <- socketMessage{Text: text}
+
+			select {
+			case msg := <-conn.Receive:
+				want := "lobby:alice:" + strings.ToUpper(text)
+				if msg.Text != want {
+					t.Fatalf("Receive = %q; want %q", msg.Text, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no message received")
+			}
+		}
+
+		close(conn.Send)
+
+		if _, ok := <-conn.Receive; ok {
+			t.Fatal("Receive is not closed after Send was closed")
+		}
+		if conn.Err() != nil {
+			t.Fatalf("Err() = %v; want nil", conn.Err())
+		}
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		ctx := convCtx.New(convAuth.Claims{User: "mallory"})
+
+		_, err := client.Echo.Connect(ctx, "lobby")
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeForbidden) {
+			t.Fatalf("Connect() = %v; want forbidden", err)
+		}
+	})
+
+	t.Run("handler_error", func(t *testing.T) {
+		conn, err := client.Fail.Connect(callerCtx)
+		if err != nil {
+			t.Fatalf("Connect() = %v; want nil", err)
+		}
+		defer conn.Close()
+
+		conn.Send <- socketMessage{Text: "go"}
+
+		if _, ok := <-conn.Receive; ok {
+			t.Fatal("Receive is not closed after handler error")
+		}
+
+		var closeErr *convAPI.WebSocketCloseError
+		if !errors.As(conn.Err(), &closeErr) {
+			t.Fatalf("Err() = %v; want *WebSocketCloseError", conn.Err())
+		}
+		if closeErr.Reason != "boom" {
+			t.Fatalf("close reason = %q; want %q", closeErr.Reason, "boom")
+		}
+	})
+	t.Run("resolver", func(t *testing.T) {
+		resolved := convAPI.NewClient[socketAPI]("localhost", 0,
+			convAPI.WithResolver(convAPI.NewStaticResolver(convAPI.Instance{Host: "127.0.0.1", Port: port})),
+		)
+
+		conn, err := resolved.Echo.Connect(callerCtx, "lobby")
+		if err != nil {
+			t.Fatalf("Connect() = %v; want nil", err)
+		}
+		defer conn.Close()
+
+		conn.Send <- socketMessage{Text: "hi"}
+
+		select {
+		case msg := <-conn.Receive:
+			if msg.Text != "lobby:alice:HI" {
+				t.Fatalf("Receive = %q; want %q", msg.Text, "lobby:alice:HI")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no message received")
+		}
+	})
+
+	t.Run("unresponsive_peer", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() = %v; want nil", err)
+		}
+		defer ln.Close()
+
+		go func() { // accept and never answer
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+
+		silent := convAPI.NewClient[socketAPI]("localhost", ln.Addr().(*net.TCPAddr).Port)
+
+		ctx, cancel := callerCtx.WithTimeout(100 * time.Millisecond)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := silent.Echo.Connect(ctx, "lobby")
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Connect() = %v; want context.DeadlineExceeded", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Connect() does not return once the context is done")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// execWithDeadline serves a timeout error when the deadline of ctx passes
+// before the handler has started to respond, even if the handler does not
+// return; what the handler writes afterwards is dropped.
+func execWithDeadline(ctx convCtx.Context, w http.ResponseWriter, r *http.Request, eps endpoints) {
+
+	dw := &deadlineWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		header:         http.Header{},
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		dw.mu.Lock()
+		defer dw.mu.Unlock()
+		if !dw.done && dw.timeoutLocked() {
+			if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	})
+	defer stop()
+
+	execIfMatch(ctx, dw, r, eps)
+
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.timeoutLocked() // the handler returned after the deadline without responding
+	dw.done = true     // the response writer must not be used once the handler returned
+}
+
+// deadlineWriter holds the headers of the handler until it starts to respond,
+// so that a timeout error can be served instead up to that point.
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx      convCtx.Context
+	header   http.Header
+	mu       sync.Mutex
+	started  bool // the handler started to respond or hijacked the connection
+	timedOut bool
+	done     bool
+}
+
+func (dw *deadlineWriter) timeoutLocked() bool {
+	if dw.started || dw.timedOut || !errors.Is(dw.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	dw.timedOut = true
+	serveError(dw.ResponseWriter, newError(dw.ctx, http.StatusGatewayTimeout, ErrorCodeTimeout, "deadline of the call exceeded", nil))
+	return true
+}
+
+func (dw *deadlineWriter) startLocked(status int) bool {
+	if dw.timedOut || dw.timeoutLocked() {
+		return false
+	}
+	if !dw.started {
+		dw.started = true
+		for k, v := range dw.header {
+			dw.ResponseWriter.Header()[k] = v
+		}
+		dw.ResponseWriter.WriteHeader(status)
+	}
+	return true
+}
+
+func (dw *deadlineWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *deadlineWriter) WriteHeader(status int) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.startLocked(status)
+}
+
+func (dw *deadlineWriter) Write(b []byte) (int, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if !dw.startLocked(http.StatusOK) {
+		return 0, http.ErrHandlerTimeout
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+func (dw *deadlineWriter) Flush() {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if !dw.startLocked(http.StatusOK) {
+		return
+	}
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (dw *deadlineWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.timedOut || dw.timeoutLocked() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	hj, ok := dw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		dw.started = true
+	}
+	return conn, rw, err
+}
+
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// timeBudget returns the Time-Budget header value for a call made with ctx;
+// ok is false when ctx has no deadline.
+func timeBudget(ctx convCtx.Context) (budget string, ok bool) {
+	left, ok := ctx.TimeBudget()
+	if !ok {
+		return
+	}
+	budget = strconv.FormatInt(max(left.Milliseconds(), 0), 10)
+	return
+}
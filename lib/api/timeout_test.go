@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+type timeoutAPI struct {
+	Wait   convAPI.OutP1[string, string] `api:"GET /test/v1/wait/{ms}" timeout:"100ms"`
+	Budget convAPI.Out[int64]            `api:"GET /test/v1/budget"`
+	Chain  convAPI.Out[int64]            `api:"GET /test/v1/chain" timeout:"200ms"`
+}
+
+func Test_timeout(t *testing.T) {
+
+	policy := convAuth.Policy{
+		Public: convAuth.Actions{
+			"GET /test/v1/wait/{any}",
+			"GET /test/v1/budget",
+			"GET /test/v1/chain",
+		},
+	}
+
+	agentCtx := convCtx.New(convAuth.Claims{User: "Test_timeout"})
+
+	client := convAPI.NewClient[timeoutAPI]("localhost", portForAPITest(t))
+
+	srv, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &timeoutAPI{
+		Wait: convAPI.NewOutP1(func(ctx convCtx.Context, ms string) (string, error) {
+			n, _ := strconv.Atoi(ms)
+			time.Sleep(time.Duration(n) * time.Millisecond) // not watching ctx on purpose
+			return "done", nil
+		}),
+		Budget: convAPI.NewOut(func(ctx convCtx.Context) (int64, error) {
+			budget, ok := ctx.TimeBudget()
+			if !ok {
+				return -1, nil
+			}
+			return budget.Milliseconds(), nil
+		}),
+		Chain: convAPI.NewOut(func(ctx convCtx.Context) (int64, error) {
+			return client.Budget.Call(ctx)
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() = %v; want nil", err)
+	}
+
+	srv.SetDefaultTimeout(time.Second)
+
+	go srv.ListenAndServe()
+	defer srv.Shutdown(agentCtx)
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	callerCtx := convCtx.New(convAuth.Claims{User: "test-v1"})
+
+	t.Run("within_deadline", func(t *testing.T) {
+		res, err := client.Wait.Call(callerCtx, "0")
+		if err != nil || res != "done" {
+			t.Fatalf("Wait(0) = %q, %v; want done", res, err)
+		}
+	})
+
+	t.Run("handler_ignores_deadline", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.Wait.Call(callerCtx, "1000")
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeTimeout) {
+			t.Fatalf("Wait(1000) = %v; want timeout error", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("Wait(1000) answered after %v; want at the 100ms deadline", elapsed)
+		}
+	})
+
+	t.Run("server_default", func(t *testing.T) {
+		budget, err := client.Budget.Call(callerCtx)
+		if err != nil {
+			t.Fatalf("Budget() = %v; want nil", err)
+		}
+		if budget <= 0 || budget > 1000 {
+			t.Fatalf("Budget() = %dms; want within the 1s default", budget)
+		}
+	})
+
+	t.Run("propagated_to_called_agent", func(t *testing.T) {
+		budget, err := client.Chain.Call(callerCtx)
+		if err != nil {
+			t.Fatalf("Chain() = %v; want nil", err)
+		}
+		if budget <= 0 || budget > 200 {
+			t.Fatalf("Chain() = %dms; want within the 200ms of the calling endpoint", budget)
+		}
+	})
+
+	t.Run("caller_budget", func(t *testing.T) {
+		ctx, cancel := callerCtx.WithTimeout(150 * time.Millisecond)
+		defer cancel()
+
+		budget, err := client.Budget.Call(ctx)
+		if err != nil {
+			t.Fatalf("Budget() = %v; want nil", err)
+		}
+		if budget <= 0 || budget > 150 {
+			t.Fatalf("Budget() = %dms; want within the 150ms of the caller", budget)
+		}
+	})
+
+	t.Run("no_time_left", func(t *testing.T) {
+		ctx, cancel := callerCtx.WithDeadline(time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := client.Budget.Call(ctx)
+		if !convAPI.ErrorHasCode(err, convAPI.ErrorCodeTimeout) {
+			t.Fatalf("Budget() with an expired deadline = %v; want timeout error", err)
+		}
+	})
+
+	t.Run("invalid_tag", func(t *testing.T) {
+		_, err := convAPI.NewServer(agentCtx, "localhost", portForAPITest(t), policy, &struct {
+			Wait convAPI.OutP1[string, string] `api:"GET /test/v1/wait/{ms}" timeout:"5 s"`
+		}{})
+		if err == nil {
+			t.Fatal("NewServer() with an invalid timeout tag = nil; want error")
+		}
+	})
+}
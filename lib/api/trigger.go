@@ -96,7 +96,7 @@ func (x *Trigger) Call(ctx convCtx.Context) (err error) {
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
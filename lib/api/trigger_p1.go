@@ -8,7 +8,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewTriggerP1[p1T ~string](fn func(ctx convCtx.Context, p1 p1T) error) TriggerP1[p1T] {
+func NewTriggerP1[p1T any](fn func(ctx convCtx.Context, p1 p1T) error) TriggerP1[p1T] {
 	return TriggerP1[p1T]{
 		fn: fn,
 	}
@@ -38,7 +38,7 @@ func (x TriggerP1[p1T]) WithPostCheck(check Check) TriggerP1[p1T] {
 	}
 }
 
-type TriggerP1[p1T ~string] struct {
+type TriggerP1[p1T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T) error
 }
@@ -50,9 +50,14 @@ func (x *TriggerP1[p1T]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter,
 		return false
 	}
 
+	var p1 p1T
+	if !x.descriptor.parseParams(ctx, w, values, &p1) {
+		return true
+	}
+
 	err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
+		p1,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -82,6 +87,10 @@ func (x *TriggerP1[p1T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *TriggerP1[p1T]) setEndpoints(eps endpoints) {}
 
+func (x *TriggerP1[p1T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T]()}
+}
+
 func (x *TriggerP1[p1T]) Call(ctx convCtx.Context, p1 p1T) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -89,8 +98,9 @@ func (x *TriggerP1[p1T]) Call(ctx convCtx.Context, p1 p1T) (err error) {
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
+	values, err := formatParams(p1)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -103,7 +113,7 @@ func (x *TriggerP1[p1T]) Call(ctx convCtx.Context, p1 p1T) (err error) {
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -8,7 +8,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewTriggerP2[p1T, p2T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T) error) TriggerP2[p1T, p2T] {
+func NewTriggerP2[p1T, p2T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T) error) TriggerP2[p1T, p2T] {
 	return TriggerP2[p1T, p2T]{
 		fn: fn,
 	}
@@ -38,7 +38,7 @@ func (x TriggerP2[p1T, p2T]) WithPostCheck(check Check) TriggerP2[p1T, p2T] {
 	}
 }
 
-type TriggerP2[p1T, p2T ~string] struct {
+type TriggerP2[p1T, p2T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T) error
 }
@@ -50,10 +50,18 @@ func (x *TriggerP2[p1T, p2T]) execIfMatch(ctx convCtx.Context, w http.ResponseWr
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2) {
+		return true
+	}
+
 	err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
+		p1,
+		p2,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -83,6 +91,10 @@ func (x *TriggerP2[p1T, p2T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *TriggerP2[p1T, p2T]) setEndpoints(eps endpoints) {}
 
+func (x *TriggerP2[p1T, p2T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T]()}
+}
+
 func (x *TriggerP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -90,9 +102,9 @@ func (x *TriggerP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (err err
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
+	values, err := formatParams(p1, p2)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -105,7 +117,7 @@ func (x *TriggerP2[p1T, p2T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T) (err err
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -8,7 +8,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewTriggerP3[p1T, p2T, p3T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) error) TriggerP3[p1T, p2T, p3T] {
+func NewTriggerP3[p1T, p2T, p3T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) error) TriggerP3[p1T, p2T, p3T] {
 	return TriggerP3[p1T, p2T, p3T]{
 		fn: fn,
 	}
@@ -38,7 +38,7 @@ func (x TriggerP3[p1T, p2T, p3T]) WithPostCheck(check Check) TriggerP3[p1T, p2T,
 	}
 }
 
-type TriggerP3[p1T, p2T, p3T ~string] struct {
+type TriggerP3[p1T, p2T, p3T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) error
 }
@@ -50,11 +50,20 @@ func (x *TriggerP3[p1T, p2T, p3T]) execIfMatch(ctx convCtx.Context, w http.Respo
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3) {
+		return true
+	}
+
 	err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
+		p1,
+		p2,
+		p3,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -84,6 +93,10 @@ func (x *TriggerP3[p1T, p2T, p3T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *TriggerP3[p1T, p2T, p3T]) setEndpoints(eps endpoints) {}
 
+func (x *TriggerP3[p1T, p2T, p3T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T]()}
+}
+
 func (x *TriggerP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -91,10 +104,9 @@ func (x *TriggerP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
+	values, err := formatParams(p1, p2, p3)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -107,7 +119,7 @@ func (x *TriggerP3[p1T, p2T, p3T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -8,7 +8,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewTriggerP4[p1T, p2T, p3T, p4T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) error) TriggerP4[p1T, p2T, p3T, p4T] {
+func NewTriggerP4[p1T, p2T, p3T, p4T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) error) TriggerP4[p1T, p2T, p3T, p4T] {
 	return TriggerP4[p1T, p2T, p3T, p4T]{
 		fn: fn,
 	}
@@ -38,7 +38,7 @@ func (x TriggerP4[p1T, p2T, p3T, p4T]) WithPostCheck(check Check) TriggerP4[p1T,
 	}
 }
 
-type TriggerP4[p1T, p2T, p3T, p4T ~string] struct {
+type TriggerP4[p1T, p2T, p3T, p4T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) error
 }
@@ -50,12 +50,22 @@ func (x *TriggerP4[p1T, p2T, p3T, p4T]) execIfMatch(ctx convCtx.Context, w http.
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4) {
+		return true
+	}
+
 	err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
+		p1,
+		p2,
+		p3,
+		p4,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -85,6 +95,10 @@ func (x *TriggerP4[p1T, p2T, p3T, p4T]) getInOutTypes() (in, out reflect.Type) {
 
 func (x *TriggerP4[p1T, p2T, p3T, p4T]) setEndpoints(eps endpoints) {}
 
+func (x *TriggerP4[p1T, p2T, p3T, p4T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T]()}
+}
+
 func (x *TriggerP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -92,11 +106,9 @@ func (x *TriggerP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
+	values, err := formatParams(p1, p2, p3, p4)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -109,7 +121,7 @@ func (x *TriggerP4[p1T, p2T, p3T, p4T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
@@ -8,7 +8,7 @@ import (
 	convCtx "github.com/sofmon/convention/lib/ctx"
 )
 
-func NewTriggerP5[p1T, p2T, p3T, p4T, p5T ~string](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) error) TriggerP5[p1T, p2T, p3T, p4T, p5T] {
+func NewTriggerP5[p1T, p2T, p3T, p4T, p5T any](fn func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) error) TriggerP5[p1T, p2T, p3T, p4T, p5T] {
 	return TriggerP5[p1T, p2T, p3T, p4T, p5T]{
 		fn: fn,
 	}
@@ -38,7 +38,7 @@ func (x TriggerP5[p1T, p2T, p3T, p4T, p5T]) WithPostCheck(check Check) TriggerP5
 	}
 }
 
-type TriggerP5[p1T, p2T, p3T, p4T, p5T ~string] struct {
+type TriggerP5[p1T, p2T, p3T, p4T, p5T any] struct {
 	descriptor descriptor
 	fn         func(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) error
 }
@@ -50,13 +50,24 @@ func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) execIfMatch(ctx convCtx.Context, w
 		return false
 	}
 
+	var (
+		p1 p1T
+		p2 p2T
+		p3 p3T
+		p4 p4T
+		p5 p5T
+	)
+	if !x.descriptor.parseParams(ctx, w, values, &p1, &p2, &p3, &p4, &p5) {
+		return true
+	}
+
 	err := x.fn(
 		ctx,
-		p1T(values.GetByIndex(0)),
-		p2T(values.GetByIndex(1)),
-		p3T(values.GetByIndex(2)),
-		p4T(values.GetByIndex(3)),
-		p5T(values.GetByIndex(4)),
+		p1,
+		p2,
+		p3,
+		p4,
+		p5,
 	)
 	if err != nil {
 		var apiErr *Error
@@ -86,6 +97,10 @@ func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) getInOutTypes() (in, out reflect.Ty
 
 func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) setEndpoints(eps endpoints) {}
 
+func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) getParamTypes() []reflect.Type {
+	return []reflect.Type{reflect.TypeFor[p1T](), reflect.TypeFor[p2T](), reflect.TypeFor[p3T](), reflect.TypeFor[p4T](), reflect.TypeFor[p5T]()}
+}
+
 func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p2 p2T, p3 p3T, p4 p4T, p5 p5T) (err error) {
 
 	if !x.descriptor.isSet() {
@@ -93,12 +108,9 @@ func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p
 		return
 	}
 
-	values := values{
-		{Name: "", Value: string(p1)},
-		{Name: "", Value: string(p2)},
-		{Name: "", Value: string(p3)},
-		{Name: "", Value: string(p4)},
-		{Name: "", Value: string(p5)},
+	values, err := formatParams(p1, p2, p3, p4, p5)
+	if err != nil {
+		return
 	}
 
 	req, err := x.descriptor.newRequest(values, nil)
@@ -111,7 +123,7 @@ func (x *TriggerP5[p1T, p2T, p3T, p4T, p5T]) Call(ctx convCtx.Context, p1 p1T, p
 		return
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := x.descriptor.send(ctx, req)
 	if err != nil {
 		return
 	}
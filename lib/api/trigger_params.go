@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+func NewTriggerParams[pT any](fn func(ctx convCtx.Context, p pT) error) TriggerParams[pT] {
+	return TriggerParams[pT]{
+		fn: fn,
+	}
+}
+
+func (x TriggerParams[pT]) WithPreCheck(check Check) TriggerParams[pT] {
+	return TriggerParams[pT]{
+		fn: func(ctx convCtx.Context, p pT) error {
+			err := check(ctx)
+			if err != nil {
+				return err
+			}
+			return x.fn(ctx, p)
+		},
+	}
+}
+
+func (x TriggerParams[pT]) WithPostCheck(check Check) TriggerParams[pT] {
+	return TriggerParams[pT]{
+		fn: func(ctx convCtx.Context, p pT) error {
+			err := x.fn(ctx, p)
+			if err != nil {
+				return err
+			}
+			return check(ctx)
+		},
+	}
+}
+
+type TriggerParams[pT any] struct {
+	descriptor descriptor
+	fn         func(ctx convCtx.Context, p pT) error
+}
+
+func (x *TriggerParams[pT]) execIfMatch(ctx convCtx.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	values, match := x.descriptor.match(r)
+	if !match {
+		return false
+	}
+
+	var p pT
+	if !x.descriptor.bindParams(ctx, w, r, values, &p) {
+		return true
+	}
+
+	err := x.fn(
+		ctx,
+		p,
+	)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			serveError(w, apiErr)
+		} else {
+			ServeError(ctx, w, http.StatusInternalServerError, ErrorCodeInternalError, "unexpected error", err)
+		}
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return true
+}
+
+func (x *TriggerParams[pT]) setDescriptor(desc descriptor) {
+	x.descriptor = desc
+}
+
+func (x *TriggerParams[pT]) getDescriptor() descriptor {
+	return x.descriptor
+}
+
+func (x *TriggerParams[pT]) getInOutTypes() (in, out reflect.Type) {
+	return nil, nil
+}
+
+func (x *TriggerParams[pT]) setEndpoints(eps endpoints) {}
+
+func (x *TriggerParams[pT]) getParamsType() reflect.Type {
+	return reflect.TypeFor[pT]()
+}
+
+func (x *TriggerParams[pT]) Call(ctx convCtx.Context, p pT) (err error) {
+
+	if !x.descriptor.isSet() {
+		err = errors.New("api not initialized as client; user convAPI.NewClient to create client form api definition")
+		return
+	}
+
+	req, err := x.descriptor.newBoundRequest(p, nil)
+	if err != nil {
+		return
+	}
+
+	err = setContextHttpHeaders(ctx, req)
+	if err != nil {
+		return
+	}
+
+	res, err := x.descriptor.send(ctx, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode == http.StatusOK {
+		return
+	}
+
+	err = parseRemoteError(ctx, req, res)
+
+	return
+}
@@ -0,0 +1,431 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal RFC 6455 implementation on top of the standard library.
+// Only what the Socket endpoints need: text messages, fragmentation,
+// ping/pong and the closing handshake.
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+
+	wsCloseNormal         = 1000
+	wsCloseGoingAway      = 1001
+	wsCloseUnsupported    = 1003
+	wsCloseInvalidPayload = 1007
+	wsCloseTooBig         = 1009
+	wsCloseInternalError  = 1011
+)
+
+// Keepalive, size limits and the handshake timeout; package vars so tests can shorten them.
+var (
+	socketPingInterval     = 30 * time.Second
+	socketMaxMessageSize   = 16 << 20
+	socketHandshakeTimeout = 10 * time.Second
+)
+
+var (
+	errWebSocketClosed   = errors.New("websocket connection closed")
+	errWebSocketTooBig   = errors.New("websocket message exceeds the size limit")
+	errWebSocketProtocol = errors.New("websocket protocol error")
+)
+
+// WebSocketCloseError is reported when the peer closes the connection
+// with a status other than normal closure.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d: %s", e.Code, e.Reason)
+}
+
+type wsConn struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	client  bool // clients mask their frames
+	writeMu sync.Mutex
+	closed  bool // close frame sent
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// upgradeWebSocket completes the server side of the opening handshake
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (ws *wsConn, err error) {
+
+	if r.Method != http.MethodGet || !isWebSocketUpgrade(r) {
+		err = errors.New("websocket upgrade required")
+		return
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		err = errors.New("unsupported websocket version; expecting 13")
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		err = errors.New("missing 'Sec-WebSocket-Key' header")
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		err = errors.New("response writer does not support hijacking")
+		return
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+
+	// make sure no handshake deadline set by the http server is left behind
+	conn.SetDeadline(time.Time{})
+
+	_, err = brw.WriteString(
+		"HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n",
+	)
+	if err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	ws = &wsConn{conn: conn, br: brw.Reader}
+	return
+}
+
+// wsDialer performs the client side of the opening handshake for an
+// already prepared https request, as the doer of the balancer and guard of
+// the target. The connection of a 101 response is kept in ws; any other
+// response is returned to be read as an error.
+type wsDialer struct {
+	ws *wsConn
+}
+
+func (d *wsDialer) Do(req *http.Request) (res *http.Response, err error) {
+
+	ctx := req.Context()
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr += ":443"
+	}
+
+	host := req.Host // set by the balancer to the target, when resolved
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: socketHandshakeTimeout},
+		Config:    &tls.Config{ServerName: (&url.URL{Host: host}).Hostname()},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return
+	}
+
+	deadline := time.Now().Add(socketHandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	// unblock the handshake once the caller gives up
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	defer func() {
+		if err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+		}
+	}()
+
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	err = req.Write(conn)
+	if err != nil {
+		return
+	}
+
+	br := bufio.NewReader(conn)
+
+	res, err = http.ReadResponse(br, req)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		// keep the connection open until the caller reads the error body
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{res.Body, conn}
+		return
+	}
+
+	if res.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		res = nil
+		err = errors.New("invalid 'Sec-WebSocket-Accept' header in websocket handshake")
+		return
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	d.ws = &wsConn{conn: conn, br: br, client: true}
+	return
+}
+
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) (err error) {
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closed {
+		return errWebSocketClosed
+	}
+
+	if opcode == wsOpClose {
+		ws.closed = true
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode // FIN + opcode
+
+	var maskBit byte
+	if ws.client {
+		maskBit = 0x80
+	}
+
+	switch l := len(payload); {
+	case l <= 125:
+		header[1] = maskBit | byte(l)
+	case l <= 0xFFFF:
+		header[1] = maskBit | 126
+		header = binary.BigEndian.AppendUint16(header, uint16(l))
+	default:
+		header[1] = maskBit | 127
+		header = binary.BigEndian.AppendUint64(header, uint64(l))
+	}
+
+	if ws.client {
+		mask := make([]byte, 4)
+		if _, err = rand.Read(mask); err != nil {
+			return
+		}
+		header = append(header, mask...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(socketPingInterval))
+
+	_, err = ws.conn.Write(append(header, payload...))
+	return
+}
+
+func (ws *wsConn) writeClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123] // control frames are limited to 125 bytes
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return ws.writeFrame(wsOpClose, append(payload, reason...))
+}
+
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+
+	var head [2]byte
+	if _, err = io.ReadFull(ws.br, head[:]); err != nil {
+		return
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	if masked == ws.client {
+		// clients must mask, servers must not
+		err = errWebSocketProtocol
+		return
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > uint64(socketMaxMessageSize) {
+		err = errWebSocketTooBig
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return
+}
+
+// readMessage returns the next data message. Control frames are handled
+// in place; every frame read extends the keepalive read deadline.
+func (ws *wsConn) readMessage() (data []byte, err error) {
+
+	var opcode byte
+
+	for {
+		ws.conn.SetReadDeadline(time.Now().Add(2 * socketPingInterval))
+
+		var (
+			fin     bool
+			op      byte
+			payload []byte
+		)
+		fin, op, payload, err = ws.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case wsOpPing:
+			ws.writeFrame(wsOpPong, payload)
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := wsCloseNormal
+			reason := ""
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				reason = string(payload[2:])
+			}
+			ws.writeClose(code, "") // echo the close, ignored when already sent
+			if code == wsCloseNormal || code == wsCloseGoingAway {
+				err = errWebSocketClosed
+			} else {
+				err = &WebSocketCloseError{Code: code, Reason: reason}
+			}
+			return
+		case wsOpText, wsOpBinary:
+			if opcode != 0 {
+				err = errWebSocketProtocol // new message before the previous one finished
+				return
+			}
+			opcode = op
+		case wsOpContinuation:
+			if opcode == 0 {
+				err = errWebSocketProtocol
+				return
+			}
+		default:
+			err = errWebSocketProtocol
+			return
+		}
+
+		if len(data)+len(payload) > socketMaxMessageSize {
+			err = errWebSocketTooBig
+			return
+		}
+		data = append(data, payload...)
+
+		if fin {
+			return
+		}
+	}
+}
+
+func (ws *wsConn) close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+func Test_websocket_accept_key(t *testing.T) {
+	// example from RFC 6455 section 1.3
+	got := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("wsAcceptKey() = %q", got)
+	}
+}
+
+func Test_websocket_frames(t *testing.T) {
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	srv := &wsConn{conn: a, br: bufio.NewReader(a)}
+	cli := &wsConn{conn: b, br: bufio.NewReader(b), client: true}
+
+	// everything the server sends back to the client
+	received := make(chan byte, 10)
+	go func() {
+		for {
+			_, op, _, err := cli.readFrame()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- op
+		}
+	}()
+
+	t.Run("small_message", func(t *testing.T) {
+		go cli.writeFrame(wsOpText, []byte("hello"))
+
+		data, err := srv.readMessage()
+		if err != nil {
+			t.Fatalf("readMessage() = %v; want nil", err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("readMessage() = %q; want %q", data, "hello")
+		}
+	})
+
+	t.Run("large_message", func(t *testing.T) {
+		payload := bytes.Repeat([]byte("x"), 70000) // uses the 64-bit length
+		go cli.writeFrame(wsOpBinary, payload)
+
+		data, err := srv.readMessage()
+		if err != nil {
+			t.Fatalf("readMessage() = %v; want nil", err)
+		}
+		if !bytes.Equal(data, payload) {
+			t.Fatalf("readMessage() returned %d bytes; want %d", len(data), len(payload))
+		}
+	})
+
+	t.Run("ping_answered_with_pong", func(t *testing.T) {
+		go func() {
+			cli.writeFrame(wsOpPing, []byte("p"))
+			cli.writeFrame(wsOpText, []byte("after"))
+		}()
+
+		data, err := srv.readMessage()
+		if err != nil {
+			t.Fatalf("readMessage() = %v; want nil", err)
+		}
+		if string(data) != "after" {
+			t.Fatalf("readMessage() = %q; want %q", data, "after")
+		}
+		if op := <-received; op != wsOpPong {
+			t.Fatalf("received opcode %#x; want pong", op)
+		}
+	})
+
+	t.Run("close_with_status", func(t *testing.T) {
+		go cli.writeClose(4000, "bye")
+
+		_, err := srv.readMessage()
+
+		var closeErr *WebSocketCloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("readMessage() = %v; want *WebSocketCloseError", err)
+		}
+		if closeErr.Code != 4000 || closeErr.Reason != "bye" {
+			t.Fatalf("close error = %+v; want 4000 bye", closeErr)
+		}
+		if op := <-received; op != wsOpClose {
+			t.Fatalf("received opcode %#x; want close", op)
+		}
+		if err := srv.writeFrame(wsOpText, []byte("late")); !errors.Is(err, errWebSocketClosed) {
+			t.Fatalf("writeFrame() after close = %v; want errWebSocketClosed", err)
+		}
+	})
+}
@@ -1,11 +1,29 @@
 package auth
 
+import "time"
+
 type Claims struct {
 	User      User
 	Entities  RolesPerEntity
 	Tenants   Tenants
 	Roles     Roles
 	Additions map[string]any
+
+	// Registered are the registered claims of the decoded token; they are of
+	// the token rather than the user, so they are neither signed again by
+	// GenerateToken nor serialized.
+	Registered RegisteredClaims `json:"-"`
+}
+
+// RegisteredClaims are the registered claims of a token (RFC 7519); zero
+// values are not set in the token
+type RegisteredClaims struct {
+	Issuer    string    // iss
+	Audience  []string  // aud
+	ExpiresAt time.Time // exp
+	NotBefore time.Time // nbf
+	IssuedAt  time.Time // iat
+	ID        string    // jti
 }
 
 const (
@@ -13,4 +31,11 @@ const (
 	claimEntities = "entities"
 	claimTenants  = "tenants"
 	claimRoles    = "roles"
+
+	claimIssuer    = "iss"
+	claimAudience  = "aud"
+	claimExpiresAt = "exp"
+	claimNotBefore = "nbf"
+	claimIssuedAt  = "iat"
+	claimID        = "jti"
 )
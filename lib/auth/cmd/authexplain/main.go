@@ -0,0 +1,97 @@
+// Command authexplain explains how an access control policy of the
+// convention auth package decides on an action of a token or of claims:
+// every candidate template in the order the check considers them, why it
+// matched or not, and the decision. It exits with status 1 when the action is
+// forbidden.
+//
+//	authexplain -policy file (-token token | -claims file) [-config folder] [-json] METHOD PATH
+//
+// Tokens are verified with the keys of the config folder; claims are JSON as
+// auth.Claims, e.g. {"User": "jane", "Tenants": ["acme"], "Roles": ["reader"]}.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+)
+
+func main() {
+
+	policyFile := flag.String("policy", "", "JSON file of the policy")
+	token := flag.String("token", "", "token of the call, without 'Bearer '")
+	claimsFile := flag.String("claims", "", "JSON file of the claims, instead of a token")
+	config := flag.String("config", "", "config folder with the keys verifying the token (default /etc/agent/)")
+	asJSON := flag.Bool("json", false, "print the explanation as JSON")
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: authexplain -policy file (-token token | -claims file) [-config folder] [-json] METHOD PATH")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *policyFile == "" || (*token == "") == (*claimsFile == "") || flag.NArg() == 0 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var policy convAuth.Policy
+	readJSON(*policyFile, &policy)
+
+	var claims convAuth.Claims
+	if *claimsFile != "" {
+		readJSON(*claimsFile, &claims)
+	} else {
+		if *config != "" {
+			err := convCfg.SetConfigLocation(*config)
+			if err != nil {
+				fail(err)
+			}
+		}
+		var err error
+		claims, err = convAuth.DecodeToken(strings.TrimPrefix(*token, "Bearer "))
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	action := convAuth.Action(strings.Join(flag.Args(), " ")) // "GET /path" or GET /path
+
+	explanation, err := convAuth.Explain(policy, claims, action)
+	if err != nil {
+		fail(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(explanation)
+	} else {
+		fmt.Print(explanation)
+	}
+
+	if explanation.Decision == convAuth.DecisionForbidden {
+		os.Exit(1)
+	}
+}
+
+func readJSON(name string, v any) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fail(err)
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		fail(fmt.Errorf("invalid JSON in '%s': %w", name, err))
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
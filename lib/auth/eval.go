@@ -2,7 +2,9 @@ package auth
 
 import (
 	"errors"
+	"maps"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -14,15 +16,17 @@ var (
 type allowedActions []allowedAction
 
 type allowedAction struct {
-	method  string
-	path    allowedPath
-	openEnd bool
+	method   string
+	path     allowedPath
+	openEnd  bool
+	template Action // as in the policy
 }
 
-// actionSource tracks which role an action came from
+// actionSource tracks which role (and permission) an action came from
 type actionSource struct {
-	action allowedAction
-	role   Role
+	action     allowedAction
+	role       Role
+	permission Permission
 }
 
 type allowedActionSources []actionSource
@@ -31,8 +35,9 @@ func expandConfig(policy Policy) (actions allowedActionSources, publicActions al
 
 	actions = make(allowedActionSources, 0)
 
-	for role, permissions := range policy.Roles {
-		for _, permission := range permissions {
+	// in the order of the roles, so templates equally specific keep an order
+	for _, role := range slices.Sorted(maps.Keys(policy.Roles)) {
+		for _, permission := range policy.Roles[role] {
 			as, ok := policy.Permissions[permission]
 			if !ok {
 				continue
@@ -45,8 +50,9 @@ func expandConfig(policy Policy) (actions allowedActionSources, publicActions al
 					return
 				}
 				actions = append(actions, actionSource{
-					action: aa,
-					role:   role,
+					action:     aa,
+					role:       role,
+					permission: permission,
 				})
 			}
 		}
@@ -76,50 +82,74 @@ type Target struct {
 
 type Check func(r *http.Request) (Target, error)
 
-func NewCheck(policy Policy) (check Check, err error) {
+// NewCheck returns the check of requests against the policy; with audiences
+// (the names the agent is called by), tokens bound to other agents are rejected.
+func NewCheck(policy Policy, audiences ...string) (check Check, err error) {
 
-	actionSources, publicEndpoints, err := expandConfig(policy)
+	ep, err := expandPolicy(policy)
 	if err != nil {
 		return
 	}
 
 	check = func(r *http.Request) (Target, error) {
+		return ep.check(r, audiences)
+	}
 
-		var target Target
+	return
+}
 
-		if r == nil {
-			return target, ErrMissingRequest
-		}
+// expandedPolicy is a policy ready to check requests against
+type expandedPolicy struct {
+	actionSources   allowedActionSources
+	publicEndpoints allowedActions
+}
 
-		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+func expandPolicy(policy Policy) (ep *expandedPolicy, err error) {
 
-		if publicEndpoints.match(
-			r.Method,
-			segments,
-			Claims{},
-			&target,
-		) {
-			return target, nil
-		}
+	actionSources, publicEndpoints, err := expandConfig(policy)
+	if err != nil {
+		return
+	}
 
-		claims, err := DecodeHTTPRequestClaims(r)
-		if err != nil {
-			return Target{}, err
-		}
+	ep = &expandedPolicy{actionSources, publicEndpoints}
 
-		if actionSources.match(
-			r.Method,
-			segments,
-			claims,
-			&target,
-		) {
-			return target, nil
-		}
+	return
+}
+
+func (ep *expandedPolicy) check(r *http.Request, audiences []string) (Target, error) {
 
-		return target, ErrForbidden
+	var target Target
+
+	if r == nil {
+		return target, ErrMissingRequest
 	}
 
-	return
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if ep.publicEndpoints.match(
+		r.Method,
+		segments,
+		Claims{},
+		&target,
+	) {
+		return target, nil
+	}
+
+	claims, err := DecodeHTTPRequestClaims(r, audiences...)
+	if err != nil {
+		return Target{}, err
+	}
+
+	if ep.actionSources.match(
+		r.Method,
+		segments,
+		claims,
+		&target,
+	) {
+		return target, nil
+	}
+
+	return target, ErrForbidden
 }
 
 func generateAllowedAction(a Action) (res allowedAction, err error) {
@@ -153,7 +183,7 @@ func generateAllowedAction(a Action) (res allowedAction, err error) {
 		}
 	}
 
-	res = allowedAction{method, allowedPath, openEnd}
+	res = allowedAction{method, allowedPath, openEnd, a}
 
 	return
 }
@@ -211,35 +241,53 @@ func (as allowedActions) match(method string, segments []string, claims Claims,
 }
 
 func (a allowedAction) match(method string, segments []string, claims Claims, target *Target) bool {
+	return a.compare(method, segments, claims, target).kind == mismatchNone
+}
+
+type mismatchKind int
+
+const (
+	mismatchNone    mismatchKind = iota
+	mismatchMethod               // the method differs
+	mismatchLength               // the number of segments does not fit
+	mismatchSegment              // a segment does not match
+)
+
+// actionMismatch is why an action does not match a template; explain turns
+// it into text, so that checks do not format
+type actionMismatch struct {
+	kind    mismatchKind
+	segment int // index of the segment not matching
+}
+
+// compare matches the action against the template, setting the target of
+// the matched segments
+func (a allowedAction) compare(method string, segments []string, claims Claims, target *Target) actionMismatch {
 
 	if a.method != method {
-		return false
+		return actionMismatch{kind: mismatchMethod}
 	}
 
 	if a.openEnd {
 		if len(a.path) > len(segments) {
-			return false
+			return actionMismatch{kind: mismatchLength}
 		}
-		return a.path.match(segments[:len(a.path)], claims, target)
-	} else {
-		return a.path.match(segments, claims, target)
+		segments = segments[:len(a.path)]
+	} else if len(a.path) != len(segments) {
+		return actionMismatch{kind: mismatchLength}
 	}
-}
 
-type allowedPath []allowedSegment
-
-func (p allowedPath) match(segments []string, claims Claims, target *Target) bool {
-	if len(p) != len(segments) {
-		return false
-	}
-	for i := range p {
-		if !p[i].Match(segments[i], claims, target) {
-			return false
+	for i := range a.path {
+		if !a.path[i].Match(segments[i], claims, target) {
+			return actionMismatch{kind: mismatchSegment, segment: i}
 		}
 	}
-	return true
+
+	return actionMismatch{}
 }
 
+type allowedPath []allowedSegment
+
 type allowedSegment interface {
 	Match(segment string, claims Claims, target *Target) bool
 }
@@ -335,13 +383,13 @@ func actionSpecificity(a allowedAction) int {
 
 // sortBySpecificity sorts actions from most specific to least specific
 func (sources allowedActionSources) sortBySpecificity() {
-	sort.Slice(sources, func(i, j int) bool {
+	sort.SliceStable(sources, func(i, j int) bool {
 		return actionSpecificity(sources[i].action) < actionSpecificity(sources[j].action)
 	})
 }
 
 func (actions allowedActions) sortBySpecificity() {
-	sort.Slice(actions, func(i, j int) bool {
+	sort.SliceStable(actions, func(i, j int) bool {
 		return actionSpecificity(actions[i]) < actionSpecificity(actions[j])
 	})
 }
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Decision of a policy on an action
+type Decision string
+
+const (
+	DecisionPublic    Decision = "public"    // allowed by a public action, without claims
+	DecisionAllowed   Decision = "allowed"   // allowed by a role of the claims
+	DecisionForbidden Decision = "forbidden" // ErrForbidden
+)
+
+// Explanation is how a policy decides on an action of claims
+type Explanation struct {
+	Action     Action      `json:"action"`
+	Decision   Decision    `json:"decision"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is a template of the policy and why it matched the action or not
+type Candidate struct {
+	Template   Action     `json:"template"`
+	Public     bool       `json:"public,omitempty"`
+	Role       Role       `json:"role,omitempty"`       // granting the template; empty when public
+	Permission Permission `json:"permission,omitempty"` // of the role with the template
+	Matched    bool       `json:"matched"`
+	Decisive   bool       `json:"decisive,omitempty"` // the match deciding, the first one
+	Reason     string     `json:"reason"`
+}
+
+// Explain returns how the policy decides on the action (e.g. "GET
+// /tenants/acme/orders") of the claims. The candidates are in the order the
+// check considers them - the public actions, then the actions of the roles,
+// each from the most specific - and the first match decides.
+func Explain(policy Policy, claims Claims, action Action) (res Explanation, err error) {
+
+	ep, err := expandPolicy(policy)
+	if err != nil {
+		return
+	}
+
+	method, path, err := action.MethodPath()
+	if err != nil {
+		return
+	}
+	segments := strings.Split(path, "/")
+
+	res = Explanation{
+		Action:   action,
+		Decision: DecisionForbidden,
+	}
+
+	for _, a := range ep.publicEndpoints {
+		c := Candidate{Template: a.template, Public: true}
+		_, c.Reason = a.explain(method, segments, Claims{}) // public actions are matched without claims
+		c.Matched = c.Reason == ""
+		if c.Matched && res.Decision == DecisionForbidden {
+			c.Decisive = true
+			res.Decision = DecisionPublic
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+
+	for _, src := range ep.actionSources {
+		c := Candidate{Template: src.action.template, Role: src.role, Permission: src.permission}
+		var target Target
+		target, c.Reason = src.action.explain(method, segments, claims)
+		if c.Reason == "" && !isRoleAllowed(src.role, target.Entity, claims) {
+			c.Reason = roleNotGranted(src.role, target.Entity)
+		}
+		c.Matched = c.Reason == ""
+		if c.Matched && res.Decision == DecisionForbidden {
+			c.Decisive = true
+			res.Decision = DecisionAllowed
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+
+	for i := range res.Candidates {
+		if res.Candidates[i].Matched && !res.Candidates[i].Decisive {
+			res.Candidates[i].Reason = "matched, after the decisive template"
+		}
+	}
+
+	return
+}
+
+// String returns the explanation as lines of text, one per candidate
+func (e Explanation) String() string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%s → %s\n", e.Action, e.Decision)
+	for _, c := range e.Candidates {
+		mark := "✘"
+		if c.Matched {
+			mark = "✔"
+		}
+		grant := "public"
+		if !c.Public {
+			grant = fmt.Sprintf("role '%s' (%s)", c.Role, c.Permission)
+		}
+		reason := c.Reason
+		if c.Decisive {
+			reason = "decisive"
+		}
+		fmt.Fprintf(&sb, "  %s %s [%s]: %s\n", mark, c.Template, grant, reason)
+	}
+	return sb.String()
+}
+
+// explain matches the action as match does, returning why it does not match;
+// empty when it does
+func (a allowedAction) explain(method string, segments []string, claims Claims) (target Target, reason string) {
+
+	m := a.compare(method, segments, claims, &target)
+
+	switch m.kind {
+	case mismatchMethod:
+		reason = fmt.Sprintf("method is %s, not %s", method, a.method)
+	case mismatchLength:
+		if a.openEnd {
+			reason = fmt.Sprintf("path has %d segments, the template at least %d", len(segments), len(a.path))
+		} else {
+			reason = fmt.Sprintf("path has %d segments, the template %d", len(segments), len(a.path))
+		}
+	case mismatchSegment:
+		reason = segmentMismatch(a.path[m.segment], segments[m.segment], claims)
+	}
+
+	return
+}
+
+func segmentMismatch(seg allowedSegment, segment string, claims Claims) string {
+	switch seg := seg.(type) {
+	case allowedSegmentFixed:
+		return fmt.Sprintf("'%s' does not match '%s'", string(seg), segment)
+	case allowedSegmentUser:
+		if claims.User == "" {
+			return fmt.Sprintf("{user} does not match '%s' without a user", segment)
+		}
+		return fmt.Sprintf("{user} does not match '%s', the user is '%s'", segment, claims.User)
+	case allowedSegmentTenant:
+		return fmt.Sprintf("{tenant} does not match '%s', the tenants are %v", segment, claims.Tenants)
+	case allowedSegmentEntity:
+		return fmt.Sprintf("{entity} does not match '%s', the entities are %v", segment, slices.Sorted(maps.Keys(claims.Entities)))
+	default:
+		return fmt.Sprintf("segment '%s' does not match", segment)
+	}
+}
+
+func roleNotGranted(role Role, entity Entity) string {
+	if entity == "" {
+		return fmt.Sprintf("role '%s' is not granted to the claims", role)
+	}
+	return fmt.Sprintf("role '%s' is granted neither to the claims nor for entity '%s'", role, entity)
+}
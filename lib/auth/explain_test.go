@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+func TestExplain(t *testing.T) {
+
+	t.Run("agrees_with_check", func(t *testing.T) {
+		for _, td := range testData {
+
+			check, err := convAuth.NewCheck(td.policy)
+			if err != nil {
+				t.Fatalf("NewCheck failed: %v", err)
+			}
+
+			claims := convAuth.Claims{
+				User:     td.user,
+				Tenants:  td.tenants,
+				Roles:    td.roles,
+				Entities: td.entities,
+			}
+
+			for _, req := range append(slices.Clone(td.pass), td.block...) {
+				res, err := convAuth.Explain(td.policy, claims, convAuth.Action(req.Method+" "+req.URL.Path))
+				if err != nil {
+					t.Fatalf("Explain failed: %v", err)
+				}
+
+				r := req.Clone(context.Background())
+				r.Header = make(http.Header)
+				err = convAuth.EncodeHTTPRequestClaims(r, claims)
+				if err != nil {
+					t.Fatalf("EncodeHTTPRequestClaims failed: %v", err)
+				}
+				_, checkErr := check(r)
+
+				if (res.Decision == convAuth.DecisionForbidden) != (checkErr != nil) {
+					t.Fatalf("%s\n%s %s: explained as %s, check = %v:\n%s", td.name, req.Method, req.URL.Path, res.Decision, checkErr, res)
+				}
+				if slices.Contains(td.block, req) != (res.Decision == convAuth.DecisionForbidden) {
+					t.Fatalf("%s\n%s %s: explained as %s:\n%s", td.name, req.Method, req.URL.Path, res.Decision, res)
+				}
+			}
+		}
+	})
+
+	t.Run("reasons", func(t *testing.T) {
+		claims := convAuth.Claims{
+			User:     "user1",
+			Tenants:  convAuth.Tenants{"tenant1"},
+			Roles:    convAuth.Roles{"manage_own_assets"},
+			Entities: convAuth.RolesPerEntity{"entity1": convAuth.Roles{"basic_user"}},
+		}
+
+		reasons := func(t *testing.T, policy convAuth.Policy, action convAuth.Action) (res convAuth.Explanation, byTemplate map[convAuth.Action]string) {
+			t.Helper()
+			res, err := convAuth.Explain(policy, claims, action)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			byTemplate = map[convAuth.Action]string{}
+			for _, c := range res.Candidates {
+				byTemplate[c.Template] = c.Reason
+			}
+			return
+		}
+
+		res, byTemplate := reasons(t, fullPolicy, "PUT /tenants/tenant2/users/user1/assets/asset1")
+		if res.Decision != convAuth.DecisionForbidden {
+			t.Fatalf("Decision = %s; want forbidden", res.Decision)
+		}
+		for template, want := range map[convAuth.Action]string{
+			"GET /public/{any...}":                            "method is PUT, not GET",
+			"PUT /tenants/{tenant}/users/{user}/assets/{any}": "{tenant} does not match 'tenant2', the tenants are [tenant1]",
+			"PUT /tenants/{any}/users/{any}/assets/{any}":     "role 'manage_all_assets_all_tenants' is not granted to the claims",
+			"PUT /tenants/{any}/users/{any}/open/{any...}":    "'open' does not match 'assets'",
+		} {
+			if byTemplate[template] != want {
+				t.Fatalf("reason of %s = %q; want %q", template, byTemplate[template], want)
+			}
+		}
+
+		res, byTemplate = reasons(t, entityRolePolicy, "PUT /entities/entity1/data/d1")
+		if res.Decision != convAuth.DecisionForbidden {
+			t.Fatalf("Decision = %s; want forbidden", res.Decision)
+		}
+		want := "role 'entity_admin' is granted neither to the claims nor for entity 'entity1'"
+		if byTemplate["PUT /entities/{entity}/data/{any}"] != want {
+			t.Fatalf("reason = %q; want %q", byTemplate["PUT /entities/{entity}/data/{any}"], want)
+		}
+
+		res, _ = reasons(t, fullPolicy, "GET /tenants/tenant1/users/user1/assets/asset1")
+		decisive := 0
+		for _, c := range res.Candidates {
+			if c.Decisive {
+				decisive++
+				if c.Template != "GET /tenants/{tenant}/users/{user}/assets/{any}" || c.Role != "manage_own_assets" {
+					t.Fatalf("decisive = %+v; want the own assets template", c)
+				}
+			}
+		}
+		if res.Decision != convAuth.DecisionAllowed || decisive != 1 {
+			t.Fatalf("Explain = %s; want allowed by one decisive template", res)
+		}
+		if !strings.HasPrefix(res.String(), "GET /tenants/tenant1/users/user1/assets/asset1 → allowed\n") {
+			t.Fatalf("String() = %s", res)
+		}
+	})
+}
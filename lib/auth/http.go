@@ -1,14 +1,16 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 
-	convCfg "github.com/sofmon/convention/lib/cfg"
+	"github.com/google/uuid"
 )
 
 const (
@@ -16,29 +18,35 @@ const (
 )
 
 var (
-	hmacSecret []byte
+	// TokenTTL is the lifetime of the tokens of GenerateToken
+	TokenTTL = time.Hour
+
+	// TokenLeeway is the clock skew tolerated when validating exp, nbf and iat
+	TokenLeeway = 30 * time.Second
+
+	// AcceptTokensWithoutExpiry accepts tokens without exp, which never
+	// expire; only to migrate from tokens minted before exp was set.
+	AcceptTokensWithoutExpiry = false
 
 	ErrMissingRequest             = errors.New("HTTP request is nil")
 	ErrMissingAuthorizationHeader = errors.New("HTTP request has no valid Bearer authentication; expecting header like 'Authorization: Bearer <token>'")
 	ErrInvalidAuthorizationToken  = errors.New("HTTP request has invalid bearer token'")
+	ErrTokenMalformed             = errors.New("bearer token is malformed")
+	ErrTokenExpired               = errors.New("bearer token is expired")
+	ErrTokenNotValidYet           = errors.New("bearer token is not valid yet")
+	ErrInvalidIssuer              = errors.New("bearer token is issued by another issuer")
 )
 
-func getHmacSecret() ([]byte, error) {
-
-	if hmacSecret != nil {
-		return hmacSecret, nil
-	}
-
-	var err error
-	hmacSecret, err = convCfg.Bytes("communication_secret")
-	if err != nil {
-		return nil, err
-	}
-
-	return hmacSecret, nil
+// DecodeHTTPRequestClaims returns the claims of the request bearer token,
+// validated at the time of WithValidationTime of the request context, or now;
+// with audiences, tokens bound to other audiences are rejected.
+func DecodeHTTPRequestClaims(r *http.Request, audiences ...string) (res Claims, err error) {
+	return DecodeHTTPRequestClaimsAt(r, validationTime(r.Context()), audiences...)
 }
 
-func DecodeHTTPRequestClaims(r *http.Request) (res Claims, err error) {
+// DecodeHTTPRequestClaimsAt returns the claims of the request bearer token,
+// validated at now.
+func DecodeHTTPRequestClaimsAt(r *http.Request, now time.Time, audiences ...string) (res Claims, err error) {
 
 	authHeader := r.Header.Get(HttpHeaderAuthorization)
 	if authHeader == "" {
@@ -52,7 +60,23 @@ func DecodeHTTPRequestClaims(r *http.Request) (res Claims, err error) {
 		return
 	}
 
-	return DecodeToken(authSplit[1])
+	return DecodeTokenAt(authSplit[1], now, audiences...)
+}
+
+type contextKeyValidationTime struct{}
+
+// WithValidationTime returns a context validating the tokens of requests at
+// now instead of the current time, for the time travel of non-production
+// agents.
+func WithValidationTime(parent context.Context, now time.Time) context.Context {
+	return context.WithValue(parent, contextKeyValidationTime{}, now)
+}
+
+func validationTime(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(contextKeyValidationTime{}).(time.Time); ok {
+		return now
+	}
+	return time.Now()
 }
 
 func EncodeHTTPRequestClaims(r *http.Request, claims Claims) error {
@@ -67,11 +91,49 @@ func EncodeHTTPRequestClaims(r *http.Request, claims Claims) error {
 	return nil
 }
 
+// GenerateToken signs the claims as a token that expires after TokenTTL;
+// agent calls use GenerateServiceToken instead.
 func GenerateToken(claims Claims) (string, error) {
+	token, _, err := generateToken(claims, TokenOptions{})
+	return token, err
+}
+
+// TokenOptions are the registered claims of a generated token; zero values
+// are defaults.
+type TokenOptions struct {
+	Audience  []string      // aud; no audience accepts the token everywhere
+	TTL       time.Duration // exp after the issue time; TokenTTL by default
+	NotBefore time.Time     // nbf; the issue time by default
+	IssuedAt  time.Time     // iat; now by default, for tokens of a time travel
+}
+
+// GenerateTokenWithOptions signs the claims as a token with the registered
+// claims of opts; iss is communication_issuer when configured.
+func GenerateTokenWithOptions(claims Claims, opts TokenOptions) (token string, expiresAt time.Time, err error) {
+	return generateToken(claims, opts)
+}
 
-	hmac, err := getHmacSecret()
+func generateToken(claims Claims, opts TokenOptions) (token string, expiresAt time.Time, err error) {
+
+	ks, err := currentKeys()
 	if err != nil {
-		return "", err
+		return
+	}
+
+	issuedAt := opts.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = TokenTTL
+	}
+	expiresAt = issuedAt.Add(ttl)
+
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		notBefore = issuedAt
 	}
 
 	rawClaim := make(map[string]any)
@@ -80,36 +142,67 @@ func GenerateToken(claims Claims) (string, error) {
 		rawClaim[k] = v
 	}
 
+	rawClaim[claimExpiresAt] = jwt.NewNumericDate(expiresAt)
+	rawClaim[claimNotBefore] = jwt.NewNumericDate(notBefore)
+	rawClaim[claimIssuedAt] = jwt.NewNumericDate(issuedAt)
+	rawClaim[claimID] = uuid.NewString()
+
+	if ks.issuer != "" {
+		rawClaim[claimIssuer] = ks.issuer
+	}
+
+	switch len(opts.Audience) {
+	case 0:
+	case 1:
+		rawClaim[claimAudience] = opts.Audience[0]
+	default:
+		rawClaim[claimAudience] = opts.Audience
+	}
+
 	// fixed claims overwrite any additions with the same key
 	rawClaim[claimUser] = string(claims.User)
 	rawClaim[claimEntities] = claims.Entities
 	rawClaim[claimTenants] = claims.Tenants
 	rawClaim[claimRoles] = claims.Roles
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(rawClaim))
-	tokenString, err := token.SignedString(hmac)
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+	token, err = ks.sign(jwt.MapClaims(rawClaim))
+
+	return
 }
 
-func DecodeToken(tokenString string) (res Claims, err error) {
+// DecodeToken returns the claims of a valid token, validated now; with
+// audiences, tokens bound to other audiences are rejected.
+func DecodeToken(tokenString string, audiences ...string) (res Claims, err error) {
+	return DecodeTokenAt(tokenString, time.Now(), audiences...)
+}
 
-	hmac, err := getHmacSecret()
+// DecodeTokenAt returns the claims of a token valid at now, within
+// TokenLeeway; tokens without nbf are valid from the start, tokens without exp
+// are rejected unless AcceptTokensWithoutExpiry is set. Expired tokens fail
+// with ErrTokenExpired, malformed tokens with ErrTokenMalformed and revoked
+// tokens with ErrTokenRevoked.
+func DecodeTokenAt(tokenString string, now time.Time, audiences ...string) (res Claims, err error) {
+
+	ks, err := currentKeys()
 	if err != nil {
 		return
 	}
 
-	token, err := jwt.Parse(tokenString,
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return hmac, nil
-		},
-	)
+	opts := []jwt.ParserOption{
+		jwt.WithLeeway(TokenLeeway),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithIssuedAt(),
+	}
+	if !AcceptTokensWithoutExpiry {
+		opts = append(opts, jwt.WithExpirationRequired())
+	}
+	if ks.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(ks.issuer))
+	}
+
+	token, err := jwt.Parse(tokenString, ks.verificationKey, opts...)
 	if err != nil {
+		err = tokenError(err)
 		return
 	}
 
@@ -124,6 +217,16 @@ func DecodeToken(tokenString string) (res Claims, err error) {
 		return
 	}
 
+	err = verifyAudience(claims, audiences)
+	if err != nil {
+		return
+	}
+
+	res.Registered, err = registeredClaims(claims)
+	if err != nil {
+		return
+	}
+
 	if user, ok := claims[claimUser].(string); ok {
 		res.User = User(user)
 	}
@@ -158,5 +261,64 @@ func DecodeToken(tokenString string) (res Claims, err error) {
 		}
 	}
 
+	if isRevoked(res) {
+		return Claims{}, ErrTokenRevoked
+	}
+
+	return
+}
+
+// tokenError returns the error of the failed validation of a token
+func tokenError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrInvalidAudience
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return fmt.Errorf("%w: %w", ErrInvalidAuthorizationToken, err)
+	default:
+		return err
+	}
+}
+
+func registeredClaims(claims jwt.MapClaims) (res RegisteredClaims, err error) {
+
+	res.Issuer, err = claims.GetIssuer()
+	if err != nil {
+		return
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return
+	}
+	res.Audience = aud
+
+	for _, c := range []struct {
+		get func() (*jwt.NumericDate, error)
+		set *time.Time
+	}{
+		{claims.GetExpirationTime, &res.ExpiresAt},
+		{claims.GetNotBefore, &res.NotBefore},
+		{claims.GetIssuedAt, &res.IssuedAt},
+	} {
+		date, err := c.get()
+		if err != nil {
+			return res, err
+		}
+		if date != nil {
+			*c.set = date.Time
+		}
+	}
+
+	res.ID, _ = claims[claimID].(string)
+
 	return
 }
@@ -0,0 +1,187 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+)
+
+func TestRegisteredClaims(t *testing.T) {
+
+	claims := convAuth.Claims{User: "user-v1", Roles: convAuth.Roles{"reader"}}
+
+	t.Run("generated", func(t *testing.T) {
+		token, err := convAuth.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+
+		res, err := convAuth.DecodeToken(token)
+		if err != nil {
+			t.Fatalf("DecodeToken failed: %v", err)
+		}
+
+		reg := res.Registered
+		if reg.ID == "" || reg.IssuedAt.IsZero() || !reg.NotBefore.Equal(reg.IssuedAt) {
+			t.Fatalf("Registered = %+v; want jti, iat and nbf at iat", reg)
+		}
+		if ttl := reg.ExpiresAt.Sub(reg.IssuedAt); ttl != convAuth.TokenTTL {
+			t.Fatalf("exp - iat = %v; want %v", ttl, convAuth.TokenTTL)
+		}
+		if reg.Issuer != "" || reg.Audience != nil {
+			t.Fatalf("Registered = %+v; want no iss and aud without communication_issuer and audience", reg)
+		}
+
+		other, _ := convAuth.GenerateToken(claims)
+		if res2, _ := convAuth.DecodeToken(other); res2.Registered.ID == reg.ID {
+			t.Fatalf("jti = %s twice; want unique ids", reg.ID)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		issuedAt := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+
+		token, expiresAt, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{
+			Audience:  []string{"orders", "payments"},
+			TTL:       10 * time.Minute,
+			NotBefore: issuedAt.Add(time.Minute),
+			IssuedAt:  issuedAt,
+		})
+		if err != nil {
+			t.Fatalf("GenerateTokenWithOptions failed: %v", err)
+		}
+		if want := issuedAt.Add(10 * time.Minute); !expiresAt.Equal(want) {
+			t.Fatalf("expiresAt = %v; want %v", expiresAt, want)
+		}
+
+		res, err := convAuth.DecodeTokenAt(token, issuedAt.Add(5*time.Minute), "payments")
+		if err != nil {
+			t.Fatalf("DecodeTokenAt failed: %v", err)
+		}
+		reg := res.Registered
+		if !slices.Equal(reg.Audience, []string{"orders", "payments"}) || !reg.NotBefore.Equal(issuedAt.Add(time.Minute)) || !reg.ExpiresAt.Equal(expiresAt) {
+			t.Fatalf("Registered = %+v; want the options", reg)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		issuedAt := time.Now().Add(-2 * time.Hour)
+
+		token, _, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{IssuedAt: issuedAt, TTL: time.Hour})
+		if err != nil {
+			t.Fatalf("GenerateTokenWithOptions failed: %v", err)
+		}
+
+		for _, c := range []struct {
+			name string
+			at   time.Time
+			want error
+		}{
+			{"expired", time.Now(), convAuth.ErrTokenExpired},
+			{"valid", issuedAt.Add(30 * time.Minute), nil},
+			{"expired_within_leeway", issuedAt.Add(time.Hour + convAuth.TokenLeeway/2), nil},
+			{"expired_beyond_leeway", issuedAt.Add(time.Hour + 2*convAuth.TokenLeeway), convAuth.ErrTokenExpired},
+			{"issued_within_leeway", issuedAt.Add(-convAuth.TokenLeeway / 2), nil},
+			{"not_yet_valid", issuedAt.Add(-2 * convAuth.TokenLeeway), convAuth.ErrTokenNotValidYet},
+		} {
+			_, err := convAuth.DecodeTokenAt(token, c.at)
+			if !errors.Is(err, c.want) {
+				t.Fatalf("%s: DecodeTokenAt = %v; want %v", c.name, err, c.want)
+			}
+		}
+
+		for _, malformed := range []string{"", "not-a-token", "a.b.c"} {
+			_, err := convAuth.DecodeToken(malformed)
+			if !errors.Is(err, convAuth.ErrTokenMalformed) {
+				t.Fatalf("DecodeToken(%q) = %v; want ErrTokenMalformed", malformed, err)
+			}
+		}
+	})
+
+	t.Run("without_expiry", func(t *testing.T) {
+		secret, _ := convCfg.Bytes("communication_secret")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user": "user-v1",
+			"iat":  time.Now().Unix(),
+		}).SignedString(secret)
+		if err != nil {
+			t.Fatalf("SignedString failed: %v", err)
+		}
+
+		_, err = convAuth.DecodeToken(token)
+		if !errors.Is(err, convAuth.ErrInvalidAuthorizationToken) {
+			t.Fatalf("DecodeToken without exp = %v; want ErrInvalidAuthorizationToken", err)
+		}
+
+		defer func() { convAuth.AcceptTokensWithoutExpiry = false }()
+		convAuth.AcceptTokensWithoutExpiry = true
+
+		res, err := convAuth.DecodeToken(token)
+		if err != nil || res.User != "user-v1" {
+			t.Fatalf("DecodeToken without exp, accepted = %+v, %v; want the claims", res, err)
+		}
+	})
+
+	t.Run("issuer", func(t *testing.T) {
+		secret, _ := convCfg.Bytes("communication_secret")
+		useConfig(t, map[string]any{
+			"communication_secret": secret,
+			"communication_issuer": []byte("orders-v1\n"),
+		})
+
+		token, err := convAuth.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		res, err := convAuth.DecodeToken(token)
+		if err != nil || res.Registered.Issuer != "orders-v1" {
+			t.Fatalf("DecodeToken = %+v, %v; want iss orders-v1", res.Registered, err)
+		}
+
+		useConfig(t, map[string]any{
+			"communication_secret": secret,
+			"communication_issuer": []byte("payments-v1"),
+		})
+		_, err = convAuth.DecodeToken(token)
+		if !errors.Is(err, convAuth.ErrInvalidIssuer) {
+			t.Fatalf("DecodeToken of another issuer = %v; want ErrInvalidIssuer", err)
+		}
+	})
+
+	t.Run("validation_time", func(t *testing.T) {
+		check, err := convAuth.NewCheck(convAuth.Policy{
+			Roles:       convAuth.RolePermissions{"reader": convAuth.Permissions{"read"}},
+			Permissions: convAuth.PermissionActions{"read": convAuth.Actions{"GET /orders"}},
+		})
+		if err != nil {
+			t.Fatalf("NewCheck failed: %v", err)
+		}
+
+		issuedAt := time.Now().Add(-48 * time.Hour)
+		token, _, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{IssuedAt: issuedAt})
+		if err != nil {
+			t.Fatalf("GenerateTokenWithOptions failed: %v", err)
+		}
+
+		req := &http.Request{Method: "GET", URL: &url.URL{Path: "/orders"}, Header: http.Header{}}
+		req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+token)
+
+		_, err = check(req)
+		if !errors.Is(err, convAuth.ErrTokenExpired) {
+			t.Fatalf("check now = %v; want ErrTokenExpired", err)
+		}
+
+		_, err = check(req.WithContext(convAuth.WithValidationTime(req.Context(), issuedAt.Add(time.Minute))))
+		if err != nil {
+			t.Fatalf("check at the issue time = %v; want nil", err)
+		}
+	})
+}
@@ -0,0 +1,275 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+)
+
+func publicPEM(t *testing.T, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func privatePEM(t *testing.T, key crypto.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// useConfig switches to a config folder with the files and reloads the keys;
+// the test config is restored when the test ends.
+func useConfig(t *testing.T, files map[string]any) string {
+
+	dir := t.TempDir()
+	for name, content := range files {
+		data, ok := content.([]byte)
+		if !ok {
+			data, _ = json.Marshal(content)
+		}
+		err := os.WriteFile(filepath.Join(dir, name), data, 0600)
+		if err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	err := convCfg.SetConfigLocation(dir)
+	if err != nil {
+		t.Fatalf("SetConfigLocation failed: %v", err)
+	}
+	err = convAuth.ReloadKeys()
+	if err != nil {
+		t.Fatalf("ReloadKeys failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		convCfg.SetConfigLocation("../../.secret")
+		convAuth.ReloadKeys()
+	})
+
+	return dir
+}
+
+func tokenHeader(t *testing.T, token string) (header map[string]any) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("token header: %v", err)
+	}
+	json.Unmarshal(data, &header)
+	return
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	claims := convAuth.Claims{User: "agent-v1", Roles: convAuth.Roles{"agent"}}
+
+	legacy, err := convAuth.GenerateToken(claims) // HS256 with the test communication_secret
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	verifyKeys := []convAuth.KeyConfig{
+		{KID: "2026-04", Alg: "RS256", PublicKey: publicPEM(t, &rsaKey.PublicKey)},
+		{KID: "2026-07", Alg: "EdDSA", PublicKey: publicPEM(t, edPub)},
+		{KID: "2026-10", Alg: "ES256", PublicKey: publicPEM(t, &ecKey.PublicKey)},
+	}
+
+	var signed string
+
+	t.Run("signing_agent", func(t *testing.T) {
+		useConfig(t, map[string]any{
+			"communication_keys":        verifyKeys[:2], // the signing key is verified without being listed
+			"communication_signing_key": convAuth.KeyConfig{KID: "2026-10", Alg: "ES256", PrivateKey: privatePEM(t, ecKey)},
+		})
+
+		signed, err = convAuth.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		if h := tokenHeader(t, signed); h["kid"] != "2026-10" || h["alg"] != "ES256" {
+			t.Fatalf("token header = %v; want kid 2026-10 and alg ES256", h)
+		}
+
+		res, err := convAuth.DecodeToken(signed)
+		if err != nil || res.User != claims.User {
+			t.Fatalf("DecodeToken = %v, %v; want the claims", res, err)
+		}
+
+		_, err = convAuth.DecodeToken(legacy)
+		if !errors.Is(err, convAuth.ErrUnknownKey) {
+			t.Fatalf("DecodeToken of an HS256 token without communication_secret = %v; want ErrUnknownKey", err)
+		}
+	})
+
+	t.Run("verifying_agent", func(t *testing.T) {
+		useConfig(t, map[string]any{
+			"communication_keys": verifyKeys,
+		})
+
+		res, err := convAuth.DecodeToken(signed)
+		if err != nil || res.User != claims.User {
+			t.Fatalf("DecodeToken = %v, %v; want the claims", res, err)
+		}
+
+		_, err = convAuth.GenerateToken(claims)
+		if !errors.Is(err, convAuth.ErrNoSigningKey) {
+			t.Fatalf("GenerateToken without a signing key = %v; want ErrNoSigningKey", err)
+		}
+	})
+
+	t.Run("migrating_agent", func(t *testing.T) {
+		secret, _ := convCfg.Bytes("communication_secret")
+		useConfig(t, map[string]any{
+			"communication_secret":      secret,
+			"communication_keys":        verifyKeys,
+			"communication_signing_key": convAuth.KeyConfig{KID: "2026-07", Alg: "EdDSA", PrivateKey: privatePEM(t, edKey)},
+		})
+
+		for _, token := range []string{legacy, signed} {
+			_, err := convAuth.DecodeToken(token)
+			if err != nil {
+				t.Fatalf("DecodeToken = %v; want nil for HS256 and signed tokens", err)
+			}
+		}
+
+		token, err := convAuth.GenerateToken(claims)
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		if h := tokenHeader(t, token); h["kid"] != "2026-07" || h["alg"] != "EdDSA" {
+			t.Fatalf("token header = %v; want kid 2026-07 and alg EdDSA", h)
+		}
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		dir := useConfig(t, map[string]any{
+			"communication_keys": verifyKeys,
+		})
+
+		defer func(interval time.Duration) { convAuth.KeysReloadInterval = interval }(convAuth.KeysReloadInterval)
+		convAuth.KeysReloadInterval = 0 // read the config on every use
+
+		data, _ := json.Marshal(verifyKeys[:2]) // 2026-10 is retired
+		os.WriteFile(filepath.Join(dir, "communication_keys"), data, 0600)
+
+		_, err := convAuth.DecodeToken(signed)
+		if !errors.Is(err, convAuth.ErrUnknownKey) {
+			t.Fatalf("DecodeToken with a retired key = %v; want ErrUnknownKey", err)
+		}
+	})
+
+	t.Run("rejected_config", func(t *testing.T) {
+		dir := useConfig(t, map[string]any{
+			"communication_keys": verifyKeys,
+		})
+
+		defer func(interval time.Duration) { convAuth.KeysReloadInterval = interval }(convAuth.KeysReloadInterval)
+		convAuth.KeysReloadInterval = 200 * time.Millisecond
+
+		var logs strings.Builder
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+		os.WriteFile(filepath.Join(dir, "communication_keys"), []byte(`[{"kid": "k", "alg": "HS256"}]`), 0600)
+		time.Sleep(convAuth.KeysReloadInterval)
+
+		for range 3 {
+			_, err := convAuth.DecodeToken(signed)
+			if err != nil {
+				t.Fatalf("DecodeToken with a rejected config = %v; want the previous keys", err)
+			}
+		}
+		if n := strings.Count(logs.String(), "keys reload rejected"); n != 1 {
+			t.Fatalf("rejected config logged %d times; want once:\n%s", n, logs.String())
+		}
+
+		data, _ := json.Marshal(verifyKeys[:2]) // 2026-10 is retired
+		os.WriteFile(filepath.Join(dir, "communication_keys"), data, 0600)
+
+		_, err := convAuth.DecodeToken(signed)
+		if err != nil {
+			t.Fatalf("DecodeToken right after a rejected reload = %v; want the previous keys until the next interval", err)
+		}
+
+		time.Sleep(convAuth.KeysReloadInterval)
+
+		_, err = convAuth.DecodeToken(signed)
+		if !errors.Is(err, convAuth.ErrUnknownKey) {
+			t.Fatalf("DecodeToken after the interval = %v; want ErrUnknownKey", err)
+		}
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		useConfig(t, map[string]any{
+			"communication_keys": verifyKeys,
+		})
+
+		set, err := convAuth.JWKS()
+		if err != nil {
+			t.Fatalf("JWKS failed: %v", err)
+		}
+
+		if len(set.Keys) != 3 {
+			t.Fatalf("len(JWKS().Keys) = %d; want 3", len(set.Keys))
+		}
+
+		for i, want := range []struct{ kid, kty, alg, crv string }{
+			{"2026-04", "RSA", "RS256", ""},
+			{"2026-07", "OKP", "EdDSA", "Ed25519"},
+			{"2026-10", "EC", "ES256", "P-256"},
+		} {
+			k := set.Keys[i]
+			if k.KID != want.kid || k.Kty != want.kty || k.Alg != want.alg || k.Crv != want.crv || k.Use != "sig" {
+				t.Fatalf("JWKS().Keys[%d] = %+v; want %+v", i, k, want)
+			}
+		}
+
+		if rsa := set.Keys[0]; rsa.E != "AQAB" || len(rsa.N) != 342 {
+			t.Fatalf("RSA key e = %s, len(n) = %d; want AQAB and 342", rsa.E, len(rsa.N))
+		}
+		if ec := set.Keys[2]; len(ec.X) != 43 || len(ec.Y) != 43 {
+			t.Fatalf("EC key len(x) = %d, len(y) = %d; want 43", len(ec.X), len(ec.Y))
+		}
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "communication_keys"), []byte(`[{"kid": "k", "alg": "HS256"}]`), 0600)
+		convCfg.SetConfigLocation(dir)
+		defer func() {
+			convCfg.SetConfigLocation("../../.secret")
+			convAuth.ReloadKeys()
+		}()
+
+		err := convAuth.ReloadKeys()
+		if err == nil {
+			t.Fatal("ReloadKeys with an HS256 key = nil; want error")
+		}
+	})
+}
@@ -0,0 +1,409 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// OIDCKeysRefreshInterval is how often the JWKS of the providers is loaded
+	// again; it is also loaded on tokens of unknown keys, at most every
+	// OIDCKeysMinRefreshInterval.
+	OIDCKeysRefreshInterval    = time.Hour
+	OIDCKeysMinRefreshInterval = time.Minute
+
+	ErrInvalidOIDCConfig = errors.New("invalid OIDC config")
+	ErrMissingUserClaim  = errors.New("token has no user claim")
+)
+
+const oidcJWKSTimeout = 10 * time.Second
+
+// OIDCConfig is the provider and the mapping of its claims
+type OIDCConfig struct {
+	Issuer    string      `json:"issuer"`              // iss of the tokens
+	Audiences []string    `json:"audiences"`           // accepted aud of the tokens, e.g. the client ids of the apps
+	JWKSURL   string      `json:"jwks_url,omitempty"`  // the JWKS of the provider, or
+	JWKSFile  string      `json:"jwks_file,omitempty"` // a local JWKS file, e.g. of a stand-in provider in tests
+	Mapping   OIDCMapping `json:"mapping"`
+}
+
+// OIDCMapping maps the claims of external tokens to Claims; claim names are
+// dot separated paths to nested claims, e.g. "realm_access.roles".
+type OIDCMapping struct {
+	UserClaim    string               `json:"user_claim,omitempty"`    // the user; "sub" by default
+	TenantsClaim string               `json:"tenants_claim,omitempty"` // tenants, as a string or a list
+	RolesClaim   string               `json:"roles_claim,omitempty"`   // roles, as a string or a list
+	GroupsClaim  string               `json:"groups_claim,omitempty"`  // groups; "groups" by default
+	Groups       map[string]OIDCGroup `json:"groups,omitempty"`        // what the groups grant, by name
+	Roles        Roles                `json:"roles,omitempty"`         // granted to every user
+	Additions    []string             `json:"additions,omitempty"`     // claims copied as they are, e.g. "email"
+}
+
+// OIDCGroup is what the members of a group of the provider are granted
+type OIDCGroup struct {
+	Tenants  Tenants        `json:"tenants,omitempty"`
+	Roles    Roles          `json:"roles,omitempty"`
+	Entities RolesPerEntity `json:"entities,omitempty"`
+}
+
+// OIDCProvider verifies the ID and access tokens of an external OpenID
+// Connect identity provider against its JWKS, and maps their claims to Claims
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey // by kid
+	loadedAt time.Time
+	loadErr  error         // of the last load
+	loading  chan struct{} // closed once the load in flight is done; nil when none
+}
+
+// NewOIDCProvider returns a provider verifying tokens with the JWKS of the
+// config; the JWKS is loaded on the first verification.
+func NewOIDCProvider(config OIDCConfig) (*OIDCProvider, error) {
+
+	switch {
+	case config.Issuer == "":
+		return nil, fmt.Errorf("%w: missing issuer", ErrInvalidOIDCConfig)
+	case len(config.Audiences) == 0:
+		return nil, fmt.Errorf("%w: missing audiences", ErrInvalidOIDCConfig)
+	case (config.JWKSURL == "") == (config.JWKSFile == ""):
+		return nil, fmt.Errorf("%w: one of jwks_url and jwks_file is required", ErrInvalidOIDCConfig)
+	}
+
+	if config.Mapping.UserClaim == "" {
+		config.Mapping.UserClaim = "sub"
+	}
+	if config.Mapping.GroupsClaim == "" {
+		config.Mapping.GroupsClaim = "groups"
+	}
+
+	return &OIDCProvider{
+		config: config,
+		client: &http.Client{Timeout: oidcJWKSTimeout},
+	}, nil
+}
+
+// Verify returns the mapped claims of a valid token of the provider, validated now
+func (p *OIDCProvider) Verify(tokenString string) (Claims, error) {
+	return p.VerifyAt(tokenString, time.Now())
+}
+
+// VerifyAt returns the mapped claims of a token of the provider valid at
+// now, within TokenLeeway; errors are those of DecodeTokenAt.
+func (p *OIDCProvider) VerifyAt(tokenString string, now time.Time) (res Claims, err error) {
+
+	token, err := jwt.Parse(tokenString, p.verificationKey,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.Audiences...),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(TokenLeeway),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		err = tokenError(err)
+		return
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims == nil {
+		err = ErrInvalidAuthorizationToken
+		return
+	}
+
+	res, err = p.config.Mapping.claims(claims)
+	if err != nil {
+		return
+	}
+
+	res.Registered, err = registeredClaims(claims)
+
+	return
+}
+
+func (p *OIDCProvider) verificationKey(token *jwt.Token) (any, error) {
+
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	if time.Since(p.loadedAt) >= OIDCKeysRefreshInterval || (!ok && time.Since(p.loadedAt) >= OIDCKeysMinRefreshInterval) {
+		p.startLoad()
+	}
+	loading := p.loading
+	p.mu.Unlock()
+
+	// tokens of known keys go on with them while the keys load; the others
+	// wait for the keys
+	if !ok && loading != nil {
+		<-loading
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys == nil && p.loadErr != nil {
+		return nil, p.loadErr
+	}
+
+	key, ok = p.keys[kid] // the keys loaded last stay in use when loading fails
+	if !ok && kid == "" && len(p.keys) == 1 {
+		for _, key = range p.keys {
+			ok = true // the only key of providers that do not set kid
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownKey, kid)
+	}
+
+	return key, nil
+}
+
+// startLoad loads the keys in the background unless they are already being
+// loaded; p.mu is held.
+func (p *OIDCProvider) startLoad() {
+
+	if p.loading != nil {
+		return
+	}
+
+	p.loadedAt = time.Now()
+	p.loading = make(chan struct{})
+
+	go func(done chan struct{}) {
+		keys, err := p.loadKeys()
+
+		p.mu.Lock()
+		if err == nil {
+			p.keys = keys
+		}
+		p.loadErr = err
+		p.loading = nil
+		p.mu.Unlock()
+
+		close(done)
+	}(p.loading)
+}
+
+func (p *OIDCProvider) loadKeys() (keys map[string]crypto.PublicKey, err error) {
+
+	var data []byte
+	if p.config.JWKSFile != "" {
+		data, err = os.ReadFile(p.config.JWKSFile)
+	} else {
+		data, err = p.fetchJWKS()
+	}
+	if err != nil {
+		return
+	}
+
+	var set JSONWebKeySet
+	err = json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue // encryption keys
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue // keys of unsupported types
+		}
+		keys[jwk.KID] = key
+	}
+
+	return
+}
+
+func (p *OIDCProvider) fetchJWKS() ([]byte, error) {
+
+	res, err := p.client.Get(p.config.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("loading JWKS from '%s' failed with status %d", p.config.JWKSURL, res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// PublicKey returns the public key of the JSON Web Key
+func (jwk JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+
+	b64 := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+
+	case "RSA":
+		n, err := b64(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+		}
+		x, err := b64(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+		}
+		x, err := b64(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", jwk.Kty)
+	}
+}
+
+// claims maps the claims of an external token
+func (m OIDCMapping) claims(external jwt.MapClaims) (res Claims, err error) {
+
+	user, _ := claimValue(external, m.UserClaim).(string)
+	if user == "" {
+		err = fmt.Errorf("%w '%s'", ErrMissingUserClaim, m.UserClaim)
+		return
+	}
+	res.User = User(user)
+
+	res.Roles = append(res.Roles, m.Roles...)
+
+	if m.TenantsClaim != "" {
+		for _, t := range claimStrings(external, m.TenantsClaim) {
+			res.Tenants = appendUnique(res.Tenants, Tenant(t))
+		}
+	}
+
+	if m.RolesClaim != "" {
+		for _, r := range claimStrings(external, m.RolesClaim) {
+			res.Roles = appendUnique(res.Roles, Role(r))
+		}
+	}
+
+	for _, g := range claimStrings(external, m.GroupsClaim) {
+		group, ok := m.Groups[g]
+		if !ok {
+			continue
+		}
+		for _, t := range group.Tenants {
+			res.Tenants = appendUnique(res.Tenants, t)
+		}
+		for _, r := range group.Roles {
+			res.Roles = appendUnique(res.Roles, r)
+		}
+		for entity, roles := range group.Entities {
+			if res.Entities == nil {
+				res.Entities = RolesPerEntity{}
+			}
+			if _, ok := res.Entities[entity]; !ok {
+				res.Entities[entity] = Roles{}
+			}
+			for _, r := range roles {
+				res.Entities[entity] = appendUnique(res.Entities[entity], r)
+			}
+		}
+	}
+
+	for _, a := range m.Additions {
+		if v := claimValue(external, a); v != nil {
+			if res.Additions == nil {
+				res.Additions = map[string]any{}
+			}
+			res.Additions[a] = v
+		}
+	}
+
+	return
+}
+
+// claimValue returns the claim of the dot separated path; nil when missing
+func claimValue(claims map[string]any, path string) any {
+	var v any = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// claimStrings returns the claim of a string or a list of strings
+func claimStrings(claims map[string]any, path string) (res []string) {
+	switch v := claimValue(claims, path).(type) {
+	case string:
+		if v != "" {
+			res = append(res, v)
+		}
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return
+}
+
+func appendUnique[T comparable](list []T, v T) []T {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
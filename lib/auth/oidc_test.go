@@ -0,0 +1,266 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+const (
+	testOIDCIssuer   = "https://id.example.com"
+	testOIDCClientID = "mobile-app"
+)
+
+func rsaJWK(kid string, key *rsa.PublicKey) convAuth.JSONWebKey {
+	b64 := base64.RawURLEncoding.EncodeToString
+	return convAuth.JSONWebKey{KID: kid, Kty: "RSA", Alg: "RS256", Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) convAuth.JSONWebKey {
+	b64 := base64.RawURLEncoding.EncodeToString
+	point, _ := key.Bytes()
+	return convAuth.JSONWebKey{KID: kid, Kty: "EC", Alg: "ES256", Use: "sig", Crv: "P-256", X: b64(point[1:33]), Y: b64(point[33:])}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...convAuth.JSONWebKey) {
+	t.Helper()
+	data, _ := json.Marshal(convAuth.JSONWebKeySet{Keys: keys})
+	err := os.WriteFile(path, data, 0600)
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+// externalToken signs the claims as the identity provider would, valid for
+// an hour unless the claims say otherwise
+func externalToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	now := time.Now()
+	full := jwt.MapClaims{
+		"iss": testOIDCIssuer,
+		"aud": testOIDCClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+	token := jwt.NewWithClaims(method, full)
+	token.Header["kid"] = kid
+	res, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString failed: %v", err)
+	}
+	return res
+}
+
+func TestOIDCProvider(t *testing.T) {
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksFile, rsaJWK("rsa-1", &rsaKey.PublicKey))
+
+	config := convAuth.OIDCConfig{
+		Issuer:    testOIDCIssuer,
+		Audiences: []string{testOIDCClientID},
+		JWKSFile:  jwksFile,
+		Mapping: convAuth.OIDCMapping{
+			UserClaim:    "email",
+			TenantsClaim: "org",
+			RolesClaim:   "realm_access.roles",
+			Groups: map[string]convAuth.OIDCGroup{
+				"admins":  {Roles: convAuth.Roles{"admin"}, Tenants: convAuth.Tenants{"acme"}},
+				"store-1": {Entities: convAuth.RolesPerEntity{"store-1": convAuth.Roles{"manager"}}},
+			},
+			Roles:     convAuth.Roles{"user"},
+			Additions: []string{"name"},
+		},
+	}
+
+	provider, err := convAuth.NewOIDCProvider(config)
+	if err != nil {
+		t.Fatalf("NewOIDCProvider failed: %v", err)
+	}
+
+	t.Run("mapping", func(t *testing.T) {
+		token := externalToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+			"sub":          "0f3c",
+			"email":        "jane@example.com",
+			"name":         "Jane",
+			"org":          "globex",
+			"realm_access": map[string]any{"roles": []any{"reader", "user"}},
+			"groups":       []any{"admins", "store-1", "unmapped"},
+		})
+
+		claims, err := provider.Verify(token)
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+
+		want := convAuth.Claims{
+			User:      "jane@example.com",
+			Tenants:   convAuth.Tenants{"globex", "acme"},
+			Roles:     convAuth.Roles{"user", "reader", "admin"},
+			Entities:  convAuth.RolesPerEntity{"store-1": convAuth.Roles{"manager"}},
+			Additions: map[string]any{"name": "Jane"},
+		}
+		claims.Registered = convAuth.RegisteredClaims{}
+		if !reflect.DeepEqual(claims, want) {
+			t.Fatalf("Verify = %+v; want %+v", claims, want)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		for _, c := range []struct {
+			name   string
+			claims jwt.MapClaims
+			want   error
+		}{
+			{"expired", jwt.MapClaims{"email": "jane@example.com", "exp": time.Now().Add(-time.Hour).Unix()}, convAuth.ErrTokenExpired},
+			{"other_issuer", jwt.MapClaims{"email": "jane@example.com", "iss": "https://evil.example.com"}, convAuth.ErrInvalidIssuer},
+			{"other_audience", jwt.MapClaims{"email": "jane@example.com", "aud": "other-app"}, convAuth.ErrInvalidAudience},
+			{"no_user", jwt.MapClaims{"sub": "0f3c"}, convAuth.ErrMissingUserClaim},
+		} {
+			_, err := provider.Verify(externalToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, c.claims))
+			if !errors.Is(err, c.want) {
+				t.Fatalf("%s: Verify = %v; want %v", c.name, err, c.want)
+			}
+		}
+
+		internal, _ := convAuth.GenerateToken(convAuth.Claims{User: "jane@example.com"})
+		_, err := provider.Verify(internal)
+		if err == nil {
+			t.Fatal("Verify of an internal token = nil; want error")
+		}
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		defer func(interval time.Duration) { convAuth.OIDCKeysMinRefreshInterval = interval }(convAuth.OIDCKeysMinRefreshInterval)
+		convAuth.OIDCKeysMinRefreshInterval = 0 // load the JWKS on every unknown key
+
+		token := externalToken(t, jwt.SigningMethodES256, "ec-2", ecKey, jwt.MapClaims{"email": "jane@example.com"})
+
+		_, err := provider.Verify(token)
+		if !errors.Is(err, convAuth.ErrUnknownKey) {
+			t.Fatalf("Verify with a key not yet published = %v; want ErrUnknownKey", err)
+		}
+
+		writeJWKS(t, jwksFile, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-2", &ecKey.PublicKey))
+
+		_, err = provider.Verify(token)
+		if err != nil {
+			t.Fatalf("Verify with a published key = %v; want nil", err)
+		}
+	})
+
+	t.Run("jwks_url", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(convAuth.JSONWebKeySet{Keys: []convAuth.JSONWebKey{ecJWK("ec-2", &ecKey.PublicKey)}})
+		}))
+		defer srv.Close()
+
+		byURL := config
+		byURL.JWKSFile = ""
+		byURL.JWKSURL = srv.URL
+
+		provider, err := convAuth.NewOIDCProvider(byURL)
+		if err != nil {
+			t.Fatalf("NewOIDCProvider failed: %v", err)
+		}
+
+		_, err = provider.Verify(externalToken(t, jwt.SigningMethodES256, "ec-2", ecKey, jwt.MapClaims{"email": "jane@example.com"}))
+		if err != nil {
+			t.Fatalf("Verify = %v; want nil", err)
+		}
+	})
+
+	t.Run("slow_refresh", func(t *testing.T) {
+		defer func(interval time.Duration) { convAuth.OIDCKeysRefreshInterval = interval }(convAuth.OIDCKeysRefreshInterval)
+
+		var fetches atomic.Int32
+		unblock := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if fetches.Add(1) > 1 {
+				<-unblock // the refreshes hang
+			}
+			json.NewEncoder(w).Encode(convAuth.JSONWebKeySet{Keys: []convAuth.JSONWebKey{ecJWK("ec-2", &ecKey.PublicKey)}})
+		}))
+		defer srv.Close()
+		defer close(unblock)
+
+		byURL := config
+		byURL.JWKSFile = ""
+		byURL.JWKSURL = srv.URL
+
+		provider, err := convAuth.NewOIDCProvider(byURL)
+		if err != nil {
+			t.Fatalf("NewOIDCProvider failed: %v", err)
+		}
+
+		token := externalToken(t, jwt.SigningMethodES256, "ec-2", ecKey, jwt.MapClaims{"email": "jane@example.com"})
+
+		_, err = provider.Verify(token)
+		if err != nil {
+			t.Fatalf("Verify = %v; want nil", err)
+		}
+
+		convAuth.OIDCKeysRefreshInterval = 0 // refresh on every verification
+
+		done := make(chan error, 1)
+		go func() {
+			for range 5 {
+				if _, err := provider.Verify(token); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Verify during a refresh = %v; want nil", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Verify waits for the refresh of the keys")
+		}
+
+		if n := fetches.Load(); n > 2 {
+			t.Fatalf("JWKS fetches = %d; want at most 2 (one refresh in flight)", n)
+		}
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		both := config
+		both.JWKSURL = "https://id.example.com/jwks"
+		noAudience := config
+		noAudience.Audiences = nil
+
+		for _, c := range []convAuth.OIDCConfig{both, noAudience, {JWKSFile: jwksFile}} {
+			_, err := convAuth.NewOIDCProvider(c)
+			if !errors.Is(err, convAuth.ErrInvalidOIDCConfig) {
+				t.Fatalf("NewOIDCProvider(%+v) = %v; want ErrInvalidOIDCConfig", c, err)
+			}
+		}
+	})
+}
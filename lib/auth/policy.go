@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	convCfg "github.com/sofmon/convention/lib/cfg"
+)
+
+var (
+	// PolicyReloadInterval is how often a watched policy is read again from the config
+	PolicyReloadInterval = 10 * time.Second
+
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// Validate returns ErrInvalidPolicy for policies with invalid actions, with
+// roles of undefined permissions, or granting nothing at all.
+func (policy Policy) Validate() error {
+	_, err := validatePolicy(policy)
+	return err
+}
+
+func validatePolicy(policy Policy) (*expandedPolicy, error) {
+
+	if len(policy.Roles) == 0 && len(policy.Public) == 0 {
+		return nil, fmt.Errorf("%w: no roles and no public actions", ErrInvalidPolicy)
+	}
+
+	for role, permissions := range policy.Roles {
+		for _, permission := range permissions {
+			if _, ok := policy.Permissions[permission]; !ok {
+				return nil, fmt.Errorf("%w: role '%s' has undefined permission '%s'", ErrInvalidPolicy, role, permission)
+			}
+		}
+	}
+
+	ep, err := expandPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+
+	return ep, nil
+}
+
+// ReloadablePolicy is a policy of the config that is replaced by its new
+// versions while requests are checked against it; requests are checked
+// against either the previous or the new version, never a mix of both.
+type ReloadablePolicy struct {
+	key convCfg.ConfigKey
+
+	mu      sync.Mutex // serialises reloads
+	raw     []byte     // of the current policy
+	current atomic.Pointer[loadedPolicy]
+}
+
+type loadedPolicy struct {
+	policy   Policy
+	expanded *expandedPolicy
+}
+
+// LoadPolicy reads and validates the policy of the config key; it is read
+// again on Reload, and periodically by Watch.
+func LoadPolicy(key convCfg.ConfigKey) (*ReloadablePolicy, error) {
+
+	rp := &ReloadablePolicy{key: key}
+
+	_, err := rp.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Reload reads the policy again from the config. A changed valid policy
+// replaces the current one; an unreadable or invalid one is rejected and the
+// current one kept.
+func (rp *ReloadablePolicy) Reload() (changed bool, err error) {
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	raw, err := convCfg.Bytes(rp.key)
+	if err != nil {
+		return
+	}
+
+	if rp.current.Load() != nil && bytes.Equal(raw, rp.raw) {
+		return
+	}
+
+	var policy Policy
+	err = json.Unmarshal(raw, &policy)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+		return
+	}
+
+	expanded, err := validatePolicy(policy)
+	if err != nil {
+		return
+	}
+
+	rp.raw = raw
+	rp.current.Store(&loadedPolicy{policy, expanded})
+
+	return true, nil
+}
+
+// Policy returns the current policy
+func (rp *ReloadablePolicy) Policy() Policy {
+	return rp.current.Load().policy
+}
+
+// Check returns the check of requests against the current policy; with
+// audiences, tokens bound to other agents are rejected as by NewCheck.
+func (rp *ReloadablePolicy) Check(audiences ...string) Check {
+	return func(r *http.Request) (Target, error) {
+		return rp.current.Load().expanded.check(r, audiences)
+	}
+}
+
+// Watch reloads the policy every PolicyReloadInterval until the context is
+// done; reloads and rejected versions are logged:
+//
+//	policy, err := convAuth.LoadPolicy("policy")
+//	// ...
+//	go policy.Watch(ctx, ctx.Logger())
+func (rp *ReloadablePolicy) Watch(ctx context.Context, logger *slog.Logger) {
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ticker := time.NewTicker(PolicyReloadInterval)
+	defer ticker.Stop()
+
+	rejected := "" // logged once per rejected version
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := rp.Reload()
+		switch {
+		case err != nil:
+			if err.Error() != rejected {
+				logger.Error("policy reload rejected, keeping the previous policy", "key", string(rp.key), "error", err.Error())
+			}
+			rejected = err.Error()
+		case changed:
+			rejected = ""
+			logger.Info("policy reloaded", "key", string(rp.key))
+		default:
+			rejected = ""
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+func policyGranting(action convAuth.Action) convAuth.Policy {
+	return convAuth.Policy{
+		Roles:       convAuth.RolePermissions{"reader": convAuth.Permissions{"read"}},
+		Permissions: convAuth.PermissionActions{"read": convAuth.Actions{action}},
+	}
+}
+
+func TestReloadablePolicy(t *testing.T) {
+
+	dir := useConfig(t, map[string]any{
+		"communication_secret": []byte("policy-test-secret"),
+		"policy":               policyGranting("GET /orders/{any}"),
+	})
+
+	writePolicy := func(t *testing.T, content any) {
+		t.Helper()
+		data, ok := content.([]byte)
+		if !ok {
+			data, _ = json.Marshal(content)
+		}
+		err := os.WriteFile(filepath.Join(dir, "policy"), data, 0600)
+		if err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	allowed := func(t *testing.T, check convAuth.Check, path string) bool {
+		t.Helper()
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}, Header: http.Header{}}
+		err := convAuth.EncodeHTTPRequestClaims(req, convAuth.Claims{User: "jane", Roles: convAuth.Roles{"reader"}})
+		if err != nil {
+			t.Fatalf("EncodeHTTPRequestClaims failed: %v", err)
+		}
+		_, err = check(req)
+		return err == nil
+	}
+
+	policy, err := convAuth.LoadPolicy("policy")
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+
+	check := policy.Check()
+
+	if !allowed(t, check, "/orders/1") || allowed(t, check, "/invoices/1") {
+		t.Fatal("check does not follow the loaded policy")
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		changed, err := policy.Reload()
+		if err != nil || changed {
+			t.Fatalf("Reload of the same policy = %v, %v; want false, nil", changed, err)
+		}
+
+		writePolicy(t, policyGranting("GET /invoices/{any}"))
+
+		changed, err = policy.Reload()
+		if err != nil || !changed {
+			t.Fatalf("Reload of a new policy = %v, %v; want true, nil", changed, err)
+		}
+
+		if allowed(t, check, "/orders/1") || !allowed(t, check, "/invoices/1") {
+			t.Fatal("check does not follow the reloaded policy")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		undefined := policyGranting("GET /orders/{any}")
+		undefined.Roles["reader"] = append(undefined.Roles["reader"], "write")
+
+		for name, content := range map[string]any{
+			"malformed":            []byte(`{"roles": {"reader": [`),
+			"empty":                convAuth.Policy{},
+			"undefined_permission": undefined,
+			"invalid_action":       policyGranting("/orders"),
+		} {
+			writePolicy(t, content)
+
+			changed, err := policy.Reload()
+			if !errors.Is(err, convAuth.ErrInvalidPolicy) || changed {
+				t.Fatalf("%s: Reload = %v, %v; want false, ErrInvalidPolicy", name, changed, err)
+			}
+			if !allowed(t, check, "/invoices/1") {
+				t.Fatalf("%s: the previous policy is not kept", name)
+			}
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		defer func(interval time.Duration) { convAuth.PolicyReloadInterval = interval }(convAuth.PolicyReloadInterval)
+		convAuth.PolicyReloadInterval = 5 * time.Millisecond
+
+		writePolicy(t, policyGranting("GET /orders/{any}"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		watched, err := convAuth.LoadPolicy("policy")
+		if err != nil {
+			t.Fatalf("LoadPolicy failed: %v", err)
+		}
+		check := watched.Check()
+
+		watching := make(chan struct{})
+		go func() {
+			defer close(watching)
+			watched.Watch(ctx, nil)
+		}()
+
+		// requests checked while the policy is replaced are never dropped
+		var wg sync.WaitGroup
+		failed := make(chan string, 1)
+		stop := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if !allowed(t, check, "/orders/1") && !allowed(t, check, "/shipments/1") {
+					select {
+					case failed <- "a request was checked against no policy":
+					default:
+					}
+				}
+			}
+		}()
+
+		writePolicy(t, policyGranting("GET /shipments/{any}"))
+
+		deadline := time.Now().Add(time.Second)
+		for !allowed(t, check, "/shipments/1") {
+			if time.Now().After(deadline) {
+				t.Fatal("the watched policy was not reloaded")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		close(stop)
+		wg.Wait()
+
+		cancel()
+		<-watching // stopped before the config is restored
+
+		select {
+		case msg := <-failed:
+			t.Fatal(msg)
+		default:
+		}
+
+		if watched.Policy().Permissions["read"][0] != "GET /shipments/{any}" {
+			t.Fatalf("Policy = %+v; want the reloaded policy", watched.Policy())
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// RevocationCheck reports whether the token of the decoded claims is revoked
+type RevocationCheck func(claims Claims) bool
+
+var (
+	ErrTokenRevoked = errors.New("bearer token is revoked")
+
+	revocationCheck atomic.Pointer[RevocationCheck]
+)
+
+// SetRevocationCheck makes DecodeToken, and so DecodeHTTPRequestClaims and
+// Check, reject the tokens the check reports as revoked with ErrTokenRevoked;
+// nil accepts all tokens again. It is set by the revocation package and must
+// not block, as it runs for every decoded token.
+func SetRevocationCheck(check RevocationCheck) {
+	if check == nil {
+		revocationCheck.Store(nil)
+		return
+	}
+	revocationCheck.Store(&check)
+}
+
+func isRevoked(claims Claims) bool {
+	check := revocationCheck.Load()
+	return check != nil && (*check)(claims)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ServiceTokenTTL is the lifetime of the tokens minted for agent calls
+	ServiceTokenTTL = 5 * time.Minute
+
+	// RequireAudience rejects tokens without aud wherever audiences are
+	// checked; clear it only while callers still send tokens without aud.
+	RequireAudience = true
+
+	ErrInvalidAudience = errors.New("HTTP request bearer token is bound to another audience")
+)
+
+// GenerateServiceToken signs the claims as a token for the audience (the
+// called agent) that expires after ttl.
+func GenerateServiceToken(claims Claims, audience string, ttl time.Duration) (token string, expiresAt time.Time, err error) {
+	return generateToken(claims, TokenOptions{Audience: []string{audience}, TTL: ttl})
+}
+
+// EncodeHTTPRequestServiceClaims sets a token of the claims for the audience
+// (the called agent) as request authorization. Tokens are reused for the same
+// claims and audience until a fifth of their lifetime is left.
+func EncodeHTTPRequestServiceClaims(r *http.Request, claims Claims, audience string) error {
+
+	token, err := serviceTokens.get(claims, audience)
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set(HttpHeaderAuthorization, "Bearer "+token)
+
+	return nil
+}
+
+var serviceTokens = &serviceTokenCache{tokens: map[string]serviceToken{}}
+
+const serviceTokenCacheSweepAt = 1024
+
+type serviceToken struct {
+	token     string
+	refreshAt time.Time
+	expiresAt time.Time
+}
+
+type serviceTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]serviceToken
+}
+
+func (c *serviceTokenCache) get(claims Claims, audience string) (token string, err error) {
+
+	raw, err := json.Marshal(claims) // map keys are sorted, so equal claims give equal keys
+	if err != nil {
+		return
+	}
+	key := audience + "\x00" + string(raw)
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if st, ok := c.tokens[key]; ok && now.Before(st.refreshAt) {
+		return st.token, nil
+	}
+
+	ttl := ServiceTokenTTL
+	token, expiresAt, err := GenerateServiceToken(claims, audience, ttl)
+	if err != nil {
+		return
+	}
+
+	if len(c.tokens) >= serviceTokenCacheSweepAt {
+		for k, st := range c.tokens {
+			if now.After(st.expiresAt) {
+				delete(c.tokens, k)
+			}
+		}
+	}
+
+	c.tokens[key] = serviceToken{
+		token:     token,
+		refreshAt: expiresAt.Add(-ttl / 5),
+		expiresAt: expiresAt,
+	}
+
+	return
+}
+
+// verifyAudience accepts tokens bound to any of the audiences, and tokens
+// without audience unless RequireAudience; all tokens when there are no
+// audiences.
+func verifyAudience(claims jwt.MapClaims, audiences []string) error {
+
+	if len(audiences) == 0 {
+		return nil
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return ErrInvalidAuthorizationToken
+	}
+
+	if len(aud) == 0 {
+		if RequireAudience {
+			return ErrInvalidAudience
+		}
+		return nil
+	}
+
+	for _, a := range aud {
+		if slices.Contains(audiences, a) {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
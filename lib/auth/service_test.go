@@ -0,0 +1,112 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+func TestServiceToken(t *testing.T) {
+
+	claims := convAuth.Claims{User: "agent-v1", Roles: convAuth.Roles{"agent"}}
+
+	token, expiresAt, err := convAuth.GenerateServiceToken(claims, "orders", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken failed: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 0 || d > time.Minute {
+		t.Fatalf("token expires in %v; want within a minute", d)
+	}
+
+	res, err := convAuth.DecodeToken(token, "orders")
+	if err != nil || res.User != claims.User {
+		t.Fatalf("DecodeToken for its audience = %v, %v; want the claims", res, err)
+	}
+
+	_, err = convAuth.DecodeToken(token, "payments")
+	if !errors.Is(err, convAuth.ErrInvalidAudience) {
+		t.Fatalf("DecodeToken for another audience = %v; want ErrInvalidAudience", err)
+	}
+
+	_, err = convAuth.DecodeToken(token)
+	if err != nil {
+		t.Fatalf("DecodeToken without audience = %v; want nil", err)
+	}
+
+	expired, _, err := convAuth.GenerateServiceToken(claims, "orders", -time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken failed: %v", err)
+	}
+	_, err = convAuth.DecodeToken(expired, "orders")
+	if err == nil {
+		t.Fatal("DecodeToken of an expired token = nil; want error")
+	}
+
+	// tokens without audience are rejected where audiences are checked
+	legacy, err := convAuth.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	_, err = convAuth.DecodeToken(legacy, "orders")
+	if err != convAuth.ErrInvalidAudience {
+		t.Fatalf("DecodeToken of a token without audience = %v; want ErrInvalidAudience", err)
+	}
+
+	// unless audiences are not required, while migrating
+	defer func(require bool) { convAuth.RequireAudience = require }(convAuth.RequireAudience)
+	convAuth.RequireAudience = false
+
+	_, err = convAuth.DecodeToken(legacy, "orders")
+	if err != nil {
+		t.Fatalf("DecodeToken of a token without audience = %v; want nil when not required", err)
+	}
+	_, err = convAuth.DecodeToken(token, "orders")
+	if err != nil {
+		t.Fatalf("DecodeToken of a token for the agent = %v; want nil when not required", err)
+	}
+}
+
+func TestServiceTokenCheck(t *testing.T) {
+
+	check, err := convAuth.NewCheck(convAuth.Policy{
+		Roles:       convAuth.RolePermissions{"agent": convAuth.Permissions{"call"}},
+		Permissions: convAuth.PermissionActions{"call": convAuth.Actions{"GET /orders"}},
+	}, "orders")
+	if err != nil {
+		t.Fatalf("NewCheck failed: %v", err)
+	}
+
+	claims := convAuth.Claims{User: "agent-v1", Roles: convAuth.Roles{"agent"}}
+
+	newRequest := func(audience string) *http.Request {
+		req := &http.Request{Method: "GET", URL: &url.URL{Path: "/orders"}, Header: http.Header{}}
+		err := convAuth.EncodeHTTPRequestServiceClaims(req, claims, audience)
+		if err != nil {
+			t.Fatalf("EncodeHTTPRequestServiceClaims failed: %v", err)
+		}
+		return req
+	}
+
+	_, err = check(newRequest("orders"))
+	if err != nil {
+		t.Fatalf("check of a token for the agent = %v; want nil", err)
+	}
+
+	_, err = check(newRequest("payments"))
+	if err != convAuth.ErrInvalidAudience {
+		t.Fatalf("check of a token for another agent = %v; want ErrInvalidAudience", err)
+	}
+
+	// minted tokens are reused per claims and audience
+	first, second := newRequest("orders"), newRequest("orders")
+	if first.Header.Get(convAuth.HttpHeaderAuthorization) != second.Header.Get(convAuth.HttpHeaderAuthorization) {
+		t.Fatal("tokens for the same claims and audience differ; want the cached token")
+	}
+	if first.Header.Get(convAuth.HttpHeaderAuthorization) == newRequest("payments").Header.Get(convAuth.HttpHeaderAuthorization) {
+		t.Fatal("tokens for different audiences are equal; want one per audience")
+	}
+}
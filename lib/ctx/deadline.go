@@ -0,0 +1,27 @@
+package ctx
+
+import (
+	"context"
+	"time"
+)
+
+func (ctx Context) WithTimeout(timeout time.Duration) (Context, context.CancelFunc) {
+	c, cancel := context.WithTimeout(ctx.Context, timeout)
+	return Context{c}, cancel
+}
+
+func (ctx Context) WithDeadline(deadline time.Time) (Context, context.CancelFunc) {
+	c, cancel := context.WithDeadline(ctx.Context, deadline)
+	return Context{c}, cancel
+}
+
+// TimeBudget returns the time left until the deadline of the context;
+// ok is false when the context has no deadline.
+func (ctx Context) TimeBudget() (budget time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	budget = time.Until(deadline)
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	convAuth "github.com/sofmon/convention/lib/auth"
@@ -15,9 +16,12 @@ const (
 	HttpHeaderAuthorization = convAuth.HttpHeaderAuthorization
 	HttpHeaderWorkflow      = "Workflow"
 	HTTPHeaderTimeNow       = "Time-Now"
+	HttpHeaderTimeBudget    = "Time-Budget" // milliseconds left to the caller
 )
 
-func (ctx Context) WithRequest(r *http.Request, decodeClaims bool) (res Context) {
+// WithRequest returns a context of the request; with audiences, claims of
+// tokens bound to other audiences are not decoded.
+func (ctx Context) WithRequest(r *http.Request, decodeClaims bool, audiences ...string) (res Context) {
 
 	res = Context{
 		context.WithValue(
@@ -34,8 +38,21 @@ func (ctx Context) WithRequest(r *http.Request, decodeClaims bool) (res Context)
 	action := convAuth.Action(fmt.Sprintf("%s %s", r.Method, r.URL.Path))
 	res = res.WithAction(action)
 
+	if !ctx.IsProdEnv() && r != nil {
+		nowStr := r.Header.Get(HTTPHeaderTimeNow)
+		if nowStr != "" {
+			now, err := time.Parse(time.RFC3339, nowStr)
+			if err != nil {
+				ctx.Logger().Warn("failed to parse '"+HTTPHeaderTimeNow+"' header", "error", err.Error())
+			} else {
+				res = res.WithNow(now.UTC())
+			}
+		}
+	}
+
 	if decodeClaims {
-		if claims, err := convAuth.DecodeHTTPRequestClaims(r); err == nil {
+		// tokens are valid at the time of the agent, so they expire on time travel
+		if claims, err := convAuth.DecodeHTTPRequestClaimsAt(r, res.Now(), audiences...); err == nil {
 			res = res.WithClaims(claims)
 		} else {
 			if err != convAuth.ErrMissingAuthorizationHeader {
@@ -48,15 +65,14 @@ func (ctx Context) WithRequest(r *http.Request, decodeClaims bool) (res Context)
 		}
 	}
 
-	if !ctx.IsProdEnv() && r != nil {
-		nowStr := r.Header.Get(HTTPHeaderTimeNow)
-		if nowStr != "" {
-			now, err := time.Parse(time.RFC3339, nowStr)
-			if err != nil {
-				ctx.Logger().Warn("failed to parse '"+HTTPHeaderTimeNow+"' header", "error", err.Error())
-			} else {
-				res = res.WithNow(now.UTC())
-			}
+	if budgetStr := r.Header.Get(HttpHeaderTimeBudget); budgetStr != "" {
+		ms, err := strconv.ParseInt(budgetStr, 10, 64)
+		if err != nil || ms < 0 {
+			ctx.Logger().Warn("failed to parse '"+HttpHeaderTimeBudget+"' header", "value", budgetStr)
+		} else {
+			var cancel context.CancelFunc
+			res, cancel = res.WithTimeout(time.Duration(ms) * time.Millisecond)
+			context.AfterFunc(r.Context(), cancel) // release the timer once the request is done
 		}
 	}
 
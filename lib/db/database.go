@@ -224,28 +224,42 @@ func dbByShardKeyWithEngine(vault Vault, tenant convAuth.Tenant, key string) (*s
 
 func dbsByShardKeys(vault Vault, tenant convAuth.Tenant, keys ...string) ([]*sql.DB, error) {
 
-	dbs, err := DBs(vault, tenant)
+	entries, err := engineDBsByShardKeys(vault, tenant, keys...)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(dbs) <= 0 {
+	res := make([]*sql.DB, len(entries))
+	for i, e := range entries {
+		res[i] = e.db
+	}
+	return res, nil
+}
+
+func engineDBsByShardKeys(vault Vault, tenant convAuth.Tenant, keys ...string) ([]engineDB, error) {
+
+	entries, err := engineDBs(vault, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) <= 0 {
 		return nil, ErrNoDBTenant
 	}
 
 	if len(keys) == 0 {
-		return dbs, nil
+		return entries, nil
 	}
 
 	sis := map[int]any{}
 
 	for _, key := range keys {
-		sis[indexByShardKey(key, len(dbs))] = nil
+		sis[indexByShardKey(key, len(entries))] = nil
 	}
 
-	var res []*sql.DB
+	var res []engineDB
 	for si := range sis {
-		res = append(res, dbs[si])
+		res = append(res, entries[si])
 	}
 
 	return res, nil
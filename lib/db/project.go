@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// SelectProjected selects the objects matching where (all objects when where
+// is nil), loading only the fields (JSON paths such as "name" or
+// "address.city") from the database; the other fields are left zero. Paths
+// into lists, maps and types with custom JSON encoding load the whole list,
+// map or value. Compute functions run on the projected objects. No fields
+// select the whole objects.
+func (tos TenantObjectSet[objT, idT, shardKeyT]) SelectProjected(ctx convCtx.Context, fields []string, where whereReady, shardKeys ...shardKeyT) (obs []objT, err error) {
+
+	err = tos.prepare()
+	if err != nil {
+		return
+	}
+
+	tree, err := newProjectionTree(tos.objType, fields)
+	if err != nil {
+		return
+	}
+
+	keys := make([]string, len(shardKeys))
+	for i, sk := range shardKeys {
+		keys[i] = string(sk)
+	}
+
+	dbs, err := engineDBsByShardKeys(tos.vault, tos.tenant, keys...)
+	if err != nil {
+		return
+	}
+
+	var (
+		statement string
+		params    []any
+	)
+	if where != nil {
+		statement, params, err = where.statement()
+		if err != nil {
+			err = fmt.Errorf("error building where statement: %w", err)
+			return
+		}
+		statement = ` WHERE ` + statement
+	}
+
+	for _, db := range dbs {
+
+		var rows *sql.Rows
+		rows, err = db.db.Query(`SELECT `+tree.expression(db.engine, `"object"`)+`, "created_at", "created_by", "updated_at", "updated_by" FROM "`+tos.table.RuntimeTableName+`"`+statement, params...)
+		if err == sql.ErrNoRows {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		for rows.Next() {
+
+			var (
+				bytes []byte
+				obj   objT
+				md    Metadata
+			)
+
+			err = rows.Scan(&bytes, &md.CreatedAt, &md.CreatedBy, &md.UpdatedAt, &md.UpdatedBy)
+			if err != nil {
+				return
+			}
+
+			err = json.Unmarshal(bytes, &obj)
+			if err != nil {
+				return
+			}
+
+			for _, compute := range tos.compute {
+				err = compute(ctx, md, &obj)
+				if err != nil {
+					return
+				}
+			}
+
+			obs = append(obs, obj)
+		}
+
+	}
+
+	return
+}
+
+// projectionTree is the JSON fields to load of an object; nil for all
+type projectionTree map[string]projectionTree
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[interface{ MarshalText() ([]byte, error) }]()
+)
+
+func newProjectionTree(t reflect.Type, fields []string) (tree projectionTree, err error) {
+
+	for _, field := range fields {
+
+		if tree == nil {
+			tree = projectionTree{}
+		}
+
+		node, typ := tree, t
+		for path := strings.Split(field, "."); len(path) > 0; path = path[1:] {
+
+			for typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+
+			name := path[0]
+
+			if typ.Kind() != reflect.Struct || (typ != t && (typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType))) {
+				break // loaded as a whole
+			}
+
+			ft, ok := jsonFieldType(typ, name)
+			if !ok {
+				return nil, fmt.Errorf("unknown field '%s' of %s", field, t.Name())
+			}
+
+			sub, loaded := node[name]
+			if loaded && sub == nil {
+				break // already loaded as a whole
+			}
+			if len(path) == 1 {
+				node[name] = nil
+				break
+			}
+			if sub == nil {
+				sub = projectionTree{}
+				node[name] = sub
+			}
+
+			node, typ = sub, ft
+		}
+	}
+
+	return
+}
+
+// jsonFieldType returns the type of the field with the JSON name
+func jsonFieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if sub, ok := jsonFieldType(ft, name); ok {
+					return sub, true
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(tag, ",")
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+		if jsonName == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// expression builds the JSON object of the fields from the column
+func (tree projectionTree) expression(engine Engine, column string) string {
+
+	if tree == nil {
+		return column
+	}
+
+	build := "json_object"
+	if engine == EnginePostgres {
+		build = "jsonb_build_object"
+	}
+
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	args := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		literal := `'` + strings.ReplaceAll(name, `'`, `''`) + `'`
+		args = append(args, literal, tree[name].expression(engine, column+`->`+literal))
+	}
+
+	return build + `(` + strings.Join(args, `, `) + `)`
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
 	convAuth "github.com/sofmon/convention/lib/auth"
 	convCtx "github.com/sofmon/convention/lib/ctx"
 	convDB "github.com/sofmon/convention/lib/db"
@@ -246,3 +248,55 @@ func Test_order_limit_offset(t *testing.T) {
 	}
 
 }
+
+func Test_select_projected(t *testing.T) {
+
+	ctx := convCtx.New(convAuth.Claims{User: "Test_select_projected"})
+
+	obj := ComplexObject{
+		ComplexID: ComplexID(uuid.NewString()),
+		Title:     "title",
+		Nested:    ComplexNested{Label: "label", Count: 3},
+		Tags:      []string{"a", "b"},
+		Attrs:     map[string]string{"k": "v"},
+	}
+
+	err := complexDB.Tenant("test").Insert(ctx, obj)
+	if err != nil {
+		t.Fatalf("Insert() = %v; want nil", err)
+	}
+	defer complexDB.Tenant("test").Delete(ctx, obj.ComplexID)
+
+	where := convDB.Where().Key("complex_id").Equals().Value(obj.ComplexID)
+
+	t.Run("fields", func(t *testing.T) {
+		obs, err := complexDB.Tenant("test").SelectProjected(ctx, []string{"complex_id", "nested.count", "tags"}, where)
+		if err != nil {
+			t.Fatalf("SelectProjected() = %v; want nil", err)
+		}
+		if len(obs) != 1 {
+			t.Fatalf("SelectProjected() = %d objects; want 1", len(obs))
+		}
+		got := obs[0]
+		if got.ComplexID != obj.ComplexID || got.Nested.Count != 3 || len(got.Tags) != 2 {
+			t.Fatalf("SelectProjected() = %+v; want id, nested.count and tags loaded", got)
+		}
+		if got.Title != "" || got.Nested.Label != "" || got.Attrs != nil {
+			t.Fatalf("SelectProjected() = %+v; want other fields left zero", got)
+		}
+	})
+
+	t.Run("whole_objects", func(t *testing.T) {
+		obs, err := complexDB.Tenant("test").SelectProjected(ctx, nil, where)
+		if err != nil || len(obs) != 1 || obs[0].Title != "title" || obs[0].Attrs["k"] != "v" {
+			t.Fatalf("SelectProjected(nil) = %+v, %v; want the whole object", obs, err)
+		}
+	})
+
+	t.Run("unknown_field", func(t *testing.T) {
+		_, err := complexDB.Tenant("test").SelectProjected(ctx, []string{"nested.missing"}, where)
+		if err == nil {
+			t.Fatal("SelectProjected() of an unknown field = nil; want error")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package revocation
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// RevokeRequest is the body of the revoke endpoints
+type RevokeRequest struct {
+	Reason       string    `json:"reason,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`    // exp of the revoked token; kept for good when empty
+	IssuedBefore time.Time `json:"issued_before,omitempty"` // of user revocations; now when empty
+}
+
+// API revokes tokens and lists the revocations. Serve it on its own or embed
+// it in the API struct of the agent.
+type API struct {
+	ListRevocations convAPI.Out[[]Revocation]                                 `api:"GET /revocations/v1/revocations"`
+	RevokeToken     convAPI.InOutP1[RevokeRequest, Revocation, TokenID]       `api:"POST /revocations/v1/tokens/{token}"`
+	RevokeUser      convAPI.InOutP1[RevokeRequest, Revocation, convAuth.User] `api:"POST /revocations/v1/users/{user}"`
+}
+
+func NewAPI() *API {
+	return &API{
+		ListRevocations: convAPI.NewOut(func(ctx convCtx.Context) ([]Revocation, error) {
+			res, err := Revocations(ctx)
+			return res, apiError(ctx, err)
+		}),
+		RevokeToken: convAPI.NewInOutP1(func(ctx convCtx.Context, token TokenID, req RevokeRequest) (Revocation, error) {
+			res, err := RevokeToken(ctx, token, req.ExpiresAt, req.Reason)
+			return res, apiError(ctx, err)
+		}),
+		RevokeUser: convAPI.NewInOutP1(func(ctx convCtx.Context, user convAuth.User, req RevokeRequest) (Revocation, error) {
+			issuedBefore := req.IssuedBefore
+			if issuedBefore.IsZero() {
+				issuedBefore = time.Now()
+			}
+			res, err := RevokeUser(ctx, user, issuedBefore, req.Reason)
+			return res, apiError(ctx, err)
+		}),
+	}
+}
+
+func apiError(ctx convCtx.Context, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrInvalidRevocation):
+		return convAPI.NewError(ctx, http.StatusBadRequest, convAPI.ErrorCodeBadRequest, err.Error(), err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,26 @@
+package revocation
+
+import (
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+)
+
+// Test seams (compiled only into the package's test binary).
+
+// SetSyncIntervalForTest shortens the sync interval for tests and returns a
+// restore func; it applies from the next sync on.
+func SetSyncIntervalForTest(interval time.Duration) (restore func()) {
+	o := syncInterval
+	syncInterval = interval
+	return func() { syncInterval = o }
+}
+
+// ForgetForTest empties the cached revocations, as on an instance that has
+// not synced them yet.
+func ForgetForTest() {
+	cacheMut.Lock()
+	defer cacheMut.Unlock()
+	tokens = map[TokenID]struct{}{}
+	users = map[convAuth.User]time.Time{}
+}
@@ -0,0 +1,281 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+)
+
+type RevocationID string
+
+// TokenID is the jti of a token
+type TokenID string
+
+// Revocation revokes a token by its jti, or all tokens of a user issued
+// before a time.
+type Revocation struct {
+	ID           RevocationID  `json:"id"`
+	Token        TokenID       `json:"token,omitempty"`
+	ExpiresAt    time.Time     `json:"expires_at,omitempty"` // of the token; the revocation is dropped once it expired
+	User         convAuth.User `json:"user,omitempty"`
+	IssuedBefore time.Time     `json:"issued_before,omitempty"` // of the user's tokens
+	Reason       string        `json:"reason,omitempty"`
+	RevokedBy    convAuth.User `json:"revoked_by"`
+	RevokedAt    time.Time     `json:"revoked_at"`
+}
+
+func (x Revocation) DBKey() convDB.Key[RevocationID, RevocationID] {
+	return convDB.Key[RevocationID, RevocationID]{
+		ID:       x.ID,
+		ShardKey: x.ID,
+	}
+}
+
+// expired reports whether the revoked token expired, so the revocation is no
+// longer needed; tokens are accepted within convAuth.TokenLeeway after exp.
+func (x Revocation) expired(now time.Time) bool {
+	return x.Token != "" && !x.ExpiresAt.IsZero() && now.After(x.ExpiresAt.Add(convAuth.TokenLeeway))
+}
+
+func tokenRevocationID(token TokenID) RevocationID {
+	return RevocationID("token:" + token)
+}
+
+func userRevocationID(user convAuth.User) RevocationID {
+	return RevocationID("user:" + user)
+}
+
+var (
+	ErrNotInitialised     = errors.New("revocations are not initialised - call Initialise first")
+	ErrAlreadyInitialised = errors.New("revocations are already initialised")
+	ErrInvalidRevocation  = errors.New("invalid revocation")
+)
+
+// syncInterval is how often the revocations are read again from the vault;
+// a package var (not a const) so tests can shorten it.
+var syncInterval = 30 * time.Second
+
+var (
+	mut           sync.Mutex
+	cancel        context.CancelFunc
+	revocationsDB convDB.TenantObjectSet[Revocation, RevocationID, RevocationID]
+
+	cacheMut sync.RWMutex
+	tokens   map[TokenID]struct{}
+	users    map[convAuth.User]time.Time // issued before
+)
+
+// Initialise stores the revocations in the tenant of the vault, reads them
+// and keeps reading them every sync interval; convAuth rejects the revoked
+// tokens from then on, until Cancel.
+func Initialise(ctx convCtx.Context, vault convDB.Vault, tenant convAuth.Tenant) (err error) {
+	mut.Lock()
+	defer mut.Unlock()
+
+	if cancel != nil {
+		return ErrAlreadyInitialised
+	}
+
+	revocationsDB = convDB.NewObjectSet[Revocation](vault).Ready().Tenant(tenant)
+
+	_, err = syncRevocations(ctx)
+	if err != nil {
+		return
+	}
+
+	ctx.Context, cancel = context.WithCancel(ctx.Context)
+
+	go background(ctx)
+
+	convAuth.SetRevocationCheck(isRevoked)
+
+	return
+}
+
+// Cancel stops reading the revocations; all tokens are accepted again.
+func Cancel() {
+	mut.Lock()
+	defer mut.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	convAuth.SetRevocationCheck(nil)
+
+	cancel()
+	cancel = nil
+}
+
+func ready() (convDB.TenantObjectSet[Revocation, RevocationID, RevocationID], error) {
+	mut.Lock()
+	defer mut.Unlock()
+
+	if cancel == nil {
+		return revocationsDB, ErrNotInitialised
+	}
+
+	return revocationsDB, nil
+}
+
+func background(ctx convCtx.Context) {
+	for {
+		timer := time.NewTimer(syncInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		expired, err := syncRevocations(ctx)
+		if err != nil {
+			ctx.Logger().Error("failed to sync revocations from database", "error", err.Error())
+			continue
+		}
+
+		for _, id := range expired {
+			err = revocationsDB.Delete(ctx, id)
+			if err != nil {
+				ctx.Logger().Error("failed to delete expired revocation", "id", id, "error", err.Error())
+			}
+		}
+	}
+}
+
+// syncRevocations replaces the cached revocations with the ones of the vault,
+// but for those of expired tokens
+func syncRevocations(ctx convCtx.Context) (expired []RevocationID, err error) {
+
+	revs, err := revocationsDB.SelectAll(ctx)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	nextTokens := make(map[TokenID]struct{})
+	nextUsers := make(map[convAuth.User]time.Time)
+	for _, rev := range revs {
+		if rev.expired(now) {
+			expired = append(expired, rev.ID)
+			continue
+		}
+		cacheRevocation(nextTokens, nextUsers, rev)
+	}
+
+	cacheMut.Lock()
+	tokens, users = nextTokens, nextUsers
+	cacheMut.Unlock()
+
+	return
+}
+
+func cacheRevocation(tokens map[TokenID]struct{}, users map[convAuth.User]time.Time, rev Revocation) {
+	if rev.Token != "" {
+		tokens[rev.Token] = struct{}{}
+	}
+	if rev.User != "" {
+		users[rev.User] = rev.IssuedBefore
+	}
+}
+
+// isRevoked is the convAuth revocation check; tokens without iat are revoked
+// with all tokens of their user.
+func isRevoked(claims convAuth.Claims) bool {
+	cacheMut.RLock()
+	defer cacheMut.RUnlock()
+
+	if _, ok := tokens[TokenID(claims.Registered.ID)]; claims.Registered.ID != "" && ok {
+		return true
+	}
+
+	issuedBefore, ok := users[claims.User]
+
+	return ok && claims.Registered.IssuedAt.Before(issuedBefore)
+}
+
+// RevokeToken revokes the token of the jti, until the token expires at
+// expiresAt; a zero time keeps the revocation for good.
+func RevokeToken(ctx convCtx.Context, token TokenID, expiresAt time.Time, reason string) (res Revocation, err error) {
+
+	if token == "" {
+		err = fmt.Errorf("%w: missing token id", ErrInvalidRevocation)
+		return
+	}
+
+	return revoke(ctx, Revocation{
+		ID:        tokenRevocationID(token),
+		Token:     token,
+		ExpiresAt: expiresAt.UTC(),
+		Reason:    reason,
+	})
+}
+
+// RevokeUser revokes all tokens of the user issued before the time, to log
+// the user out everywhere; tokens issued afterwards are valid. The time is
+// truncated to the second, the precision of iat, so that tokens issued right
+// after the revocation are valid.
+func RevokeUser(ctx convCtx.Context, user convAuth.User, issuedBefore time.Time, reason string) (res Revocation, err error) {
+
+	if user == "" || issuedBefore.IsZero() {
+		err = fmt.Errorf("%w: missing user or issued before time", ErrInvalidRevocation)
+		return
+	}
+
+	return revoke(ctx, Revocation{
+		ID:           userRevocationID(user),
+		User:         user,
+		IssuedBefore: issuedBefore.UTC().Truncate(time.Second),
+		Reason:       reason,
+	})
+}
+
+func revoke(ctx convCtx.Context, rev Revocation) (res Revocation, err error) {
+
+	db, err := ready()
+	if err != nil {
+		return
+	}
+
+	rev.RevokedBy = ctx.Claims().User
+	rev.RevokedAt = time.Now().UTC()
+
+	err = db.Upsert(ctx, rev)
+	if err != nil {
+		return
+	}
+
+	// effective on this instance right away, on others after their next sync
+	cacheMut.Lock()
+	cacheRevocation(tokens, users, rev)
+	cacheMut.Unlock()
+
+	return rev, nil
+}
+
+// Revocations returns all revocations, as stored in the vault.
+func Revocations(ctx convCtx.Context) (res []Revocation, err error) {
+
+	db, err := ready()
+	if err != nil {
+		return
+	}
+
+	res, err = db.SelectAll(ctx)
+	if err != nil {
+		return
+	}
+
+	if res == nil {
+		res = []Revocation{}
+	}
+
+	return
+}
@@ -0,0 +1,268 @@
+package revocation_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convRevocation "github.com/sofmon/convention/lib/revocation"
+)
+
+const (
+	testTenant = "test"
+	testVault  = "jobs"
+	testPort   = 12500
+
+	roleAdmin       convAuth.Role       = "admin"
+	permissionAdmin convAuth.Permission = "revoke_tokens"
+)
+
+func TestMain(m *testing.M) {
+
+	err := convCfg.SetConfigLocation("../../.secret")
+	if err != nil {
+		panic(fmt.Errorf("SetConfigLocation failed: %w", err))
+	}
+
+	ctx := convCtx.New(convAuth.Claims{User: "revocation_test"})
+
+	restore := convRevocation.SetSyncIntervalForTest(50 * time.Millisecond)
+
+	err = convRevocation.Initialise(ctx, testVault, testTenant)
+	if err != nil {
+		panic(fmt.Errorf("Initialise failed: %w", err))
+	}
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleAdmin: convAuth.Permissions{permissionAdmin},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionAdmin: convAuth.Actions{
+				"GET /revocations/v1/revocations",
+				"POST /revocations/v1/{any...}",
+			},
+		},
+	}
+
+	srv, err := convAPI.NewServer(ctx, "localhost", testPort, policy, convRevocation.NewAPI())
+	if err != nil {
+		panic(fmt.Errorf("NewServer failed: %w", err))
+	}
+
+	go srv.ListenAndServe()
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	code := m.Run()
+
+	_ = srv.Shutdown(ctx)
+	convRevocation.Cancel()
+	restore()
+
+	os.Exit(code)
+}
+
+// newUser returns claims of a user unique to the test run, as revocations
+// are kept in the vault
+func newUser(roles ...convAuth.Role) convAuth.Claims {
+	return convAuth.Claims{User: convAuth.User("user-" + uuid.NewString()), Roles: roles}
+}
+
+func newToken(t *testing.T, claims convAuth.Claims, issuedAt time.Time) (token string, jti convRevocation.TokenID) {
+	t.Helper()
+	token, _, err := convAuth.GenerateTokenWithOptions(claims, convAuth.TokenOptions{IssuedAt: issuedAt, Audience: []string{"localhost"}})
+	if err != nil {
+		t.Fatalf("GenerateTokenWithOptions failed: %v", err)
+	}
+	res, err := convAuth.DecodeToken(token)
+	if err != nil {
+		t.Fatalf("DecodeToken of a fresh token = %v; want nil", err)
+	}
+	return token, convRevocation.TokenID(res.Registered.ID)
+}
+
+func call(t *testing.T, token, method, path string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, fmt.Sprintf("https://localhost:%d%s", testPort, path), bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(convAuth.HttpHeaderAuthorization, "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Do() = %v; want nil", err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestRevokeToken(t *testing.T) {
+
+	admin := newUser(roleAdmin)
+	adminToken, _ := newToken(t, admin, time.Now())
+
+	user := newUser()
+	revoked, jti := newToken(t, user, time.Now())
+	other, _ := newToken(t, user, time.Now())
+
+	res := call(t, adminToken, http.MethodPost, "/revocations/v1/tokens/"+string(jti), convRevocation.RevokeRequest{Reason: "leaked"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST tokens/{token} status = %d; want %d", res.StatusCode, http.StatusOK)
+	}
+
+	var rev convRevocation.Revocation
+	json.NewDecoder(res.Body).Decode(&rev)
+	if rev.Token != jti || rev.Reason != "leaked" || rev.RevokedBy != admin.User {
+		t.Fatalf("revocation = %+v; want of the token by the admin", rev)
+	}
+
+	_, err := convAuth.DecodeToken(revoked)
+	if !errors.Is(err, convAuth.ErrTokenRevoked) {
+		t.Fatalf("DecodeToken of the revoked token = %v; want ErrTokenRevoked", err)
+	}
+
+	_, err = convAuth.DecodeToken(other)
+	if err != nil {
+		t.Fatalf("DecodeToken of another token of the user = %v; want nil", err)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+
+	admin := newUser(roleAdmin)
+	adminToken, _ := newToken(t, admin, time.Now())
+
+	user := newUser()
+	before, _ := newToken(t, user, time.Now().Add(-time.Hour))
+
+	res := call(t, adminToken, http.MethodPost, "/revocations/v1/users/"+string(user.User), convRevocation.RevokeRequest{Reason: "log out everywhere"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST users/{user} status = %d; want %d", res.StatusCode, http.StatusOK)
+	}
+
+	after, _ := newToken(t, user, time.Now()) // right after, likely in the same second
+
+	_, err := convAuth.DecodeToken(before)
+	if !errors.Is(err, convAuth.ErrTokenRevoked) {
+		t.Fatalf("DecodeToken of a token issued before = %v; want ErrTokenRevoked", err)
+	}
+
+	_, err = convAuth.DecodeToken(after)
+	if err != nil {
+		t.Fatalf("DecodeToken of a token issued after = %v; want nil", err)
+	}
+
+	_, err = convRevocation.RevokeUser(convCtx.New(admin), "", time.Now(), "")
+	if !errors.Is(err, convRevocation.ErrInvalidRevocation) {
+		t.Fatalf("RevokeUser without user = %v; want ErrInvalidRevocation", err)
+	}
+}
+
+func TestRevokedAdmin(t *testing.T) {
+
+	admin := newUser(roleAdmin)
+	adminToken, jti := newToken(t, admin, time.Now())
+
+	_, err := convRevocation.RevokeToken(convCtx.New(admin), jti, time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+
+	res := call(t, adminToken, http.MethodGet, "/revocations/v1/revocations", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET revocations with a revoked token status = %d; want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestSync(t *testing.T) {
+
+	admin := newUser(roleAdmin)
+	adminToken, _ := newToken(t, admin, time.Now())
+
+	user := newUser()
+	token, jti := newToken(t, user, time.Now())
+
+	_, err := convRevocation.RevokeToken(convCtx.New(admin), jti, time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+
+	convRevocation.ForgetForTest() // as on another instance
+
+	_, err = convAuth.DecodeToken(token)
+	if err != nil {
+		t.Fatalf("DecodeToken before the sync = %v; want nil", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = convAuth.DecodeToken(token)
+		if errors.Is(err, convAuth.ErrTokenRevoked) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("DecodeToken after the sync = %v; want ErrTokenRevoked", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res := call(t, adminToken, http.MethodGet, "/revocations/v1/revocations", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET revocations status = %d; want %d", res.StatusCode, http.StatusOK)
+	}
+
+	var revs []convRevocation.Revocation
+	json.NewDecoder(res.Body).Decode(&revs)
+
+	found := false
+	for _, rev := range revs {
+		found = found || rev.Token == jti
+	}
+	if !found {
+		t.Fatalf("revocations do not list the token %s", jti)
+	}
+}
+
+func TestExpiredRevocation(t *testing.T) {
+
+	admin := newUser(roleAdmin)
+
+	jti := convRevocation.TokenID(uuid.NewString()) // of a token that expired an hour ago
+
+	_, err := convRevocation.RevokeToken(convCtx.New(admin), jti, time.Now().Add(-time.Hour), "")
+	if err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		revs, err := convRevocation.Revocations(convCtx.New(admin))
+		if err != nil {
+			t.Fatalf("Revocations failed: %v", err)
+		}
+
+		found := false
+		for _, rev := range revs {
+			found = found || rev.Token == jti
+		}
+		if !found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("revocation of the expired token %s is not deleted", jti)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
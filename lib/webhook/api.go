@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+)
+
+// API manages the subscriptions and dead deliveries of a tenant. Serve it on
+// its own or embed it in the API struct of the agent.
+type API struct {
+	ListSubscriptions  convAPI.OutP1[[]Subscription, convAuth.Tenant]                               `api:"GET /webhooks/v1/tenants/{tenant}/subscriptions"`
+	CreateSubscription convAPI.InOutP1[Subscription, Subscription, convAuth.Tenant]                 `api:"POST /webhooks/v1/tenants/{tenant}/subscriptions"`
+	GetSubscription    convAPI.OutP2[Subscription, convAuth.Tenant, SubscriptionID]                 `api:"GET /webhooks/v1/tenants/{tenant}/subscriptions/{subscription}"`
+	UpdateSubscription convAPI.InOutP2[Subscription, Subscription, convAuth.Tenant, SubscriptionID] `api:"PUT /webhooks/v1/tenants/{tenant}/subscriptions/{subscription}"`
+	DeleteSubscription convAPI.TriggerP2[convAuth.Tenant, SubscriptionID]                           `api:"DELETE /webhooks/v1/tenants/{tenant}/subscriptions/{subscription}"`
+	ListDeadLetters    convAPI.OutP1[[]Delivery, convAuth.Tenant]                                   `api:"GET /webhooks/v1/tenants/{tenant}/dead-letters"`
+	GetDelivery        convAPI.OutP2[Delivery, convAuth.Tenant, DeliveryID]                         `api:"GET /webhooks/v1/tenants/{tenant}/deliveries/{delivery}"`
+	ReplayDelivery     convAPI.OutP2[Delivery, convAuth.Tenant, DeliveryID]                         `api:"POST /webhooks/v1/tenants/{tenant}/deliveries/{delivery}/replay"`
+}
+
+func NewAPI() *API {
+	return &API{
+		ListSubscriptions: convAPI.NewOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant) ([]Subscription, error) {
+			res, err := Subscriptions(ctx, tenant)
+			return res, apiError(ctx, err)
+		}),
+		CreateSubscription: convAPI.NewInOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant, sub Subscription) (Subscription, error) {
+			res, err := Subscribe(ctx, tenant, sub)
+			return res, apiError(ctx, err)
+		}),
+		GetSubscription: convAPI.NewOutP2(func(ctx convCtx.Context, tenant convAuth.Tenant, id SubscriptionID) (Subscription, error) {
+			res, err := GetSubscription(ctx, tenant, id)
+			return res, apiError(ctx, err)
+		}),
+		UpdateSubscription: convAPI.NewInOutP2(func(ctx convCtx.Context, tenant convAuth.Tenant, id SubscriptionID, sub Subscription) (Subscription, error) {
+			sub.ID = id
+			res, err := UpdateSubscription(ctx, tenant, sub)
+			return res, apiError(ctx, err)
+		}),
+		DeleteSubscription: convAPI.NewTriggerP2(func(ctx convCtx.Context, tenant convAuth.Tenant, id SubscriptionID) error {
+			return apiError(ctx, Unsubscribe(ctx, tenant, id))
+		}),
+		ListDeadLetters: convAPI.NewOutP1(func(ctx convCtx.Context, tenant convAuth.Tenant) ([]Delivery, error) {
+			res, err := DeadLetters(ctx, tenant)
+			return res, apiError(ctx, err)
+		}),
+		GetDelivery: convAPI.NewOutP2(func(ctx convCtx.Context, tenant convAuth.Tenant, id DeliveryID) (Delivery, error) {
+			res, err := GetDelivery(ctx, tenant, id)
+			return res, apiError(ctx, err)
+		}),
+		ReplayDelivery: convAPI.NewOutP2(func(ctx convCtx.Context, tenant convAuth.Tenant, id DeliveryID) (Delivery, error) {
+			res, err := Replay(ctx, tenant, id)
+			return res, apiError(ctx, err)
+		}),
+	}
+}
+
+func apiError(ctx convCtx.Context, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrSubscriptionNotFound), errors.Is(err, ErrDeliveryNotFound):
+		return convAPI.NewError(ctx, http.StatusNotFound, convAPI.ErrorCodeNotFound, err.Error(), err)
+	case errors.Is(err, ErrInvalidSubscription), errors.Is(err, ErrTenantNotServed):
+		return convAPI.NewError(ctx, http.StatusBadRequest, convAPI.ErrorCodeBadRequest, err.Error(), err)
+	case errors.Is(err, ErrDeliveryNotReplayable):
+		return convAPI.NewError(ctx, http.StatusConflict, convAPI.ErrorCodeConflict, err.Error(), err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+)
+
+// Delivery tuning; package vars so tests can shorten them. A failed attempt
+// is retried after deliveryBackoff, doubling up to deliveryMaxBackoff; after
+// deliveryMaxAttempts the delivery is dead.
+var (
+	deliveryPollInterval = 10 * time.Second
+	deliveryTimeout      = 10 * time.Second
+	deliveryBackoff      = 30 * time.Second
+	deliveryMaxBackoff   = 6 * time.Hour
+	deliveryMaxAttempts  = 10
+	deliveryBatchSize    = 100 // per job run, the rest waits for the next one
+)
+
+// deliverDue runs in the delivery job of the tenant; the job lock ensures a
+// single instance delivers at a time.
+func deliverDue(ctx convCtx.Context, tenant convAuth.Tenant) (err error) {
+
+	subsDB, delsDB, err := ready()
+	if err != nil {
+		return
+	}
+
+	pending, err := delsDB.Tenant(tenant).Select(ctx, convDB.Where().Key("state").Equals().Value(DeliveryStatePending))
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	client := newDeliveryClient()
+	subs := map[SubscriptionID]*Subscription{}
+
+	sent := 0
+	for _, del := range pending {
+
+		if ctx.Err() != nil || sent >= deliveryBatchSize {
+			return
+		}
+
+		if del.NextAttemptAt.After(now) {
+			continue
+		}
+
+		sub, ok := subs[del.Subscription]
+		if !ok {
+			sub, err = subsDB.Tenant(tenant).SelectByID(ctx, del.Subscription)
+			if err != nil {
+				return
+			}
+			subs[del.Subscription] = sub
+		}
+
+		next := del
+		next.UpdatedAt = time.Now().UTC()
+
+		switch {
+		case sub == nil:
+			next.State = DeliveryStateDead
+			next.LastError = "subscription removed"
+		case sub.Disabled:
+			next.State = DeliveryStateDead
+			next.LastError = "subscription disabled"
+		default:
+			sent++
+			next.Attempts++
+			next.LastAttemptAt = next.UpdatedAt
+			next.LastStatus, err = send(ctx, client, *sub, del)
+			if err == nil {
+				next.State = DeliveryStateDelivered
+				next.LastError = ""
+				break
+			}
+			next.LastError = err.Error()
+			if next.Attempts >= deliveryMaxAttempts {
+				next.State = DeliveryStateDead
+			} else {
+				next.NextAttemptAt = next.LastAttemptAt.Add(backoff(next.Attempts))
+			}
+		}
+
+		err = delsDB.Tenant(tenant).SafeUpdate(ctx, del, next)
+		if errors.Is(err, convDB.ErrCASConflict) || errors.Is(err, convDB.ErrLockNotAvailable) {
+			err = nil // replayed or changed meanwhile; picked up by the next run
+		}
+		if err != nil {
+			return
+		}
+
+		if next.State == DeliveryStateDead {
+			ctx.Logger().Warn("webhook delivery dead",
+				"tenant", string(tenant),
+				"delivery", string(del.ID),
+				"subscription", string(del.Subscription),
+				"attempts", next.Attempts,
+				"error", next.LastError,
+			)
+		}
+	}
+
+	return
+}
+
+// newDeliveryClient returns a client dialing only addresses passing
+// checkAddress, also after redirects and DNS changes since the subscription
+func newDeliveryClient() *http.Client {
+
+	dialer := &net.Dialer{
+		Timeout: deliveryTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil && !AllowPrivateURLs {
+				return fmt.Errorf("%w: %s", errPrivateAddress, host) // e.g. link-local with a zone
+			}
+			return checkAddress(ip)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // dialed directly, so that the control sees the address of the receiver
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: deliveryTimeout, Transport: transport}
+}
+
+func backoff(attempts int) time.Duration {
+	d := deliveryBackoff
+	for i := 1; i < attempts && d < deliveryMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, deliveryMaxBackoff)
+}
+
+func send(ctx convCtx.Context, client *http.Client, sub Subscription, del Delivery) (status int, err error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(del.Body))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HttpHeaderID, string(del.Event))
+	req.Header.Set(HttpHeaderEvent, string(del.Type))
+	req.Header.Set(HttpHeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HttpHeaderSignature, Sign(sub.Secret, now, del.Body))
+	if del.Workflow != "" {
+		req.Header.Set(convCtx.HttpHeaderWorkflow, string(del.Workflow))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	status = res.StatusCode
+	if status < 200 || status > 299 {
+		err = fmt.Errorf("unexpected status code %d", status)
+	}
+
+	return
+}
@@ -0,0 +1,13 @@
+package webhook
+
+import "time"
+
+// Test seams (compiled only into the package's test binary).
+
+// SetDeliveryForTest shortens the delivery intervals and attempts for tests
+// and returns a restore func.
+func SetDeliveryForTest(poll, backoff time.Duration, maxAttempts int) (restore func()) {
+	op, ob, om := deliveryPollInterval, deliveryBackoff, deliveryMaxAttempts
+	deliveryPollInterval, deliveryBackoff, deliveryMaxAttempts = poll, backoff, maxAttempts
+	return func() { deliveryPollInterval, deliveryBackoff, deliveryMaxAttempts = op, ob, om }
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HttpHeaderID        = "Webhook-Id"        // event id, same for every attempt; use it to drop duplicates
+	HttpHeaderEvent     = "Webhook-Event"     // event type
+	HttpHeaderTimestamp = "Webhook-Timestamp" // unix seconds of the attempt
+	HttpHeaderSignature = "Webhook-Signature" // v1=hex(hmac-sha256(secret, timestamp + "." + body))
+
+	signatureVersion = "v1"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredTimestamp = errors.New("webhook timestamp outside of tolerance")
+)
+
+// Sign returns the signature header value of the body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and the timestamp of a received webhook and
+// returns its event; receivers pass the secret of their subscription.
+func Verify(secret string, r *http.Request, tolerance time.Duration) (event Event, err error) {
+
+	signature := r.Header.Get(HttpHeaderSignature)
+	ts := r.Header.Get(HttpHeaderTimestamp)
+	if signature == "" || ts == "" {
+		err = ErrMissingSignature
+		return
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		err = ErrInvalidSignature
+		return
+	}
+	timestamp := time.Unix(unix, 0)
+
+	age := time.Since(timestamp)
+	if age > tolerance || age < -tolerance {
+		err = ErrExpiredTimestamp
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		err = ErrInvalidSignature
+		return
+	}
+
+	err = json.Unmarshal(body, &event)
+	return
+}
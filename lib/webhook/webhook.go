@@ -0,0 +1,491 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convDB "github.com/sofmon/convention/lib/db"
+	convJob "github.com/sofmon/convention/lib/job"
+)
+
+type SubscriptionID string
+
+type EventID string
+
+type EventType string
+
+type DeliveryID string
+
+type DeliveryState string
+
+const (
+	DeliveryStatePending   DeliveryState = "pending"
+	DeliveryStateDelivered DeliveryState = "delivered"
+	DeliveryStateDead      DeliveryState = "dead" // gave up; can be replayed
+
+	deliveryJobID convJob.JobID = "convention-webhook-deliveries"
+)
+
+var (
+	ErrNotInitialised        = errors.New("webhooks are not initialised - call Initialise first")
+	ErrSubscriptionNotFound  = errors.New("subscription not found")
+	ErrDeliveryNotFound      = errors.New("delivery not found")
+	ErrInvalidSubscription   = errors.New("invalid subscription")
+	ErrDeliveryNotReplayable = errors.New("only dead deliveries can be replayed")
+	ErrTenantNotServed       = errors.New("webhooks are not delivered for the tenant - pass it to Initialise")
+)
+
+// Subscription is an external endpoint notified of the events of a tenant.
+type Subscription struct {
+	ID          SubscriptionID `json:"id"`
+	URL         string         `json:"url"`
+	Events      []EventType    `json:"events,omitempty"` // empty for all events
+	Description string         `json:"description,omitempty"`
+	Secret      string         `json:"secret,omitempty"` // generated when empty; returned on creation only
+	Disabled    bool           `json:"disabled,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (x Subscription) DBKey() convDB.Key[SubscriptionID, SubscriptionID] {
+	return convDB.Key[SubscriptionID, SubscriptionID]{
+		ID:       x.ID,
+		ShardKey: x.ID,
+	}
+}
+
+func (x Subscription) matches(event EventType) bool {
+	return !x.Disabled && (len(x.Events) == 0 || slices.Contains(x.Events, event))
+}
+
+// Event is the signed payload posted to subscribers.
+type Event struct {
+	ID        EventID         `json:"id"`
+	Type      EventType       `json:"type"`
+	Tenant    convAuth.Tenant `json:"tenant"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Delivery is an event on its way to a single subscription.
+type Delivery struct {
+	ID            DeliveryID       `json:"id"`
+	Subscription  SubscriptionID   `json:"subscription"`
+	Event         EventID          `json:"event"`
+	Type          EventType        `json:"type"`
+	Body          json.RawMessage  `json:"body"`
+	Workflow      convCtx.Workflow `json:"workflow,omitempty"`
+	State         DeliveryState    `json:"state"`
+	Attempts      int              `json:"attempts"`
+	NextAttemptAt time.Time        `json:"next_attempt_at"`
+	LastAttemptAt time.Time        `json:"last_attempt_at,omitempty"`
+	LastStatus    int              `json:"last_status,omitempty"`
+	LastError     string           `json:"last_error,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+func (x Delivery) DBKey() convDB.Key[DeliveryID, DeliveryID] {
+	return convDB.Key[DeliveryID, DeliveryID]{
+		ID:       x.ID,
+		ShardKey: x.ID,
+	}
+}
+
+var (
+	mut             sync.Mutex
+	subscriptionsDB convDB.ObjectSetReady[Subscription, SubscriptionID, SubscriptionID]
+	deliveriesDB    convDB.ObjectSetReady[Delivery, DeliveryID, DeliveryID]
+	servedTenants   map[convAuth.Tenant]bool // with a delivery job
+)
+
+// Initialise stores subscriptions and deliveries in the vault and registers
+// the delivery job of each tenant; the job runner must be initialised beforehand.
+// Only these tenants can subscribe.
+func Initialise(ctx convCtx.Context, vault convDB.Vault, tenants ...convAuth.Tenant) (err error) {
+	mut.Lock()
+	defer mut.Unlock()
+
+	subscriptionsDB = convDB.NewObjectSet[Subscription](vault).Ready()
+	deliveriesDB = convDB.NewObjectSet[Delivery](vault).Ready()
+	servedTenants = map[convAuth.Tenant]bool{}
+
+	for _, tenant := range tenants {
+		err = convJob.Register(ctx, tenant, deliveryJobID, time.Now().UTC(), deliveryPollInterval, func(ctx convCtx.Context) error {
+			return deliverDue(ctx, tenant)
+		})
+		if err != nil {
+			return
+		}
+		servedTenants[tenant] = true
+	}
+
+	return
+}
+
+func served(tenant convAuth.Tenant) error {
+	mut.Lock()
+	defer mut.Unlock()
+
+	if !servedTenants[tenant] {
+		return fmt.Errorf("%w: '%s'", ErrTenantNotServed, tenant)
+	}
+
+	return nil
+}
+
+func ready() (subs convDB.ObjectSetReady[Subscription, SubscriptionID, SubscriptionID], dels convDB.ObjectSetReady[Delivery, DeliveryID, DeliveryID], err error) {
+	mut.Lock()
+	defer mut.Unlock()
+
+	if subscriptionsDB == nil || deliveriesDB == nil {
+		err = ErrNotInitialised
+		return
+	}
+
+	return subscriptionsDB, deliveriesDB, nil
+}
+
+// Publish stores a delivery of the event for every matching subscription of
+// the tenant; the deliveries are sent by the delivery job.
+func Publish(ctx convCtx.Context, tenant convAuth.Tenant, event EventType, data any) (err error) {
+
+	subsDB, delsDB, err := ready()
+	if err != nil {
+		return
+	}
+
+	subs, err := subsDB.Tenant(tenant).SelectAll(ctx)
+	if err != nil {
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	id := EventID(uuid.NewString())
+
+	body, err := json.Marshal(Event{
+		ID:        id,
+		Type:      event,
+		Tenant:    tenant,
+		CreatedAt: now,
+		Data:      raw,
+	})
+	if err != nil {
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.matches(event) {
+			continue
+		}
+
+		err = delsDB.Tenant(tenant).Insert(ctx, Delivery{
+			ID:            DeliveryID(uuid.NewString()),
+			Subscription:  sub.ID,
+			Event:         id,
+			Type:          event,
+			Body:          body,
+			Workflow:      ctx.Workflow(),
+			State:         DeliveryStatePending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// AllowPrivateURLs accepts subscriptions to, and delivers to, loopback,
+// private and link-local addresses; only for tests against local receivers
+// such as httptest servers.
+var AllowPrivateURLs = false
+
+var errPrivateAddress = errors.New("loopback, private and link-local addresses are not allowed")
+
+// checkAddress fails on addresses inside the network of the agent, so that
+// subscriptions cannot make it call internal services
+func checkAddress(ip net.IP) error {
+	if AllowPrivateURLs {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, ip)
+	}
+	return nil
+}
+
+func validate(ctx convCtx.Context, sub Subscription) error {
+
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidSubscription)
+	}
+
+	if AllowPrivateURLs {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: url host does not resolve: %w", ErrInvalidSubscription, err)
+	}
+	for _, addr := range addrs {
+		err = checkAddress(addr.IP)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+		}
+	}
+
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Subscribe creates a subscription; the returned one holds the signing secret.
+// Tenants not passed to Initialise get ErrTenantNotServed, as nothing would
+// deliver their events.
+func Subscribe(ctx convCtx.Context, tenant convAuth.Tenant, sub Subscription) (res Subscription, err error) {
+
+	subsDB, _, err := ready()
+	if err != nil {
+		return
+	}
+
+	err = served(tenant)
+	if err != nil {
+		return
+	}
+
+	err = validate(ctx, sub)
+	if err != nil {
+		return
+	}
+
+	if sub.Secret == "" {
+		sub.Secret, err = newSecret()
+		if err != nil {
+			return
+		}
+	}
+
+	sub.ID = SubscriptionID(uuid.NewString())
+	sub.CreatedAt = time.Now().UTC()
+	sub.UpdatedAt = sub.CreatedAt
+
+	err = subsDB.Tenant(tenant).Insert(ctx, sub)
+	if err != nil {
+		return
+	}
+
+	return sub, nil
+}
+
+// UpdateSubscription changes the url, events, description and state of a
+// subscription; the secret is kept unless a new one is given.
+func UpdateSubscription(ctx convCtx.Context, tenant convAuth.Tenant, sub Subscription) (res Subscription, err error) {
+
+	subsDB, _, err := ready()
+	if err != nil {
+		return
+	}
+
+	err = validate(ctx, sub)
+	if err != nil {
+		return
+	}
+
+	cur, err := subsDB.Tenant(tenant).SelectByID(ctx, sub.ID)
+	if err != nil {
+		return
+	}
+	if cur == nil {
+		err = ErrSubscriptionNotFound
+		return
+	}
+
+	next := *cur
+	next.URL = sub.URL
+	next.Events = sub.Events
+	next.Description = sub.Description
+	next.Disabled = sub.Disabled
+	if sub.Secret != "" {
+		next.Secret = sub.Secret
+	}
+	next.UpdatedAt = time.Now().UTC()
+
+	err = subsDB.Tenant(tenant).Update(ctx, next)
+	if err != nil {
+		return
+	}
+
+	return withoutSecret(next), nil
+}
+
+// Unsubscribe deletes a subscription; the delivery job marks its pending
+// deliveries dead when they are next due.
+func Unsubscribe(ctx convCtx.Context, tenant convAuth.Tenant, id SubscriptionID) (err error) {
+
+	subsDB, _, err := ready()
+	if err != nil {
+		return
+	}
+
+	cur, err := subsDB.Tenant(tenant).SelectByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if cur == nil {
+		return ErrSubscriptionNotFound
+	}
+
+	return subsDB.Tenant(tenant).Delete(ctx, id)
+}
+
+// Subscriptions returns the subscriptions of the tenant, without their secrets.
+func Subscriptions(ctx convCtx.Context, tenant convAuth.Tenant) (res []Subscription, err error) {
+
+	subsDB, _, err := ready()
+	if err != nil {
+		return
+	}
+
+	subs, err := subsDB.Tenant(tenant).SelectAll(ctx)
+	if err != nil {
+		return
+	}
+
+	res = make([]Subscription, 0, len(subs))
+	for _, sub := range subs {
+		res = append(res, withoutSecret(sub))
+	}
+
+	return
+}
+
+// GetSubscription returns a subscription without its secret.
+func GetSubscription(ctx convCtx.Context, tenant convAuth.Tenant, id SubscriptionID) (res Subscription, err error) {
+
+	subsDB, _, err := ready()
+	if err != nil {
+		return
+	}
+
+	sub, err := subsDB.Tenant(tenant).SelectByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if sub == nil {
+		err = ErrSubscriptionNotFound
+		return
+	}
+
+	return withoutSecret(*sub), nil
+}
+
+func withoutSecret(sub Subscription) Subscription {
+	sub.Secret = ""
+	return sub
+}
+
+// GetDelivery returns a delivery of the tenant.
+func GetDelivery(ctx convCtx.Context, tenant convAuth.Tenant, id DeliveryID) (res Delivery, err error) {
+
+	_, delsDB, err := ready()
+	if err != nil {
+		return
+	}
+
+	del, err := delsDB.Tenant(tenant).SelectByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if del == nil {
+		err = ErrDeliveryNotFound
+		return
+	}
+
+	return *del, nil
+}
+
+// DeadLetters returns the deliveries given up on.
+func DeadLetters(ctx convCtx.Context, tenant convAuth.Tenant) (res []Delivery, err error) {
+
+	_, delsDB, err := ready()
+	if err != nil {
+		return
+	}
+
+	res, err = delsDB.Tenant(tenant).Select(ctx, convDB.Where().Key("state").Equals().Value(DeliveryStateDead))
+	if err != nil {
+		return
+	}
+
+	if res == nil {
+		res = []Delivery{}
+	}
+
+	return
+}
+
+// Replay schedules a dead delivery to be sent again, with fresh attempts.
+func Replay(ctx convCtx.Context, tenant convAuth.Tenant, id DeliveryID) (res Delivery, err error) {
+
+	_, delsDB, err := ready()
+	if err != nil {
+		return
+	}
+
+	cur, err := delsDB.Tenant(tenant).SelectByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if cur == nil {
+		err = ErrDeliveryNotFound
+		return
+	}
+	if cur.State != DeliveryStateDead {
+		err = ErrDeliveryNotReplayable
+		return
+	}
+
+	next := *cur
+	next.State = DeliveryStatePending
+	next.Attempts = 0
+	next.NextAttemptAt = time.Now().UTC()
+	next.UpdatedAt = next.NextAttemptAt
+
+	err = delsDB.Tenant(tenant).SafeUpdate(ctx, *cur, next)
+	if err != nil {
+		return
+	}
+
+	return next, nil
+}
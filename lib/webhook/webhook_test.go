@@ -0,0 +1,385 @@
+package webhook_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	convAPI "github.com/sofmon/convention/lib/api"
+	convAuth "github.com/sofmon/convention/lib/auth"
+	convCfg "github.com/sofmon/convention/lib/cfg"
+	convCtx "github.com/sofmon/convention/lib/ctx"
+	convJob "github.com/sofmon/convention/lib/job"
+	convWebhook "github.com/sofmon/convention/lib/webhook"
+)
+
+const (
+	testTenant = "test"
+	testVault  = "jobs"
+	testPort   = 12400
+
+	roleAdmin       convAuth.Role       = "admin"
+	permissionAdmin convAuth.Permission = "manage_webhooks"
+)
+
+func TestMain(m *testing.M) {
+
+	err := convCfg.SetConfigLocation("../../.secret")
+	if err != nil {
+		panic(fmt.Errorf("SetConfigLocation failed: %w", err))
+	}
+
+	ctx := convCtx.New(convAuth.Claims{User: "webhook_test"})
+
+	err = convJob.Initialise(ctx, testVault)
+	if err != nil {
+		panic(fmt.Errorf("convJob.Initialise failed: %w", err))
+	}
+
+	restore := convWebhook.SetDeliveryForTest(time.Second, 10*time.Millisecond, 2)
+
+	convWebhook.AllowPrivateURLs = true // the receivers are local httptest servers
+
+	err = convWebhook.Initialise(ctx, testVault, testTenant)
+	if err != nil {
+		panic(fmt.Errorf("Initialise failed: %w", err))
+	}
+
+	policy := convAuth.Policy{
+		Roles: convAuth.RolePermissions{
+			roleAdmin: convAuth.Permissions{permissionAdmin},
+		},
+		Permissions: convAuth.PermissionActions{
+			permissionAdmin: convAuth.Actions{
+				"GET /webhooks/v1/tenants/{tenant}/{any...}",
+				"POST /webhooks/v1/tenants/{tenant}/{any...}",
+				"PUT /webhooks/v1/tenants/{tenant}/{any...}",
+				"DELETE /webhooks/v1/tenants/{tenant}/{any...}",
+			},
+		},
+	}
+
+	srv, err := convAPI.NewServer(ctx, "localhost", testPort, policy, convWebhook.NewAPI())
+	if err != nil {
+		panic(fmt.Errorf("NewServer failed: %w", err))
+	}
+
+	go srv.ListenAndServe()
+
+	time.Sleep(10 * time.Millisecond) // give time for the agent api to start
+
+	code := m.Run()
+
+	_ = srv.Shutdown(ctx)
+	_ = convJob.Cancel()
+	restore()
+
+	os.Exit(code)
+}
+
+func adminCtx() convCtx.Context {
+	return convCtx.New(convAuth.Claims{
+		User:    "admin",
+		Tenants: convAuth.Tenants{testTenant},
+		Roles:   convAuth.Roles{roleAdmin},
+	})
+}
+
+// receiver is a local subscriber verifying the signature of every call
+type receiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	secret string
+	events []convWebhook.Event
+	fail   atomic.Bool
+}
+
+func newReceiver() *receiver {
+	rcv := &receiver{}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rcv.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+
+		event, err := convWebhook.Verify(rcv.secret, r, time.Minute)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rcv.events = append(rcv.events, event)
+	}))
+	return rcv
+}
+
+func (rcv *receiver) received() []convWebhook.Event {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]convWebhook.Event{}, rcv.events...)
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func Test_sign_and_verify(t *testing.T) {
+
+	body := []byte(`{"id":"1","type":"order.created","tenant":"test"}`)
+
+	newRequest := func(ts time.Time, signature string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set(convWebhook.HttpHeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+		r.Header.Set(convWebhook.HttpHeaderSignature, signature)
+		return r
+	}
+
+	now := time.Now()
+
+	event, err := convWebhook.Verify("secret", newRequest(now, convWebhook.Sign("secret", now, body)), time.Minute)
+	if err != nil || event.Type != "order.created" {
+		t.Fatalf("Verify() = %+v, %v; want order.created event", event, err)
+	}
+
+	_, err = convWebhook.Verify("other", newRequest(now, convWebhook.Sign("secret", now, body)), time.Minute)
+	if !errors.Is(err, convWebhook.ErrInvalidSignature) {
+		t.Fatalf("Verify() with wrong secret = %v; want ErrInvalidSignature", err)
+	}
+
+	old := now.Add(-time.Hour)
+	_, err = convWebhook.Verify("secret", newRequest(old, convWebhook.Sign("secret", old, body)), time.Minute)
+	if !errors.Is(err, convWebhook.ErrExpiredTimestamp) {
+		t.Fatalf("Verify() with old timestamp = %v; want ErrExpiredTimestamp", err)
+	}
+
+	_, err = convWebhook.Verify("secret", httptest.NewRequest(http.MethodPost, "/", nil), time.Minute)
+	if !errors.Is(err, convWebhook.ErrMissingSignature) {
+		t.Fatalf("Verify() without headers = %v; want ErrMissingSignature", err)
+	}
+}
+
+func Test_delivery(t *testing.T) {
+
+	ctx := adminCtx()
+	client := convAPI.NewClient[convWebhook.API]("localhost", testPort)
+
+	rcv := newReceiver()
+	defer rcv.Close()
+
+	sub, err := client.CreateSubscription.Call(ctx, testTenant, convWebhook.Subscription{
+		URL:    rcv.URL,
+		Events: []convWebhook.EventType{"order.created"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription() = %v; want nil", err)
+	}
+	if sub.ID == "" || sub.Secret == "" {
+		t.Fatalf("CreateSubscription() = %+v; want id and secret", sub)
+	}
+	rcv.secret = sub.Secret
+
+	got, err := client.GetSubscription.Call(ctx, testTenant, sub.ID)
+	if err != nil || got.URL != rcv.URL || got.Secret != "" {
+		t.Fatalf("GetSubscription() = %+v, %v; want subscription without secret", got, err)
+	}
+
+	err = convWebhook.Publish(ctx, testTenant, "order.deleted", map[string]string{"order": "1"})
+	if err != nil {
+		t.Fatalf("Publish() = %v; want nil", err)
+	}
+
+	err = convWebhook.Publish(ctx, testTenant, "order.created", map[string]string{"order": "2"})
+	if err != nil {
+		t.Fatalf("Publish() = %v; want nil", err)
+	}
+
+	eventually(t, "delivery", func() bool { return len(rcv.received()) > 0 })
+
+	events := rcv.received()
+	if len(events) != 1 || events[0].Type != "order.created" || string(events[0].Data) != `{"order":"2"}` {
+		t.Fatalf("received = %+v; want the order.created event only", events)
+	}
+
+	err = client.DeleteSubscription.Call(ctx, testTenant, sub.ID)
+	if err != nil {
+		t.Fatalf("DeleteSubscription() = %v; want nil", err)
+	}
+
+	_, err = client.GetSubscription.Call(ctx, testTenant, sub.ID)
+
+	var apiErr *convAPI.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("GetSubscription() after delete = %v; want 404", err)
+	}
+}
+
+func Test_dead_letter_and_replay(t *testing.T) {
+
+	ctx := adminCtx()
+	client := convAPI.NewClient[convWebhook.API]("localhost", testPort)
+
+	rcv := newReceiver()
+	defer rcv.Close()
+	rcv.fail.Store(true)
+
+	sub, err := client.CreateSubscription.Call(ctx, testTenant, convWebhook.Subscription{
+		URL:    rcv.URL,
+		Events: []convWebhook.EventType{"invoice.paid"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription() = %v; want nil", err)
+	}
+	rcv.secret = sub.Secret
+	defer client.DeleteSubscription.Call(ctx, testTenant, sub.ID)
+
+	err = convWebhook.Publish(ctx, testTenant, "invoice.paid", map[string]int{"amount": 100})
+	if err != nil {
+		t.Fatalf("Publish() = %v; want nil", err)
+	}
+
+	var dead convWebhook.Delivery
+	eventually(t, "dead letter", func() bool {
+		dls, err := client.ListDeadLetters.Call(ctx, testTenant)
+		if err != nil {
+			t.Fatalf("ListDeadLetters() = %v; want nil", err)
+		}
+		for _, dl := range dls {
+			if dl.Subscription == sub.ID {
+				dead = dl
+				return true
+			}
+		}
+		return false
+	})
+
+	if dead.Attempts != 2 || dead.LastStatus != http.StatusServiceUnavailable {
+		t.Fatalf("dead letter = %+v; want 2 attempts with status 503", dead)
+	}
+
+	rcv.fail.Store(false)
+
+	replayed, err := client.ReplayDelivery.Call(ctx, testTenant, dead.ID)
+	if err != nil || replayed.State != convWebhook.DeliveryStatePending {
+		t.Fatalf("ReplayDelivery() = %+v, %v; want pending delivery", replayed, err)
+	}
+
+	eventually(t, "replayed delivery", func() bool { return len(rcv.received()) > 0 })
+
+	del, err := client.GetDelivery.Call(ctx, testTenant, dead.ID)
+	if err != nil || del.State != convWebhook.DeliveryStateDelivered {
+		t.Fatalf("GetDelivery() = %+v, %v; want delivered", del, err)
+	}
+
+	_, err = client.ReplayDelivery.Call(ctx, testTenant, dead.ID)
+
+	var apiErr *convAPI.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		t.Fatalf("ReplayDelivery() of delivered = %v; want 409", err)
+	}
+}
+
+func Test_subscribe_tenant_not_served(t *testing.T) {
+
+	ctx := convCtx.New(convAuth.Claims{
+		User:    "admin",
+		Tenants: convAuth.Tenants{"other"},
+		Roles:   convAuth.Roles{roleAdmin},
+	})
+	sub := convWebhook.Subscription{URL: "https://example.com/hook"}
+
+	_, err := convWebhook.Subscribe(ctx, "other", sub)
+	if !errors.Is(err, convWebhook.ErrTenantNotServed) {
+		t.Fatalf("Subscribe() = %v; want ErrTenantNotServed", err)
+	}
+
+	client := convAPI.NewClient[convWebhook.API]("localhost", testPort)
+
+	_, err = client.CreateSubscription.Call(ctx, "other", sub)
+
+	var apiErr *convAPI.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("CreateSubscription() = %v; want 400", err)
+	}
+}
+
+func Test_private_urls(t *testing.T) {
+
+	ctx := adminCtx()
+	client := convAPI.NewClient[convWebhook.API]("localhost", testPort)
+
+	rcv := newReceiver()
+	defer rcv.Close()
+
+	sub, err := client.CreateSubscription.Call(ctx, testTenant, convWebhook.Subscription{
+		URL:    rcv.URL,
+		Events: []convWebhook.EventType{"order.shipped"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription() = %v; want nil", err)
+	}
+	rcv.secret = sub.Secret
+	defer client.DeleteSubscription.Call(ctx, testTenant, sub.ID)
+
+	convWebhook.AllowPrivateURLs = false
+	defer func() { convWebhook.AllowPrivateURLs = true }()
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://[::1]/hook",
+	} {
+		_, err := convWebhook.Subscribe(ctx, testTenant, convWebhook.Subscription{URL: u})
+		if !errors.Is(err, convWebhook.ErrInvalidSubscription) {
+			t.Fatalf("Subscribe(%s) = %v; want ErrInvalidSubscription", u, err)
+		}
+	}
+
+	// subscribed before, to a receiver that is private by now
+	err = convWebhook.Publish(ctx, testTenant, "order.shipped", map[string]string{"order": "3"})
+	if err != nil {
+		t.Fatalf("Publish() = %v; want nil", err)
+	}
+
+	var dead convWebhook.Delivery
+	eventually(t, "dead letter", func() bool {
+		dls, err := client.ListDeadLetters.Call(ctx, testTenant)
+		if err != nil {
+			t.Fatalf("ListDeadLetters() = %v; want nil", err)
+		}
+		for _, dl := range dls {
+			if dl.Subscription == sub.ID {
+				dead = dl
+				return true
+			}
+		}
+		return false
+	})
+
+	if len(rcv.received()) != 0 || !strings.Contains(dead.LastError, "not allowed") {
+		t.Fatalf("dead letter = %+v; want not delivered to the private address", dead)
+	}
+}